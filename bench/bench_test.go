@@ -0,0 +1,139 @@
+package bench
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/matcher"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+	log "github.com/sirupsen/logrus"
+)
+
+// Small enough for go test -bench to run in seconds, pass bigger sizes after -args for a whole genome
+var (
+	benchDir             = flag.String("bench.dir", "", "Directory to keep the synthetic data in, a temporary one by default")
+	benchClinvarVariants = flag.Int("bench.clinvar-variants", 50000, "ClinVar variants to generate")
+	benchSampleVariants  = flag.Int("bench.sample-variants", 200000, "Sample variants to generate")
+)
+
+var (
+	datasetOnce sync.Once
+	dataset     *Dataset
+	datasetErr  error
+	tempDir     string
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	log.SetLevel(log.WarnLevel)
+	code := m.Run()
+	if tempDir != "" {
+		os.RemoveAll(tempDir)
+	}
+	os.Exit(code)
+}
+
+// Generated the first time a benchmark needs it, so go test without -bench doesn't pay for it
+func benchDataset(b *testing.B) *Dataset {
+	datasetOnce.Do(func() {
+		dir := *benchDir
+		if dir == "" {
+			tempDir, datasetErr = ioutil.TempDir("", "clinvar-bench")
+			if datasetErr != nil {
+				return
+			}
+			dir = tempDir
+		} else if existing, ok := Existing(dir); ok {
+			dataset = existing
+			return
+		}
+		dataset, datasetErr = Generate(Config{
+			Dir:             dir,
+			ClinvarVariants: *benchClinvarVariants,
+			SampleVariants:  *benchSampleVariants,
+			MatchFraction:   0.01,
+			Seed:            1,
+		})
+	})
+	if datasetErr != nil {
+		b.Fatal(datasetErr)
+	}
+	return dataset
+}
+
+func workerCounts() []int {
+	counts := []int{1}
+	if runtime.NumCPU() > 1 {
+		counts = append(counts, runtime.NumCPU())
+	}
+	return counts
+}
+
+func readSample(b *testing.B, path string, workers int) (*vcf.Header, []*vcf.VcfLine) {
+	input, err := os.Open(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer input.Close()
+	header, variants, err := vcf.ReadVcfFromParallel(input, workers)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return header, variants
+}
+
+func BenchmarkLoadClinvar(b *testing.B) {
+	data := benchDataset(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, result, err := LoadClinvar(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportMetric(result.BytesPerVariant(), "heap-B/variant")
+	}
+}
+
+func BenchmarkParseSample(b *testing.B) {
+	data := benchDataset(b)
+	for _, workers := range workerCounts() {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			variants := 0
+			start := time.Now()
+			for i := 0; i < b.N; i++ {
+				_, lines := readSample(b, data.SampleVcfPath, workers)
+				variants = len(lines)
+			}
+			b.ReportMetric(float64(variants)*float64(b.N)/time.Since(start).Seconds(), "variants/s")
+		})
+	}
+}
+
+func BenchmarkMatchSample(b *testing.B) {
+	data := benchDataset(b)
+	client, err := clinvar.NewClinvar(data.ClinvarVcfPath, data.ClinvarSubmissionPath)
+	if err != nil {
+		b.Fatal(err)
+	}
+	header, variants := readSample(b, data.SampleVcfPath, runtime.NumCPU())
+	for _, workers := range workerCounts() {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			config := matcher.ReportConfig{OutputFile: "the benchmark", Workers: workers, SVOverlap: clinvar.DefaultSVOverlap}
+			b.ResetTimer()
+			start := time.Now()
+			for i := 0; i < b.N; i++ {
+				if err := matcher.WriteReport(ioutil.Discard, config, client, header, variants); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(variants))*float64(b.N)/time.Since(start).Seconds(), "variants/s")
+		})
+	}
+}
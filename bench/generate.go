@@ -0,0 +1,231 @@
+package bench
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	ClinvarFile    = "clinvar.vcf.gz"
+	SubmissionFile = "submission_summary.txt.gz"
+	SampleFile     = "sample.vcf"
+
+	// Close to a current ClinVar release and a 30x whole genome
+	DefaultClinvarVariants = 1000000
+	DefaultSampleVariants  = 4500000
+)
+
+var (
+	chromosomes = []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12", "13", "14", "15",
+		"16", "17", "18", "19", "20", "21", "22", "X"}
+	bases         = []string{"A", "C", "G", "T"}
+	significances = []string{"Pathogenic", "Likely pathogenic", "Uncertain significance", "Likely benign",
+		"Benign", "not provided"}
+	reviewStatuses = []string{"criteria_provided,_single_submitter",
+		"criteria_provided,_multiple_submitters,_no_conflicts", "reviewed_by_expert_panel"}
+)
+
+// Sizes of the synthetic data, written to Dir
+type Config struct {
+	Dir             string
+	ClinvarVariants int
+	SampleVariants  int
+	// Share of the sample's variants that are in ClinVar, the rest miss
+	MatchFraction float64
+	Seed          int64
+}
+
+type Dataset struct {
+	ClinvarVcfPath        string
+	ClinvarSubmissionPath string
+	SampleVcfPath         string
+}
+
+// Writes a ClinVar VCF and submission summary along with a single sample VCF that hits some of its
+// variants, shaped like the real files so they exercise the same parsing
+func Generate(config Config) (*Dataset, error) {
+	if config.ClinvarVariants < len(chromosomes) || config.SampleVariants < len(chromosomes) {
+		return nil, fmt.Errorf("need at least %d ClinVar and sample variants, one per chromosome", len(chromosomes))
+	}
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return nil, err
+	}
+	dataset := datasetIn(config.Dir)
+	random := rand.New(rand.NewSource(config.Seed))
+	if err := writeFile(dataset.ClinvarVcfPath, true, func(w *bufio.Writer) error {
+		return writeClinvarVcf(w, config, random)
+	}); err != nil {
+		return nil, err
+	}
+	if err := writeFile(dataset.ClinvarSubmissionPath, true, func(w *bufio.Writer) error {
+		return writeSubmissions(w, config, random)
+	}); err != nil {
+		return nil, err
+	}
+	if err := writeFile(dataset.SampleVcfPath, false, func(w *bufio.Writer) error {
+		return writeSample(w, config, random)
+	}); err != nil {
+		return nil, err
+	}
+	return dataset, nil
+}
+
+func datasetIn(dir string) *Dataset {
+	return &Dataset{
+		ClinvarVcfPath:        filepath.Join(dir, ClinvarFile),
+		ClinvarSubmissionPath: filepath.Join(dir, SubmissionFile),
+		SampleVcfPath:         filepath.Join(dir, SampleFile),
+	}
+}
+
+// The data an earlier Generate left in dir, if all of it is there
+func Existing(dir string) (*Dataset, bool) {
+	dataset := datasetIn(dir)
+	for _, path := range []string{dataset.ClinvarVcfPath, dataset.ClinvarSubmissionPath, dataset.SampleVcfPath} {
+		if _, err := os.Stat(path); err != nil {
+			return nil, false
+		}
+	}
+	return dataset, true
+}
+
+func writeFile(path string, compress bool, write func(*bufio.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var output io.Writer = file
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(file)
+		output = gz
+	}
+	buffered := bufio.NewWriterSize(output, 1<<20)
+	if err := write(buffered); err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	if gz != nil {
+		return gz.Close()
+	}
+	return nil
+}
+
+// ClinVar variants are spread evenly over the chromosomes, this far apart
+const clinvarSpacing = 100
+
+func clinvarPosition(index int) int {
+	return 10000 + index*clinvarSpacing
+}
+
+// The REF and ALT are derived from the variant's number, so the sample can reproduce them
+func clinvarAlleles(id int) (string, string) {
+	return bases[id%4], bases[(id+1+(id/4)%3)%4]
+}
+
+func writeClinvarVcf(w *bufio.Writer, config Config, random *rand.Rand) error {
+	fmt.Fprintln(w, "##fileformat=VCFv4.1")
+	fmt.Fprintln(w, "##fileDate=2020-07-06")
+	fmt.Fprintln(w, "##source=ClinVar")
+	fmt.Fprintln(w, "##reference=GRCh37")
+	fmt.Fprintln(w, "#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO")
+	perChrom := config.ClinvarVariants / len(chromosomes)
+	id := 0
+	for _, chrom := range chromosomes {
+		for i := 0; i < perChrom; i++ {
+			ref, alt := clinvarAlleles(id)
+			_, err := fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t.\t.\tALLELEID=%d;CLNSIG=%s;CLNREVSTAT=%s;CLNVC=single_nucleotide_variant;GENEINFO=%s:%d;RS=%d;CLNHGVS=NC_000001.10:g.%d%s>%s\n",
+				chrom, clinvarPosition(i), id, ref, alt, id+1,
+				strings.Replace(significances[random.Intn(len(significances))], " ", "_", -1),
+				reviewStatuses[random.Intn(len(reviewStatuses))], geneName(id), id/50+1, id+1000,
+				clinvarPosition(i), ref, alt)
+			if err != nil {
+				return err
+			}
+			id++
+		}
+	}
+	return nil
+}
+
+// About fifty variants to a gene, like the genes with the most ClinVar variants
+func geneName(id int) string {
+	return fmt.Sprintf("GENE%d", id/50)
+}
+
+func writeSubmissions(w *bufio.Writer, config Config, random *rand.Rand) error {
+	fmt.Fprintln(w, "#VariationID\tClinicalSignificance\tDateLastEvaluated\tDescription\tSubmittedPhenotypeInfo\tReportedPhenotypeInfo\tReviewStatus\tCollectionMethod\tOriginCounts\tSubmitter\tSCV\tSubmittedGeneSymbol\tExplanationOfInterpretation")
+	variants := config.ClinvarVariants / len(chromosomes) * len(chromosomes)
+	scv := 0
+	for id := 0; id < variants; id++ {
+		// Most variants have one or two submissions, a few have many
+		submissions := 1 + random.Intn(2)
+		if random.Intn(20) == 0 {
+			submissions += random.Intn(10)
+		}
+		for i := 0; i < submissions; i++ {
+			disease := random.Intn(5000)
+			_, err := fmt.Fprintf(w, "%d\t%s\tJan 01, 2019\t-\tDisease %d\tC%07d:Disease %d\tcriteria provided, single submitter\tclinical testing\tgermline:1\tLab %d\tSCV%09d.1\t%s\t-\n",
+				id, significances[random.Intn(len(significances))], disease, disease, disease,
+				random.Intn(300), scv, geneName(id))
+			if err != nil {
+				return err
+			}
+			scv++
+		}
+	}
+	return nil
+}
+
+// Sample variants are interleaved with the ClinVar ones, taking a ClinVar variant's place often
+// enough to match MatchFraction of them
+func writeSample(w *bufio.Writer, config Config, random *rand.Rand) error {
+	fmt.Fprintln(w, "##fileformat=VCFv4.2")
+	fmt.Fprintln(w, "##FORMAT=<ID=GT,Number=1,Type=String,Description=\"Genotype\">")
+	fmt.Fprintln(w, "##FORMAT=<ID=DP,Number=1,Type=Integer,Description=\"Read Depth\">")
+	fmt.Fprintln(w, "##FORMAT=<ID=GQ,Number=1,Type=Integer,Description=\"Genotype Quality\">")
+	fmt.Fprintln(w, "#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT\tSAMPLE")
+	clinvarPerChrom := config.ClinvarVariants / len(chromosomes)
+	perChrom := config.SampleVariants / len(chromosomes)
+	spacing := clinvarPerChrom * clinvarSpacing / perChrom
+	if spacing < 1 {
+		spacing = 1
+	}
+	genotypes := []string{"0/1", "0/1", "1/1"}
+	for c, chrom := range chromosomes {
+		for i := 0; i < perChrom; i++ {
+			pos := clinvarPosition(0) + i*spacing + 1
+			base := random.Intn(4)
+			ref, alt := bases[base], bases[(base+1+random.Intn(3))%4]
+			if random.Float64() < config.MatchFraction {
+				index := (pos - clinvarPosition(0)) / clinvarSpacing
+				if index >= clinvarPerChrom {
+					index = clinvarPerChrom - 1
+				}
+				pos = clinvarPosition(index)
+				ref, alt = clinvarAlleles(c*clinvarPerChrom + index)
+			}
+			filter := "PASS"
+			if random.Intn(10) == 0 {
+				filter = "LowQual"
+			}
+			depth := 10 + random.Intn(40)
+			_, err := fmt.Fprintf(w, "%s\t%d\t.\t%s\t%s\t%d\t%s\tDP=%d\tGT:DP:GQ\t%s:%d:%d\n",
+				chrom, pos, ref, alt, 20+random.Intn(60), filter, depth,
+				genotypes[random.Intn(len(genotypes))], depth, 20+random.Intn(79))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
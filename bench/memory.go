@@ -0,0 +1,53 @@
+package bench
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+)
+
+// What loading ClinVar cost
+type MemoryResult struct {
+	Variants    int
+	Submissions int
+	// Heap still in use once ClinVar is loaded and garbage collected
+	Heap uint64
+	Load time.Duration
+}
+
+// Heap in use per ClinVar variant, including its share of the submissions
+func (result MemoryResult) BytesPerVariant() float64 {
+	return float64(result.Heap) / float64(result.Variants)
+}
+
+// Loads ClinVar, measuring how much of the heap it holds on to
+func LoadClinvar(dataset *Dataset) (*clinvar.ClinvarClient, *MemoryResult, error) {
+	before := heapInUse()
+	start := time.Now()
+	client, err := clinvar.NewClinvar(dataset.ClinvarVcfPath, dataset.ClinvarSubmissionPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	result := &MemoryResult{Load: time.Since(start), Heap: heapSince(before)}
+	result.Variants = client.VariantCount()
+	result.Submissions = client.SubmissionCount()
+	return client, result, nil
+}
+
+// How much more of the heap is in use than before, anything allocated since has to be kept alive
+// until this returns
+func heapSince(before uint64) uint64 {
+	after := heapInUse()
+	if after < before {
+		return 0
+	}
+	return after - before
+}
+
+func heapInUse() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
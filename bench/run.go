@@ -0,0 +1,87 @@
+package bench
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/matcher"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+	log "github.com/sirupsen/logrus"
+)
+
+// How long the sample took with a number of workers
+type Result struct {
+	Workers  int
+	Variants int
+	// Heap the parsed variants take
+	Heap  uint64
+	Parse time.Duration
+	Match time.Duration
+}
+
+func (result Result) Total() time.Duration {
+	return result.Parse + result.Match
+}
+
+// Sample variants parsed and matched a second
+func (result Result) Throughput() float64 {
+	return float64(result.Variants) / result.Total().Seconds()
+}
+
+// Reads and matches the sample with each number of workers. The reports aren't kept, but they have
+// to come out the same for every number of workers
+func Run(dataset *Dataset, client *clinvar.ClinvarClient, workerCounts []int) ([]Result, error) {
+	results := make([]Result, 0, len(workerCounts))
+	var firstReport []byte
+	for _, workers := range workerCounts {
+		result, report, err := runOnce(dataset, client, workers)
+		if err != nil {
+			return results, err
+		}
+		if firstReport == nil {
+			firstReport = report
+		} else if !bytes.Equal(report, firstReport) {
+			return results, fmt.Errorf("the report with %d workers differs from the one with %d", workers, workerCounts[0])
+		}
+		log.Infof("%d workers: parsed in %v, matched in %v", workers, result.Parse, result.Match)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Returns the result along with a hash of the report
+func runOnce(dataset *Dataset, client *clinvar.ClinvarClient, workers int) (Result, []byte, error) {
+	result := Result{Workers: workers}
+	input, err := os.Open(dataset.SampleVcfPath)
+	if err != nil {
+		return result, nil, err
+	}
+	defer input.Close()
+
+	before := heapInUse()
+	start := time.Now()
+	header, variants, err := vcf.ReadVcfFromParallel(input, workers)
+	if err != nil {
+		return result, nil, err
+	}
+	result.Parse = time.Since(start)
+	result.Variants = len(variants)
+	result.Heap = heapSince(before)
+
+	config := matcher.ReportConfig{
+		OutputFile: "the benchmark",
+		Workers:    workers,
+		SVOverlap:  clinvar.DefaultSVOverlap,
+	}
+	report := sha256.New()
+	start = time.Now()
+	if err := matcher.WriteReport(report, config, client, header, variants); err != nil {
+		return result, nil, err
+	}
+	result.Match = time.Since(start)
+	return result, report.Sum(nil), nil
+}
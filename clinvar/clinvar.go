@@ -1,14 +1,14 @@
 package clinvar
 
 import (
-	"bufio"
-	"compress/gzip"
+	"context"
 	"fmt"
-	"os"
 	"sort"
 	"strings"
 	"sync"
 
+	"github.com/kazmiekr/clinvar-matcher/interval"
+	"github.com/kazmiekr/clinvar-matcher/progress"
 	"github.com/kazmiekr/clinvar-matcher/vcf"
 	log "github.com/sirupsen/logrus"
 )
@@ -20,6 +20,15 @@ const (
 	AFEspKey                 = "AF_ESP"
 	AFExacKey                = "AF_EXAC"
 	AFTgpKey                 = "AF_TGP"
+	ReviewStatusKey          = "CLNREVSTAT"
+	GeneInfoKey              = "GENEINFO"
+	MolecularConsequenceKey  = "MC"
+	HgvsKey                  = "CLNHGVS"
+)
+
+const (
+	AssemblyGRCh37 = "GRCh37"
+	AssemblyGRCh38 = "GRCh38"
 )
 
 type Pathogenicity int
@@ -42,15 +51,86 @@ var PathogenicitytoString = map[Pathogenicity]string{
 	PathogenicityOther:            "Other",
 }
 
+var PathogenicityAbbreviations = map[Pathogenicity]string{
+	PathogenicityBenign:           "B",
+	PathogenicityLikelyBenign:     "LB",
+	PathogenicityVUS:              "VUS",
+	PathogenicityLikelyPathogenic: "LP",
+	PathogenicityPathogenic:       "P",
+	PathogenicityOther:            "Other",
+}
+
 func (p Pathogenicity) ToString() string {
 	return PathogenicitytoString[p]
 }
 
+// Short name like LP, which ParsePathogenicity also accepts
+func (p Pathogenicity) Abbreviation() string {
+	return PathogenicityAbbreviations[p]
+}
+
+// Accepts the full pathogenicity names, ClinVar's underscored versions and the common
+// abbreviations B, LB, VUS, LP and P, ignoring case
+func ParsePathogenicity(value string) (Pathogenicity, bool) {
+	value = strings.ToLower(strings.Replace(strings.TrimSpace(value), "_", " ", -1))
+	switch value {
+	case "b", "benign":
+		return PathogenicityBenign, true
+	case "lb", "likely benign":
+		return PathogenicityLikelyBenign, true
+	case "vus", "us", "uncertain significance":
+		return PathogenicityVUS, true
+	case "lp", "likely pathogenic":
+		return PathogenicityLikelyPathogenic, true
+	case "p", "pathogenic":
+		return PathogenicityPathogenic, true
+	case "other":
+		return PathogenicityOther, true
+	}
+	return 0, false
+}
+
+// Converts the ClinVar review status into the gold stars shown on the ClinVar website
+func ReviewStars(reviewStatus string) int {
+	status := strings.ToLower(strings.Replace(reviewStatus, "_", " ", -1))
+	switch status {
+	case "practice guideline":
+		return 4
+	case "reviewed by expert panel":
+		return 3
+	case "criteria provided, multiple submitters, no conflicts":
+		return 2
+	case "criteria provided, single submitter",
+		"criteria provided, conflicting interpretations",
+		"criteria provided, conflicting classifications":
+		return 1
+	default:
+		return 0
+	}
+}
+
 type ClinvarClient struct {
-	Variants        []*vcf.VcfLine
-	VariantsByKey   map[string]*vcf.VcfLine
-	Assessments     []*ClinvarSubmission
-	AssessmentsByID map[string][]*ClinvarSubmission
+	// Every variant by chromosome, position and alleles, see FindVariant
+	variants *variantIndex
+	// Variants for each normalized chromosome, sorted by position
+	VariantsByChrom map[string][]*vcf.VcfLine
+	// Variants for each rsID, like rs80357906
+	VariantsByRsid map[string][]*vcf.VcfLine
+	// Variants for each ClinVar variation ID, from the VCF's ID column
+	VariantsByID map[string][]*vcf.VcfLine
+	// Variants for each upper cased GENEINFO gene symbol
+	VariantsByGene map[string][]*vcf.VcfLine
+	// Submissions for each variation ID, see Submissions
+	submissions *submissionStore
+	// Deletions, duplications and other SVs for each normalized chromosome
+	StructuralVariants map[string]*interval.Tree
+	// ClinVar's fileDate for the weekly release, like 2020-07-06, blank when the VCF doesn't say
+	ReleaseDate string
+	// Reference assembly from the VCF's ##reference, AssemblyGRCh37 or AssemblyGRCh38, blank when
+	// it's something else or missing
+	Assembly string
+	// Length of the longest REF, for finding variants that reach into a range
+	longestRef int
 }
 
 type ClinvarRecord struct {
@@ -59,8 +139,77 @@ type ClinvarRecord struct {
 	Pathogenicity       Pathogenicity
 	PathogenicityCounts map[Pathogenicity]int
 	AssessmentCount     int
+	Stars               int
 	Diseases            []string
 	Genes               []string
+	// How the sample variant was matched to ClinVar, one of the MatchMethod constants
+	MatchMethod string
+	// Change made to the sample's alleles to match, one of the MatchTransform constants or blank
+	MatchTransform string
+	// For SVs matched on overlap, the fraction each SV covers of the other
+	ReciprocalOverlap float64
+}
+
+// Gene symbols from both the ClinVar GENEINFO field and the submitted gene symbols
+func (record *ClinvarRecord) GeneSymbols() []string {
+	symbols := GeneInfoSymbols(record.Variant.GetInfo(GeneInfoKey))
+	for _, gene := range record.Genes {
+		found := false
+		for _, symbol := range symbols {
+			if symbol == gene {
+				found = true
+				break
+			}
+		}
+		if !found {
+			symbols = append(symbols, gene)
+		}
+	}
+	return symbols
+}
+
+// ClinVar's own aggregate classification from CLNSIG, rather than the max of the submissions.
+// Conflicting classifications come back as PathogenicityOther
+func (record *ClinvarRecord) AggregatePathogenicity() Pathogenicity {
+	return VariantPathogenicity(record.Variant)
+}
+
+// ClinVar's aggregate classification of a variant line, without needing its submissions
+func VariantPathogenicity(variant *vcf.VcfLine) Pathogenicity {
+	return getPathogencityFromClinsig(variant.GetInfo(SignficanceKey))
+}
+
+func (record *ClinvarRecord) IsPathogenic() bool {
+	p := record.AggregatePathogenicity()
+	return p == PathogenicityLikelyPathogenic || p == PathogenicityPathogenic
+}
+
+var truncatingConsequences = []string{"nonsense", "stop_gained", "frameshift", "splice_donor", "splice_acceptor"}
+
+// Checks the molecular consequences ClinVar lists for the variant, like SO:0001587|nonsense
+func (record *ClinvarRecord) IsTruncating() bool {
+	consequences := strings.ToLower(record.Variant.GetInfo(MolecularConsequenceKey))
+	for _, consequence := range truncatingConsequences {
+		if strings.Contains(consequences, consequence) {
+			return true
+		}
+	}
+	return false
+}
+
+// Pulls the symbols out of a GENEINFO value, which looks like BRCA1:672|NBR2:10230
+func GeneInfoSymbols(geneInfo string) []string {
+	symbols := make([]string, 0)
+	if geneInfo == "" {
+		return symbols
+	}
+	for _, gene := range strings.Split(geneInfo, "|") {
+		symbol := strings.Split(gene, ":")[0]
+		if symbol != "" {
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols
 }
 
 type ClinvarSubmission struct {
@@ -86,57 +235,87 @@ type ClinvarDisease struct {
 	DiseaseName string
 }
 
-func (clinvar *ClinvarClient) Lookup(variantClinvarKey string) (*ClinvarRecord, bool) {
-	variant, ok := clinvar.VariantsByKey[variantClinvarKey]
+// Finds the record for the ClinVar variant with the same chromosome, position, REF and ALT as the line
+func (clinvar *ClinvarClient) Lookup(line *vcf.VcfLine) (*ClinvarRecord, bool) {
+	variant, ok := clinvar.FindVariant(line)
 	if !ok {
 		return nil, ok
 	}
-	assessments, ok := clinvar.AssessmentsByID[variant.ID]
+	return clinvar.LookupVariant(variant)
+}
+
+// Finds the ClinVar variant with the same chromosome, position, REF and ALT as the line
+func (clinvar *ClinvarClient) FindVariant(line *vcf.VcfLine) (*vcf.VcfLine, bool) {
+	if clinvar.variants == nil {
+		return nil, false
+	}
+	return clinvar.variants.find(line)
+}
+
+// The submissions for a ClinVar variation ID, built fresh on each call so they can be changed
+func (clinvar *ClinvarClient) Submissions(variationID string) ([]*ClinvarSubmission, bool) {
+	if clinvar.submissions == nil {
+		return nil, false
+	}
+	return clinvar.submissions.get(variationID)
+}
+
+// Number of rows in the submission summary
+func (clinvar *ClinvarClient) SubmissionCount() int {
+	if clinvar.submissions == nil {
+		return 0
+	}
+	return clinvar.submissions.total
+}
+
+// Number of variation IDs with at least one submission
+func (clinvar *ClinvarClient) SubmittedVariantCount() int {
+	if clinvar.submissions == nil {
+		return 0
+	}
+	return len(clinvar.submissions.byID)
+}
+
+// Number of distinct ClinVar variants loaded
+func (clinvar *ClinvarClient) VariantCount() int {
+	if clinvar.variants == nil {
+		return 0
+	}
+	return clinvar.variants.len()
+}
+
+// Builds the record for a ClinVar variant by aggregating its submissions
+func (clinvar *ClinvarClient) LookupVariant(variant *vcf.VcfLine) (*ClinvarRecord, bool) {
+	assessments, ok := clinvar.Submissions(variant.ID)
 	if !ok {
 		return nil, ok
 	}
 
-	pathogenicityCounts := make(map[Pathogenicity]int)
-	diseases := make(map[string]struct{})
-	genes := make(map[string]struct{})
-	maxPathogenicity := PathogenicityBenign
-
-	allPaths := []Pathogenicity{PathogenicityBenign, PathogenicityLikelyBenign, PathogenicityVUS, PathogenicityLikelyPathogenic, PathogenicityPathogenic, PathogenicityOther}
-	for _, p := range allPaths {
+	pathogenicityCounts := make(map[Pathogenicity]int, len(allPathogenicities))
+	for _, p := range allPathogenicities {
 		pathogenicityCounts[p] = 0
 	}
-
+	diseases := make([]string, 0, len(assessments))
+	genes := make([]string, 0, len(assessments))
+	maxPathogenicity := PathogenicityBenign
 	for _, assessment := range assessments {
 		pathogenicityCounts[assessment.Pathogenicity]++
 		if assessment.Pathogenicity > maxPathogenicity {
 			maxPathogenicity = assessment.Pathogenicity
 		}
-		if _, ok := diseases[assessment.Disease.DiseaseName]; !ok {
-			diseases[assessment.Disease.DiseaseName] = struct{}{}
-		}
+		diseases = append(diseases, assessment.Disease.DiseaseName)
 		if assessment.SubmittedGeneSymbol != "-" {
-			if _, ok := genes[assessment.SubmittedGeneSymbol]; !ok {
-				genes[assessment.SubmittedGeneSymbol] = struct{}{}
-			}
+			genes = append(genes, assessment.SubmittedGeneSymbol)
 		}
 	}
-
-	uniqueDiseases := make([]string, 0)
-	for disease := range diseases {
-		uniqueDiseases = append(uniqueDiseases, disease)
-	}
-	sort.Strings(uniqueDiseases)
-
-	uniqueGenes := make([]string, 0)
-	for gene := range genes {
-		uniqueGenes = append(uniqueGenes, gene)
-	}
-	sort.Strings(uniqueGenes)
+	uniqueDiseases := sortedUnique(diseases)
+	uniqueGenes := sortedUnique(genes)
 
 	return &ClinvarRecord{
 		Variant:             variant,
 		Assessments:         assessments,
 		AssessmentCount:     len(assessments),
+		Stars:               ReviewStars(variant.GetInfo(ReviewStatusKey)),
 		PathogenicityCounts: pathogenicityCounts,
 		Pathogenicity:       maxPathogenicity,
 		Diseases:            uniqueDiseases,
@@ -144,14 +323,29 @@ func (clinvar *ClinvarClient) Lookup(variantClinvarKey string) (*ClinvarRecord,
 	}, ok
 }
 
+var allPathogenicities = []Pathogenicity{PathogenicityBenign, PathogenicityLikelyBenign, PathogenicityVUS, PathogenicityLikelyPathogenic, PathogenicityPathogenic, PathogenicityOther}
+
+// Sorts the values in place and drops the repeats, cheaper than a set for the handful of submissions
+// most variants have
+func sortedUnique(values []string) []string {
+	sort.Strings(values)
+	unique := values[:0]
+	for i, value := range values {
+		if i == 0 || value != values[i-1] {
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
 func (clinvar *ClinvarClient) PrintPathogenicityStats() {
 	submissionMap := make(map[string]int)
-	for _, assessment := range clinvar.Assessments {
-		p := assessment.ClinicalSignificance
-		if _, ok := submissionMap[p]; !ok {
-			submissionMap[p] = 0
+	if clinvar.submissions != nil {
+		for _, rows := range clinvar.submissions.byID {
+			for _, row := range rows {
+				submissionMap[clinvar.submissions.strings[row[1]]]++
+			}
 		}
-		submissionMap[p]++
 	}
 	fmt.Println("-----")
 	for key, value := range submissionMap {
@@ -164,28 +358,29 @@ func ToClinvarKey(vcfLine *vcf.VcfLine) string {
 }
 
 type assessmentLoad struct {
+	header      *vcf.Header
 	assessments []*vcf.VcfLine
 	err         error
 }
 
-func loadAssessments(assessmentsFile string, loadAssessmentsChan chan assessmentLoad, wg *sync.WaitGroup) {
+func loadAssessments(read func() (*vcf.Header, []*vcf.VcfLine, error), loadAssessmentsChan chan assessmentLoad, wg *sync.WaitGroup) {
 	defer wg.Done()
-	log.Infof("Loading clinvar assessments from %s", assessmentsFile)
-	assessments, err := vcf.ReadVcf(assessmentsFile)
+	header, assessments, err := read()
 	loadAssessmentsChan <- assessmentLoad{
+		header:      header,
 		assessments: assessments,
 		err:         err,
 	}
 }
 
 type submissionLoad struct {
-	submissions []*ClinvarSubmission
+	submissions *submissionStore
 	err         error
 }
 
-func loadSubmissions(submissionFile string, loadSubmissionsChan chan submissionLoad, wg *sync.WaitGroup) {
+func loadSubmissions(tracker *progress.Tracker, submissionFile string, loadSubmissionsChan chan submissionLoad, wg *sync.WaitGroup) {
 	defer wg.Done()
-	submissions, err := ParseSubmissionSummary(submissionFile)
+	submissions, err := loadSubmissionStore(submissionFile, tracker)
 	loadSubmissionsChan <- submissionLoad{
 		submissions: submissions,
 		err:         err,
@@ -229,6 +424,47 @@ func getPathogencityFromClinsig(clinsig string) Pathogenicity {
 }
 
 func NewClinvar(assessmentsFile string, submissionFile string) (*ClinvarClient, error) {
+	return NewClinvarContext(context.Background(), assessmentsFile, submissionFile, nil)
+}
+
+// Like NewClinvar, stopping with the context's error once it's cancelled and telling the observer,
+// which can be nil, how far it's got with each file
+func NewClinvarContext(ctx context.Context, assessmentsFile string, submissionFile string, observer progress.Observer) (*ClinvarClient, error) {
+	return newClinvar(ctx, observer, func() (*vcf.Header, []*vcf.VcfLine, error) {
+		log.Infof("Loading clinvar assessments from %s", assessmentsFile)
+		return vcf.ReadVcfWithHeaderContext(ctx, assessmentsFile, observer)
+	}, submissionFile)
+}
+
+// Only loads the ClinVar variants in the regions, using the tabix or CSI index next to the bgzipped
+// ClinVar VCF. Lookups outside the regions won't find anything
+func NewClinvarForRegions(assessmentsFile string, submissionFile string, regions []vcf.Region) (*ClinvarClient, error) {
+	return NewClinvarForRegionsContext(context.Background(), assessmentsFile, submissionFile, regions, nil)
+}
+
+// Like NewClinvarForRegions, but only the submission summary's progress is reported, since the
+// regions are read straight from the index
+func NewClinvarForRegionsContext(ctx context.Context, assessmentsFile string, submissionFile string, regions []vcf.Region, observer progress.Observer) (*ClinvarClient, error) {
+	return newClinvar(ctx, observer, func() (*vcf.Header, []*vcf.VcfLine, error) {
+		reader, err := vcf.OpenIndexed(assessmentsFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		header, err := reader.ReadHeader()
+		if err != nil {
+			return nil, nil, err
+		}
+		merged := vcf.MergeRegions(regions, 0)
+		log.Infof("Loading clinvar assessments in %d regions from %s", len(merged), assessmentsFile)
+		lines, err := reader.QueryRegions(merged)
+		if err == nil {
+			err = ctx.Err()
+		}
+		return header, lines, err
+	}, submissionFile)
+}
+
+func newClinvar(ctx context.Context, observer progress.Observer, readAssessments func() (*vcf.Header, []*vcf.VcfLine, error), submissionFile string) (*ClinvarClient, error) {
 	clinvarClient := &ClinvarClient{}
 
 	loadAssessmentsChan := make(chan assessmentLoad, 1)
@@ -236,8 +472,8 @@ func NewClinvar(assessmentsFile string, submissionFile string) (*ClinvarClient,
 
 	var wg sync.WaitGroup
 	wg.Add(2)
-	go loadAssessments(assessmentsFile, loadAssessmentsChan, &wg)
-	go loadSubmissions(submissionFile, loadSubmissionsChan, &wg)
+	go loadAssessments(readAssessments, loadAssessmentsChan, &wg)
+	go loadSubmissions(progress.NewTracker(ctx, observer, submissionFile), submissionFile, loadSubmissionsChan, &wg)
 	wg.Wait()
 
 	loadAssessmentsResult := <-loadAssessmentsChan
@@ -246,81 +482,119 @@ func NewClinvar(assessmentsFile string, submissionFile string) (*ClinvarClient,
 	}
 
 	log.Infof("Clinvar Assessment Count: %d\n", len(loadAssessmentsResult.assessments))
-	clinvarClient.VariantsByKey = buildClinvarMap(loadAssessmentsResult.assessments)
+	clinvarClient.ReleaseDate = ReleaseDate(loadAssessmentsResult.header)
+	clinvarClient.Assembly = Assembly(loadAssessmentsResult.header)
+	clinvarClient.variants = buildVariantIndex(loadAssessmentsResult.assessments)
+	clinvarClient.VariantsByChrom = buildChromIndex(loadAssessmentsResult.assessments)
+	clinvarClient.VariantsByRsid = buildRsidIndex(loadAssessmentsResult.assessments)
+	clinvarClient.VariantsByID = buildIDIndex(loadAssessmentsResult.assessments)
+	clinvarClient.VariantsByGene = buildGeneIndex(loadAssessmentsResult.assessments)
+	clinvarClient.StructuralVariants = buildStructuralIndex(loadAssessmentsResult.assessments)
+	clinvarClient.longestRef = longestRef(loadAssessmentsResult.assessments)
 
 	loadSubmissionsResult := <-loadSubmissionsChan
 	if loadSubmissionsResult.err != nil {
 		return clinvarClient, loadSubmissionsResult.err
 	}
-	log.Infof("Clinvar Submission Count: %d\n", len(loadSubmissionsResult.submissions))
+	log.Infof("Clinvar Submission Count: %d\n", loadSubmissionsResult.submissions.total)
+	clinvarClient.submissions = loadSubmissionsResult.submissions
+
+	return clinvarClient, nil
+}
 
+// Groups submissions by their variation ID
+func GroupSubmissions(submissions []*ClinvarSubmission) map[string][]*ClinvarSubmission {
 	submissionsMap := make(map[string][]*ClinvarSubmission)
-	for _, submission := range loadSubmissionsResult.submissions {
+	for _, submission := range submissions {
 		if _, ok := submissionsMap[submission.VariationID]; !ok {
 			submissionsMap[submission.VariationID] = make([]*ClinvarSubmission, 0)
 		}
 		submissionsMap[submission.VariationID] = append(submissionsMap[submission.VariationID], submission)
 	}
-	clinvarClient.AssessmentsByID = submissionsMap
+	return submissionsMap
+}
 
-	return clinvarClient, nil
+// Older ClinVar VCFs write the fileDate as 20200706, which is changed to 2020-07-06 to match the newer ones
+func ReleaseDate(header *vcf.Header) string {
+	date := header.Meta("fileDate")
+	if len(date) == 8 && strings.Trim(date, "0123456789") == "" {
+		return date[:4] + "-" + date[4:6] + "-" + date[6:]
+	}
+	return date
 }
 
-func buildClinvarMap(lines []*vcf.VcfLine) map[string]*vcf.VcfLine {
-	clinvarMap := make(map[string]*vcf.VcfLine)
-	for _, line := range lines {
-		clinvarMap[ToClinvarKey(line)] = line
+// ClinVar writes ##reference=GRCh37 or GRCh38, other VCFs may give hg19, hg38 or a FASTA path
+func Assembly(header *vcf.Header) string {
+	reference := strings.ToLower(header.Meta("reference"))
+	switch {
+	case strings.Contains(reference, "grch38"), strings.Contains(reference, "hg38"):
+		return AssemblyGRCh38
+	case strings.Contains(reference, "grch37"), strings.Contains(reference, "hg19"), strings.Contains(reference, "b37"):
+		return AssemblyGRCh37
 	}
-	return clinvarMap
+	return ""
 }
 
-func ParseSubmissionSummary(filePath string) ([]*ClinvarSubmission, error) {
-	log.Infof("Loading clinvar submission summary from %s", filePath)
-	file, err := os.Open(filePath)
-	submissions := make([]*ClinvarSubmission, 0)
-	if err != nil {
-		return submissions, err
+func buildChromIndex(lines []*vcf.VcfLine) map[string][]*vcf.VcfLine {
+	chromIndex := make(map[string][]*vcf.VcfLine)
+	for _, line := range lines {
+		chrom := vcf.NormalizeChrom(line.Chrom)
+		chromIndex[chrom] = append(chromIndex[chrom], line)
 	}
-	defer file.Close()
-	gz, err := gzip.NewReader(file)
-	if err != nil {
-		return submissions, err
+	for _, chromLines := range chromIndex {
+		sort.SliceStable(chromLines, func(i, j int) bool {
+			return chromLines[i].Pos < chromLines[j].Pos
+		})
 	}
-	defer gz.Close()
-	scanner := bufio.NewScanner(gz)
+	return chromIndex
+}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line[0] == '#' {
-			continue
-		}
-		parts := strings.Split(line, "\t")
-		if len(parts) < 13 {
-			continue
-		}
-		reportedPhenotype := parts[5]
-		submission := &ClinvarSubmission{
-			VariationID:                 parts[0],
-			ClinicalSignificance:        parts[1],
-			Pathogenicity:               getPathogencityFromClinsig(parts[1]),
-			Disease:                     getDiseaseFromPhenotype(reportedPhenotype),
-			DateLastEvaluated:           parts[2],
-			Description:                 parts[3],
-			SubmittedPhenotypeInfo:      parts[4],
-			ReportedPhenotypeInfo:       reportedPhenotype,
-			ReviewStatus:                parts[6],
-			CollectionMethod:            parts[7],
-			OriginCounts:                parts[8],
-			Submitter:                   parts[9],
-			SCV:                         parts[10],
-			SubmittedGeneSymbol:         parts[11],
-			ExplanationOfInterpretation: parts[12],
+func buildIDIndex(lines []*vcf.VcfLine) map[string][]*vcf.VcfLine {
+	idIndex := make(map[string][]*vcf.VcfLine)
+	for _, line := range lines {
+		idIndex[line.ID] = append(idIndex[line.ID], line)
+	}
+	return idIndex
+}
+
+func buildGeneIndex(lines []*vcf.VcfLine) map[string][]*vcf.VcfLine {
+	geneIndex := make(map[string][]*vcf.VcfLine)
+	for _, line := range lines {
+		for _, symbol := range GeneInfoSymbols(line.GetInfo(GeneInfoKey)) {
+			symbol = strings.ToUpper(symbol)
+			geneIndex[symbol] = append(geneIndex[symbol], line)
 		}
-		submissions = append(submissions, submission)
 	}
+	return geneIndex
+}
 
-	if err := scanner.Err(); err != nil {
-		return submissions, err
+// Returns the ClinVar variants in a gene, ignoring the case of the symbol
+func (clinvar *ClinvarClient) VariantsInGene(gene string) []*vcf.VcfLine {
+	return clinvar.VariantsByGene[strings.ToUpper(gene)]
+}
+
+// Returns the ClinVar variants that start at this position
+func (clinvar *ClinvarClient) VariantsAt(chrom string, pos int) []*vcf.VcfLine {
+	chromLines := clinvar.VariantsByChrom[vcf.NormalizeChrom(chrom)]
+	start := sort.Search(len(chromLines), func(i int) bool {
+		return chromLines[i].Pos >= pos
+	})
+	end := start
+	for end < len(chromLines) && chromLines[end].Pos == pos {
+		end++
 	}
-	return submissions, nil
+	return chromLines[start:end]
+}
+
+// Reads every row of the submission summary, sharing one copy of the strings that repeat
+func ParseSubmissionSummary(filePath string) ([]*ClinvarSubmission, error) {
+	submissions := make([]*ClinvarSubmission, 0)
+	table := newStringTable()
+	err := readSubmissionSummary(filePath, nil, func(parts []string) {
+		for i := 0; i < submissionColumns; i++ {
+			parts[i] = table.strings[table.intern(parts[i])]
+		}
+		submissions = append(submissions, newSubmission(parts))
+	})
+	return submissions, err
 }
@@ -0,0 +1,63 @@
+package clinvar
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+const submissionHeader = "#VariationID\tClinicalSignificance\tDateLastEvaluated\tDescription\tSubmittedPhenotypeInfo\t" +
+	"ReportedPhenotypeInfo\tReviewStatus\tCollectionMethod\tOriginCounts\tSubmitter\tSCV\tSubmittedGeneSymbol\t" +
+	"ExplanationOfInterpretation\n"
+
+// Writes the ClinVar VCF and submission summary rows, which go under the summary's header, into dir
+func writeTestClinvar(t *testing.T, dir string, vcfText string, submissionRows string) (string, string) {
+	vcfPath := filepath.Join(dir, "clinvar.vcf")
+	submissionPath := filepath.Join(dir, "submission_summary.txt")
+	if err := ioutil.WriteFile(vcfPath, []byte(vcfText), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(submissionPath, []byte(submissionHeader+submissionRows), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return vcfPath, submissionPath
+}
+
+func loadTestClinvar(t *testing.T, vcfText string, submissionRows string) *ClinvarClient {
+	dir, err := ioutil.TempDir("", "clinvar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	client, err := NewClinvar(writeTestClinvar(t, dir, vcfText, submissionRows))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestAssembly(t *testing.T) {
+	tests := []struct {
+		reference string
+		expected  string
+	}{
+		{"GRCh37", AssemblyGRCh37},
+		{"GRCh38", AssemblyGRCh38},
+		{"file:///ref/hg38.fa", AssemblyGRCh38},
+		{"hg19", AssemblyGRCh37},
+		{"human_g1k_v37_b37.fasta", AssemblyGRCh37},
+		{"", ""},
+	}
+	for _, test := range tests {
+		header := vcf.NewHeader()
+		if test.reference != "" {
+			header.MetaLines = append(header.MetaLines, "##reference="+test.reference)
+		}
+		if actual := Assembly(header); actual != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.reference, test.expected, actual)
+		}
+	}
+}
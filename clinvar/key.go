@@ -0,0 +1,104 @@
+package clinvar
+
+import (
+	"math"
+
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+// A variant's chromosome, position and alleles packed into two words, which makes a much smaller map
+// key than ToClinvarKey's string. The alleles are hashed, so a variant found by its key still has to
+// be checked against the REF and ALT
+type variantKey struct {
+	locus   uint64
+	alleles uint64
+}
+
+// Looks up ClinVar variants by their variantKey. Variants that can't be keyed, or whose key is
+// already taken by different alleles, go in overflow under their ToClinvarKey
+type variantIndex struct {
+	// ID for each chromosome as ClinVar writes it, chr1 and 1 are different contigs like they are
+	// for ToClinvarKey
+	contigs  map[string]uint32
+	variants map[variantKey]*vcf.VcfLine
+	overflow map[string]*vcf.VcfLine
+}
+
+func buildVariantIndex(lines []*vcf.VcfLine) *variantIndex {
+	index := &variantIndex{
+		contigs:  make(map[string]uint32),
+		variants: make(map[variantKey]*vcf.VcfLine, len(lines)),
+		overflow: make(map[string]*vcf.VcfLine),
+	}
+	for _, line := range lines {
+		if _, ok := index.contigs[line.Chrom]; !ok {
+			index.contigs[line.Chrom] = uint32(len(index.contigs))
+		}
+		key, ok := index.key(line)
+		if !ok {
+			index.overflow[ToClinvarKey(line)] = line
+			continue
+		}
+		// Like a map of ToClinvarKey, a repeated variant replaces the earlier one
+		if existing, taken := index.variants[key]; taken && !identicalVariant(existing, line) {
+			index.overflow[ToClinvarKey(line)] = line
+			continue
+		}
+		index.variants[key] = line
+	}
+	return index
+}
+
+func (index *variantIndex) key(line *vcf.VcfLine) (variantKey, bool) {
+	contig, ok := index.contigs[line.Chrom]
+	if !ok || line.Pos < 0 || uint64(line.Pos) > math.MaxUint32 {
+		return variantKey{}, false
+	}
+	return variantKey{
+		locus:   uint64(contig)<<32 | uint64(line.Pos),
+		alleles: hashAlleles(line.Ref, line.Alt),
+	}, true
+}
+
+// The ClinVar variant with the same chromosome, position, REF and ALT as the line
+func (index *variantIndex) find(line *vcf.VcfLine) (*vcf.VcfLine, bool) {
+	if key, ok := index.key(line); ok {
+		if variant, found := index.variants[key]; found && identicalVariant(variant, line) {
+			return variant, true
+		}
+	} else if _, known := index.contigs[line.Chrom]; !known {
+		return nil, false
+	}
+	// Formatting the string key is the slow part, so it's only done when there's an overflow
+	if len(index.overflow) == 0 {
+		return nil, false
+	}
+	variant, ok := index.overflow[ToClinvarKey(line)]
+	return variant, ok
+}
+
+func (index *variantIndex) len() int {
+	return len(index.variants) + len(index.overflow)
+}
+
+// Exactly what ToClinvarKey compares, unlike sameVariant
+func identicalVariant(a *vcf.VcfLine, b *vcf.VcfLine) bool {
+	return a.Chrom == b.Chrom && a.Pos == b.Pos && a.Ref == b.Ref && a.Alt == b.Alt
+}
+
+// 64 bit FNV-1a of the REF and ALT, with a separator so A/CG and AC/G differ
+func hashAlleles(ref string, alt string) uint64 {
+	const (
+		offset = 14695981039346656037
+		prime  = 1099511628211
+	)
+	hash := uint64(offset)
+	for i := 0; i < len(ref); i++ {
+		hash = (hash ^ uint64(ref[i])) * prime
+	}
+	hash = (hash ^ '/') * prime
+	for i := 0; i < len(alt); i++ {
+		hash = (hash ^ uint64(alt[i])) * prime
+	}
+	return hash
+}
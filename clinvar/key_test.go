@@ -0,0 +1,115 @@
+package clinvar
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+// Repeats a variant, puts several alleles at one position and has a chr prefixed contig, which the
+// compact index has to treat exactly like a map of ToClinvarKey did
+const keyVcf = `##fileformat=VCFv4.1
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO
+1	1000	100	A	G	.	.	CLNSIG=Pathogenic
+1	1000	101	A	C	.	.	CLNSIG=Benign
+1	1000	102	A	AC	.	.	CLNSIG=Likely_pathogenic
+1	1000	103	AC	A	.	.	CLNSIG=Uncertain_significance
+1	2000	104	G	T	.	.	CLNSIG=Pathogenic
+1	2000	105	G	T	.	.	CLNSIG=Likely_benign
+chr2	3000	106	T	C	.	.	CLNSIG=Pathogenic
+X	4294967296	107	C	G	.	.	CLNSIG=Pathogenic
+MT	300	108	T	C	.	.	CLNSIG=Pathogenic
+`
+
+const keySubmissions = `100	Pathogenic	Jan 01, 2019	-	Disease A	C0000:Disease A	criteria provided, single submitter	clinical testing	germline:1	Lab 1	SCV000000001.1	GENEA	-
+100	Likely pathogenic	Jan 02, 2019	-	Disease A	C0000:Disease A	criteria provided, single submitter	clinical testing	germline:1	Lab 2	SCV000000002.1	GENEA	-
+101	Benign	-	-	-	-	no assertion criteria provided	literature only	germline:1	Lab 1	SCV000000003.1	GENEA	-
+102	Likely pathogenic	-	-	-	C0001:Disease B	criteria provided, single submitter	clinical testing	germline:1	Lab 3	SCV000000004.1	GENEA	-
+103	Uncertain significance	-	-	-	-	criteria provided, single submitter	clinical testing	germline:1	Lab 3	SCV000000005.1	GENEA	-
+104	Pathogenic	-	-	-	-	criteria provided, single submitter	clinical testing	germline:1	Lab 1	SCV000000006.1	GENEB	-
+105	Likely benign	-	-	-	-	criteria provided, single submitter	clinical testing	germline:1	Lab 2	SCV000000007.1	GENEB	-
+106	Pathogenic	-	-	-	-	criteria provided, single submitter	clinical testing	germline:1	Lab 1	SCV000000008.1	GENEC	-
+107	Pathogenic	-	-	-	-	criteria provided, single submitter	clinical testing	germline:1	Lab 1	SCV000000009.1	GENED	-
+
+999	Pathogenic	-	-	-	-	criteria provided, single submitter	clinical testing	germline:1	Lab 1	SCV000000010.1	GENEE	-
+`
+
+func TestCompactStoreMatchesKeyLookup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clinvar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	vcfPath, submissionPath := writeTestClinvar(t, dir, keyVcf, keySubmissions)
+	client, err := NewClinvar(vcfPath, submissionPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// How variants and submissions were found before they were stored compactly
+	lines, err := vcf.ReadVcf(vcfPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	variantsByKey := make(map[string]*vcf.VcfLine)
+	for _, line := range lines {
+		variantsByKey[ToClinvarKey(line)] = line
+	}
+	parsed, err := ParseSubmissionSummary(submissionPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assessmentsByID := GroupSubmissions(parsed)
+
+	if client.VariantCount() != len(variantsByKey) || client.SubmissionCount() != len(parsed) ||
+		client.SubmittedVariantCount() != len(assessmentsByID) {
+		t.Errorf("expected %d variants, %d submissions and %d submitted variants, got %d, %d and %d",
+			len(variantsByKey), len(parsed), len(assessmentsByID),
+			client.VariantCount(), client.SubmissionCount(), client.SubmittedVariantCount())
+	}
+
+	probes := append([]*vcf.VcfLine{}, lines...)
+	probes = append(probes,
+		&vcf.VcfLine{Chrom: "1", Pos: 1000, Ref: "A", Alt: "T"},
+		&vcf.VcfLine{Chrom: "1", Pos: 1001, Ref: "A", Alt: "G"},
+		&vcf.VcfLine{Chrom: "1", Pos: 1000, Ref: "a", Alt: "g"},
+		&vcf.VcfLine{Chrom: "1", Pos: 1000, Ref: "AC", Alt: "AC"},
+		&vcf.VcfLine{Chrom: "2", Pos: 3000, Ref: "T", Alt: "C"},
+		&vcf.VcfLine{Chrom: "chr1", Pos: 1000, Ref: "A", Alt: "G"},
+		&vcf.VcfLine{Chrom: "X", Pos: 0, Ref: "C", Alt: "G"},
+		&vcf.VcfLine{Chrom: "7", Pos: 1000, Ref: "A", Alt: "G"},
+	)
+	for _, probe := range probes {
+		key := ToClinvarKey(probe)
+		expected, expectedOk := variantsByKey[key]
+		variant, ok := client.FindVariant(probe)
+		if ok != expectedOk || (ok && (variant.ID != expected.ID || ToClinvarKey(variant) != key)) {
+			t.Errorf("%s: expected %v, got %v", key, expected, variant)
+			continue
+		}
+		record, ok := client.Lookup(probe)
+		if !expectedOk {
+			if ok {
+				t.Errorf("%s: didn't expect a record", key)
+			}
+			continue
+		}
+		submissions, submitted := assessmentsByID[expected.ID]
+		if ok != submitted || (ok && (record.Variant.ID != expected.ID || record.AssessmentCount != len(submissions))) {
+			t.Errorf("%s: expected %d submissions, got %+v", key, len(submissions), record)
+		}
+	}
+
+	for id, expected := range assessmentsByID {
+		submissions, ok := client.Submissions(id)
+		if !ok || !reflect.DeepEqual(submissions, expected) {
+			t.Errorf("%s: expected %+v, got %+v", id, expected, submissions)
+		}
+	}
+	if _, ok := client.Submissions("108"); ok {
+		t.Errorf("expected no submissions for 108")
+	}
+}
@@ -0,0 +1,97 @@
+package clinvar
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+const (
+	MatchMethodPosition = "position"
+	MatchMethodRsid     = "rsid"
+)
+
+// Optional ways to match a sample variant when the chrom:pos ref:alt key isn't in ClinVar
+type MatchOptions struct {
+	// Match on the sample's rsID when the alleles agree with ClinVar's
+	RsidFallback bool
+	// Try the opposite strand and swapped REF/ALT for SNVs, see LookupTransformed
+	AlleleTransforms bool
+	// Smallest reciprocal overlap for matching SVs like <DEL> against ClinVar, 0 turns it off
+	SVOverlap float64
+}
+
+func buildRsidIndex(lines []*vcf.VcfLine) map[string][]*vcf.VcfLine {
+	rsidIndex := make(map[string][]*vcf.VcfLine)
+	for _, line := range lines {
+		for _, rs := range splitIDs(line.GetInfo(RSIDKey)) {
+			rsid := fmt.Sprintf("rs%s", rs)
+			rsidIndex[rsid] = append(rsidIndex[rsid], line)
+		}
+	}
+	return rsidIndex
+}
+
+// Splits ID style fields that can hold more than one value, ignoring the . placeholder
+func splitIDs(ids string) []string {
+	split := make([]string, 0)
+	for _, id := range strings.FieldsFunc(ids, func(r rune) bool { return r == ';' || r == ',' || r == '|' }) {
+		if id != "." {
+			split = append(split, id)
+		}
+	}
+	return split
+}
+
+// Finds the ClinVar record for a sample variant, first on its position and alleles, then
+// falling back on the methods turned on in options. The record's MatchMethod says which one hit
+func (clinvar *ClinvarClient) Match(line *vcf.VcfLine, options MatchOptions) (*ClinvarRecord, bool) {
+	if record, ok := clinvar.Lookup(line); ok {
+		record.MatchMethod = MatchMethodPosition
+		return record, true
+	}
+	if options.SVOverlap > 0 && line.IsStructuralVariant() {
+		return clinvar.LookupStructural(line, options.SVOverlap)
+	}
+	if options.AlleleTransforms {
+		if record, ok := clinvar.LookupTransformed(line); ok {
+			return record, true
+		}
+	}
+	if options.RsidFallback {
+		if record, ok := clinvar.LookupRsid(line); ok {
+			return record, true
+		}
+	}
+	return nil, false
+}
+
+// Looks up the sample variant's rsIDs, only accepting ClinVar variants with the same REF and one of
+// the sample's ALT alleles, since an rsID can cover several different alleles at a site
+func (clinvar *ClinvarClient) LookupRsid(line *vcf.VcfLine) (*ClinvarRecord, bool) {
+	for _, rsid := range splitIDs(line.ID) {
+		for _, variant := range clinvar.VariantsByRsid[strings.ToLower(rsid)] {
+			if !allelesAgree(line, variant) {
+				continue
+			}
+			if record, ok := clinvar.LookupVariant(variant); ok {
+				record.MatchMethod = MatchMethodRsid
+				return record, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func allelesAgree(line *vcf.VcfLine, variant *vcf.VcfLine) bool {
+	if !strings.EqualFold(line.Ref, variant.Ref) {
+		return false
+	}
+	for _, alt := range strings.Split(line.Alt, ",") {
+		if strings.EqualFold(alt, variant.Alt) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package clinvar
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+// Last reference base a variant covers, the REF span starts at POS
+func variantEnd(line *vcf.VcfLine) int {
+	if len(line.Ref) == 0 {
+		return line.Pos
+	}
+	return line.Pos + len(line.Ref) - 1
+}
+
+// Returns the ClinVar variants whose REF span overlaps start to end, both inclusive
+func (clinvar *ClinvarClient) VariantsInRange(chrom string, start int, end int) []*vcf.VcfLine {
+	chromLines := clinvar.VariantsByChrom[vcf.NormalizeChrom(chrom)]
+	// A variant starting up to the longest REF before start can still reach into the range
+	first := sort.Search(len(chromLines), func(i int) bool {
+		return chromLines[i].Pos >= start-clinvar.longestRef
+	})
+	variants := make([]*vcf.VcfLine, 0)
+	for i := first; i < len(chromLines) && chromLines[i].Pos <= end; i++ {
+		if variantEnd(chromLines[i]) >= start {
+			variants = append(variants, chromLines[i])
+		}
+	}
+	return variants
+}
+
+// Returns the ClinVar variants within window bp of the sample variant's REF span, leaving out the
+// ones that are the same variant. These catch indels and MNPs that ClinVar represents differently
+func (clinvar *ClinvarClient) VariantsNear(line *vcf.VcfLine, window int) []*vcf.VcfLine {
+	nearby := make([]*vcf.VcfLine, 0)
+	for _, variant := range clinvar.VariantsInRange(line.Chrom, line.Pos-window, variantEnd(line)+window) {
+		if !sameVariant(line, variant) {
+			nearby = append(nearby, variant)
+		}
+	}
+	return nearby
+}
+
+func sameVariant(line *vcf.VcfLine, variant *vcf.VcfLine) bool {
+	return line.Pos == variant.Pos && allelesAgree(line, variant)
+}
+
+// Number of bases between two variants' REF spans, 0 when they overlap
+func Distance(a *vcf.VcfLine, b *vcf.VcfLine) int {
+	if !strings.EqualFold(vcf.NormalizeChrom(a.Chrom), vcf.NormalizeChrom(b.Chrom)) {
+		return -1
+	}
+	switch {
+	case variantEnd(a) < b.Pos:
+		return b.Pos - variantEnd(a)
+	case variantEnd(b) < a.Pos:
+		return a.Pos - variantEnd(b)
+	default:
+		return 0
+	}
+}
+
+func longestRef(lines []*vcf.VcfLine) int {
+	longest := 0
+	for _, line := range lines {
+		if len(line.Ref) > longest {
+			longest = len(line.Ref)
+		}
+	}
+	return longest
+}
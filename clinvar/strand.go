@@ -0,0 +1,120 @@
+package clinvar
+
+import (
+	"strings"
+
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+const (
+	MatchTransformSwap           = "swap"
+	MatchTransformStrandFlip     = "strand flip"
+	MatchTransformStrandFlipSwap = "strand flip and swap"
+)
+
+var complementBases = map[string]string{"A": "T", "T": "A", "C": "G", "G": "C"}
+
+type alleleTransform struct {
+	name string
+	ref  string
+	alt  string
+}
+
+func complement(base string) string {
+	return complementBases[strings.ToUpper(base)]
+}
+
+// A/T and C/G SNPs look the same on both strands, so there's no telling a strand flip from a swap
+func isAmbiguousSnp(ref string, alt string) bool {
+	return complement(ref) != "" && complement(ref) == strings.ToUpper(alt)
+}
+
+// Array data is sometimes reported on the opposite strand or with REF and ALT the wrong way round.
+// Only biallelic SNVs are transformed, and only when the sample's REF disagrees with the reference
+// base that ClinVar has at the position, which the transformed REF has to agree with
+func (clinvar *ClinvarClient) LookupTransformed(line *vcf.VcfLine) (*ClinvarRecord, bool) {
+	if len(line.Ref) != 1 || len(line.Alt) != 1 || complement(line.Ref) == "" || complement(line.Alt) == "" {
+		return nil, false
+	}
+	if isAmbiguousSnp(line.Ref, line.Alt) {
+		return nil, false
+	}
+	variants := clinvar.VariantsAt(line.Chrom, line.Pos)
+	if len(variants) == 0 || len(variants[0].Ref) == 0 {
+		return nil, false
+	}
+	reference := strings.ToUpper(variants[0].Ref[:1])
+	if strings.EqualFold(line.Ref, reference) {
+		return nil, false
+	}
+
+	transforms := []alleleTransform{
+		{MatchTransformSwap, strings.ToUpper(line.Alt), strings.ToUpper(line.Ref)},
+		{MatchTransformStrandFlip, complement(line.Ref), complement(line.Alt)},
+		{MatchTransformStrandFlipSwap, complement(line.Alt), complement(line.Ref)},
+	}
+	for _, transform := range transforms {
+		if transform.ref != reference {
+			continue
+		}
+		for _, variant := range variants {
+			if !strings.EqualFold(variant.Ref, transform.ref) || !strings.EqualFold(variant.Alt, transform.alt) {
+				continue
+			}
+			if record, ok := clinvar.LookupVariant(variant); ok {
+				record.MatchMethod = MatchMethodPosition
+				record.MatchTransform = transform.name
+				return record, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Returns a copy of the sample line with its alleles as ClinVar has them. A swap also swaps the
+// 0 and 1 alleles in every sample's GT, so a 1/1 that was really the reference becomes 0/0
+func ApplyTransform(line *vcf.VcfLine, record *ClinvarRecord) *vcf.VcfLine {
+	if record.MatchTransform == "" {
+		return line
+	}
+	transformed := *line
+	transformed.Ref = record.Variant.Ref
+	transformed.Alt = record.Variant.Alt
+	if record.MatchTransform == MatchTransformStrandFlip {
+		return &transformed
+	}
+	transformed.Samples = make([]map[string]string, len(line.Samples))
+	for i, sample := range line.Samples {
+		transformed.Samples[i] = swapGenotype(sample)
+	}
+	transformed.SampleData = swapGenotype(line.SampleData)
+	transformed.Sample = ""
+	return &transformed
+}
+
+func swapGenotype(sampleData map[string]string) map[string]string {
+	swapped := make(map[string]string, len(sampleData))
+	for key, value := range sampleData {
+		swapped[key] = value
+	}
+	gt, ok := sampleData["GT"]
+	if !ok {
+		return swapped
+	}
+	swappedGT := make([]rune, 0, len(gt))
+	for _, r := range gt {
+		switch r {
+		case '0':
+			r = '1'
+		case '1':
+			r = '0'
+		}
+		swappedGT = append(swappedGT, r)
+	}
+	swapped["GT"] = string(swappedGT)
+	// Keep the reference allele first in unphased genotypes like variant callers do
+	if swapped["GT"] == "1/0" {
+		swapped["GT"] = "0/1"
+	}
+	return swapped
+}
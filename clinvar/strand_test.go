@@ -0,0 +1,121 @@
+package clinvar
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+const strandVcf = `##fileformat=VCFv4.1
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO
+1	1000	100	A	G	.	.	CLNSIG=Pathogenic
+1	2000	101	A	T	.	.	CLNSIG=Pathogenic
+1	3000	102	C	G	.	.	CLNSIG=Pathogenic
+1	4000	103	C	T	.	.	CLNSIG=Pathogenic
+1	5000	104	AT	A	.	.	CLNSIG=Pathogenic
+`
+
+const strandSubmissions = `100	Pathogenic	-	-	-	-	-	-	-	Lab	SCV1	GENEA	-
+101	Pathogenic	-	-	-	-	-	-	-	Lab	SCV2	GENEA	-
+102	Pathogenic	-	-	-	-	-	-	-	Lab	SCV3	GENEA	-
+103	Pathogenic	-	-	-	-	-	-	-	Lab	SCV4	GENEA	-
+104	Pathogenic	-	-	-	-	-	-	-	Lab	SCV5	GENEA	-
+`
+
+func TestLookupTransformed(t *testing.T) {
+	client := loadTestClinvar(t, strandVcf, strandSubmissions)
+	tests := []struct {
+		name      string
+		pos       int
+		ref       string
+		alt       string
+		id        string
+		transform string
+	}{
+		{"swap", 1000, "G", "A", "100", MatchTransformSwap},
+		{"strand flip", 1000, "T", "C", "100", MatchTransformStrandFlip},
+		{"strand flip and swap", 1000, "C", "T", "100", MatchTransformStrandFlipSwap},
+		{"lower case", 4000, "g", "a", "103", MatchTransformStrandFlip},
+		{"already matches", 1000, "A", "G", "", ""},
+		{"other alt", 1000, "A", "C", "", ""},
+		// A/T and C/G read the same on both strands, so they're never transformed
+		{"A/T", 2000, "T", "A", "", ""},
+		{"C/G", 3000, "G", "C", "", ""},
+		{"indel", 5000, "A", "AT", "", ""},
+		{"nothing at the position", 6000, "G", "A", "", ""},
+		{"not a base", 1000, "N", "A", "", ""},
+	}
+	for _, test := range tests {
+		line := &vcf.VcfLine{Chrom: "1", Pos: test.pos, Ref: test.ref, Alt: test.alt}
+		record, ok := client.LookupTransformed(line)
+		if test.id == "" {
+			if ok {
+				t.Errorf("%s: didn't expect a match, got %s", test.name, record.Variant.ID)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%s: expected to match %s", test.name, test.id)
+			continue
+		}
+		if record.Variant.ID != test.id || record.MatchTransform != test.transform || record.MatchMethod != MatchMethodPosition {
+			t.Errorf("%s: expected %s by %s, got %s by %s", test.name, test.id, test.transform, record.Variant.ID, record.MatchTransform)
+		}
+	}
+}
+
+func TestMatchOnlyTransformsWhenAsked(t *testing.T) {
+	client := loadTestClinvar(t, strandVcf, strandSubmissions)
+	line := &vcf.VcfLine{Chrom: "1", Pos: 1000, Ref: "G", Alt: "A"}
+	if _, ok := client.Match(line, MatchOptions{}); ok {
+		t.Errorf("expected no match without allele transforms")
+	}
+	if record, ok := client.Match(line, MatchOptions{AlleleTransforms: true}); !ok || record.MatchTransform != MatchTransformSwap {
+		t.Errorf("expected a swap match with allele transforms")
+	}
+}
+
+func TestApplyTransform(t *testing.T) {
+	variant := &vcf.VcfLine{Chrom: "1", Pos: 1000, ID: "100", Ref: "A", Alt: "G"}
+	tests := []struct {
+		transform string
+		gts       []string
+		expected  []string
+	}{
+		{"", []string{"0/1"}, []string{"0/1"}},
+		{MatchTransformStrandFlip, []string{"1/1", "0/1"}, []string{"1/1", "0/1"}},
+		{MatchTransformSwap, []string{"1/1", "0/1", "0/0", "0|1", "./."}, []string{"0/0", "0/1", "1/1", "1|0", "./."}},
+		{MatchTransformStrandFlipSwap, []string{"1/1", "1"}, []string{"0/0", "0"}},
+	}
+	for _, test := range tests {
+		samples := make([]map[string]string, len(test.gts))
+		for i, gt := range test.gts {
+			samples[i] = map[string]string{"GT": gt, "DP": "20"}
+		}
+		line := &vcf.VcfLine{Chrom: "1", Pos: 1000, Ref: "G", Alt: "A", SampleData: samples[0], Samples: samples}
+		transformed := ApplyTransform(line, &ClinvarRecord{Variant: variant, MatchTransform: test.transform})
+		if test.transform == "" {
+			if transformed != line {
+				t.Errorf("expected the same line back without a transform")
+			}
+			continue
+		}
+		if transformed.Ref != "A" || transformed.Alt != "G" {
+			t.Errorf("%s: expected ClinVar's alleles, got %s>%s", test.transform, transformed.Ref, transformed.Alt)
+		}
+		gts := make([]string, 0, len(transformed.Samples))
+		for _, sample := range transformed.Samples {
+			gts = append(gts, sample["GT"])
+			if sample["DP"] != "20" {
+				t.Errorf("%s: expected the other fields kept", test.transform)
+			}
+		}
+		if !reflect.DeepEqual(gts, test.expected) || transformed.GetSampleData("GT") != test.expected[0] {
+			t.Errorf("%s: expected %v, got %v", test.transform, test.expected, gts)
+		}
+		if line.Ref != "G" || line.Samples[0]["GT"] != test.gts[0] {
+			t.Errorf("%s: expected the sample's line left alone", test.transform)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package clinvar
+
+import (
+	"bufio"
+	"strings"
+
+	"github.com/kazmiekr/clinvar-matcher/progress"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+	log "github.com/sirupsen/logrus"
+)
+
+// Columns in the submission summary, VariationID through ExplanationOfInterpretation
+const submissionColumns = 13
+
+// Keeps one copy of each of the many strings that repeat across submissions, like submitters,
+// review statuses and diseases. Values are copied in, since they're usually cut from a much longer
+// line that they'd otherwise keep alive
+type stringTable struct {
+	strings []string
+	ids     map[string]uint32
+}
+
+func newStringTable() *stringTable {
+	return &stringTable{strings: make([]string, 0), ids: make(map[string]uint32)}
+}
+
+func (table *stringTable) intern(value string) uint32 {
+	if id, ok := table.ids[value]; ok {
+		return id
+	}
+	value = string([]byte(value))
+	id := uint32(len(table.strings))
+	table.strings = append(table.strings, value)
+	table.ids[value] = id
+	return id
+}
+
+// A submission summary row as the ids of its columns in the string table, a fraction of the size
+// of a ClinvarSubmission
+type storedSubmission [submissionColumns]uint32
+
+// Every submission, grouped by variation ID in the order they're in the file
+type submissionStore struct {
+	strings []string
+	byID    map[string][]storedSubmission
+	total   int
+}
+
+func loadSubmissionStore(filePath string, tracker *progress.Tracker) (*submissionStore, error) {
+	table := newStringTable()
+	rows := make([]storedSubmission, 0)
+	err := readSubmissionSummary(filePath, tracker, func(parts []string) {
+		var row storedSubmission
+		for i := range row {
+			row[i] = table.intern(parts[i])
+		}
+		rows = append(rows, row)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Each variant's submissions are carved out of one array rather than grown one at a time
+	counts := make(map[uint32]int)
+	for _, row := range rows {
+		counts[row[0]]++
+	}
+	grouped := make([]storedSubmission, len(rows))
+	byID := make(map[string][]storedSubmission, len(counts))
+	offsets := make(map[uint32]int, len(counts))
+	next := 0
+	for _, row := range rows {
+		offset, ok := offsets[row[0]]
+		if !ok {
+			offset = next
+			byID[table.strings[row[0]]] = grouped[next : next+counts[row[0]]]
+			next += counts[row[0]]
+		}
+		grouped[offset] = row
+		offsets[row[0]] = offset + 1
+	}
+	return &submissionStore{strings: table.strings, byID: byID, total: len(rows)}, nil
+}
+
+// Builds the ClinvarSubmissions for a variation ID, new ones on every call
+func (store *submissionStore) get(variationID string) ([]*ClinvarSubmission, bool) {
+	rows, ok := store.byID[variationID]
+	if !ok {
+		return nil, false
+	}
+	submissions := make([]*ClinvarSubmission, len(rows))
+	parts := make([]string, submissionColumns)
+	for i, row := range rows {
+		for column, id := range row {
+			parts[column] = store.strings[id]
+		}
+		submissions[i] = newSubmission(parts)
+	}
+	return submissions, true
+}
+
+// Calls add with the columns of each row, skipping comments and short rows
+func readSubmissionSummary(filePath string, tracker *progress.Tracker, add func(parts []string)) error {
+	log.Infof("Loading clinvar submission summary from %s", filePath)
+	reader, err := vcf.OpenTracked(filePath, tracker)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		parts := strings.Split(line, "\t")
+		if len(parts) < submissionColumns {
+			continue
+		}
+		add(parts)
+		if err := tracker.Records(1); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return tracker.Done()
+}
+
+func newSubmission(parts []string) *ClinvarSubmission {
+	reportedPhenotype := parts[5]
+	return &ClinvarSubmission{
+		VariationID:                 parts[0],
+		ClinicalSignificance:        parts[1],
+		Pathogenicity:               getPathogencityFromClinsig(parts[1]),
+		Disease:                     getDiseaseFromPhenotype(reportedPhenotype),
+		DateLastEvaluated:           parts[2],
+		Description:                 parts[3],
+		SubmittedPhenotypeInfo:      parts[4],
+		ReportedPhenotypeInfo:       reportedPhenotype,
+		ReviewStatus:                parts[6],
+		CollectionMethod:            parts[7],
+		OriginCounts:                parts[8],
+		Submitter:                   parts[9],
+		SCV:                         parts[10],
+		SubmittedGeneSymbol:         parts[11],
+		ExplanationOfInterpretation: parts[12],
+	}
+}
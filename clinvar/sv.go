@@ -0,0 +1,164 @@
+package clinvar
+
+import (
+	"strings"
+
+	"github.com/kazmiekr/clinvar-matcher/interval"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+const MatchMethodOverlap = "reciprocal overlap"
+
+// Half of each SV has to overlap the other, a common cutoff for calling two CNVs the same
+const DefaultSVOverlap = 0.5
+
+// Smallest ClinVar variant with explicit alleles indexed as an SV, the usual cutoff between indels
+// and SVs. Anything smaller is left to exact matching
+const MinSVLength = 50
+
+// ClinVar writes SVs with explicit alleles, so their type comes from CLNVC
+var clinvarSVTypes = map[string]string{
+	"deletion":            vcf.SVTypeDel,
+	"copy number loss":    vcf.SVTypeDel,
+	"duplication":         vcf.SVTypeDup,
+	"tandem duplication":  vcf.SVTypeDup,
+	"copy number gain":    vcf.SVTypeDup,
+	"inversion":           vcf.SVTypeInv,
+	"insertion":           vcf.SVTypeIns,
+	"copy number variant": vcf.SVTypeCNV,
+}
+
+// A ClinVar variant along with its SV type and span, the value in the SV interval trees
+type structuralEntry struct {
+	variant *vcf.VcfLine
+	sv      *vcf.StructuralVariant
+}
+
+// Works out the SV type and span of a ClinVar variant, either from SV INFO fields or from CLNVC
+// and the explicit alleles. Deletions and duplications are anchored on the base before them
+func ClinvarStructuralVariant(line *vcf.VcfLine) (*vcf.StructuralVariant, bool) {
+	if sv, ok := line.StructuralVariant(); ok {
+		return sv, true
+	}
+	svType := clinvarSVTypes[strings.ToLower(strings.Replace(line.GetInfo(VariantClassificationKey), "_", " ", -1))]
+	if svType == "" {
+		return nil, false
+	}
+	start := line.Pos
+	if len(line.Ref) > 0 && len(line.Alt) > 0 && strings.EqualFold(line.Ref[:1], line.Alt[:1]) {
+		start++
+	}
+	length := len(line.Ref) - (start - line.Pos)
+	if svType == vcf.SVTypeDup || svType == vcf.SVTypeIns {
+		length = len(line.Alt) - len(line.Ref)
+	}
+	if length < 1 {
+		length = 1
+	}
+	return &vcf.StructuralVariant{Type: svType, Start: start, End: start + length - 1, Length: length}, true
+}
+
+func buildStructuralIndex(lines []*vcf.VcfLine) map[string]*interval.Tree {
+	intervals := make(map[string][]interval.Interval)
+	for _, line := range lines {
+		sv, ok := ClinvarStructuralVariant(line)
+		if !ok || (!line.IsStructuralVariant() && sv.Length < MinSVLength) {
+			continue
+		}
+		chrom := vcf.NormalizeChrom(line.Chrom)
+		intervals[chrom] = append(intervals[chrom], interval.Interval{
+			Start: sv.Start,
+			End:   sv.End,
+			Value: &structuralEntry{variant: line, sv: sv},
+		})
+	}
+	trees := make(map[string]*interval.Tree)
+	for chrom, chromIntervals := range intervals {
+		trees[chrom] = interval.NewTree(chromIntervals)
+	}
+	return trees
+}
+
+// Copy number calls don't say which way they went, so they can match either a loss or a gain
+func compatibleSVTypes(sample string, clinvar string) bool {
+	if sample == clinvar {
+		return true
+	}
+	switch {
+	case sample == vcf.SVTypeCNV:
+		return clinvar == vcf.SVTypeDel || clinvar == vcf.SVTypeDup
+	case clinvar == vcf.SVTypeCNV:
+		return sample == vcf.SVTypeDel || sample == vcf.SVTypeDup
+	}
+	return false
+}
+
+// Fraction of the smaller of the two SVs covered by their overlap, which is how much each covers of
+// the other at worst. The sample's start is moved within its CIPOS towards the ClinVar start first
+func ReciprocalOverlap(sample *vcf.StructuralVariant, other *vcf.StructuralVariant) float64 {
+	start := sample.Start
+	switch {
+	case other.Start < sample.Start+sample.CIPos[0]:
+		start = sample.Start + sample.CIPos[0]
+	case other.Start > sample.Start+sample.CIPos[1]:
+		start = sample.Start + sample.CIPos[1]
+	default:
+		start = other.Start
+	}
+	if start > sample.End {
+		start = sample.End
+	}
+	overlapStart, overlapEnd := start, sample.End
+	if other.Start > overlapStart {
+		overlapStart = other.Start
+	}
+	if other.End < overlapEnd {
+		overlapEnd = other.End
+	}
+	if overlapEnd < overlapStart {
+		return 0
+	}
+	overlap := float64(overlapEnd - overlapStart + 1)
+	sampleFraction := overlap / float64(sample.End-start+1)
+	otherFraction := overlap / float64(other.Span())
+	if sampleFraction < otherFraction {
+		return sampleFraction
+	}
+	return otherFraction
+}
+
+// Finds the ClinVar SV of a compatible type with the highest reciprocal overlap with the sample's
+// SV, as long as it's at least minOverlap. Insertions and breakends have no span to overlap
+func (clinvar *ClinvarClient) LookupStructural(line *vcf.VcfLine, minOverlap float64) (*ClinvarRecord, bool) {
+	sv, ok := line.StructuralVariant()
+	if !ok || sv.Type == vcf.SVTypeIns || sv.Type == vcf.SVTypeBnd {
+		return nil, false
+	}
+	tree, ok := clinvar.StructuralVariants[vcf.NormalizeChrom(line.Chrom)]
+	if !ok {
+		return nil, false
+	}
+	var best *structuralEntry
+	bestOverlap := 0.0
+	for _, found := range tree.Overlapping(sv.Start+sv.CIPos[0], sv.End) {
+		entry := found.Value.(*structuralEntry)
+		if !compatibleSVTypes(sv.Type, entry.sv.Type) {
+			continue
+		}
+		overlap := ReciprocalOverlap(sv, entry.sv)
+		if overlap >= minOverlap && overlap > bestOverlap {
+			best = entry
+			bestOverlap = overlap
+		}
+	}
+	if best == nil {
+		return nil, false
+	}
+	record, ok := clinvar.LookupVariant(best.variant)
+	if !ok {
+		return nil, false
+	}
+	record.MatchMethod = MatchMethodOverlap
+	record.ReciprocalOverlap = bestOverlap
+	return record, true
+}
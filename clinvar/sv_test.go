@@ -0,0 +1,53 @@
+package clinvar
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+func TestBuildStructuralIndex(t *testing.T) {
+	lines := []*vcf.VcfLine{
+		{Chrom: "1", Pos: 100, Ref: "AT", Alt: "A", InfoText: "CLNVC=Deletion"},
+		{Chrom: "1", Pos: 200, Ref: "A" + strings.Repeat("T", 49), Alt: "A", InfoText: "CLNVC=Deletion"},
+		{Chrom: "1", Pos: 300, Ref: "A" + strings.Repeat("T", 50), Alt: "A", InfoText: "CLNVC=Deletion"},
+		{Chrom: "1", Pos: 400, Ref: "A", Alt: "A" + strings.Repeat("G", 60), InfoText: "CLNVC=Duplication"},
+		{Chrom: "1", Pos: 500, Ref: "A", Alt: "<DEL>", InfoText: "SVTYPE=DEL;END=520"},
+		{Chrom: "1", Pos: 600, Ref: "A", Alt: "G", InfoText: "CLNVC=single_nucleotide_variant"},
+	}
+	trees := buildStructuralIndex(lines)
+	indexed := make([]int, 0)
+	for _, found := range trees["1"].Overlapping(1, 1000) {
+		indexed = append(indexed, found.Value.(*structuralEntry).variant.Pos)
+	}
+	expected := map[int]bool{300: true, 400: true, 500: true}
+	if len(indexed) != len(expected) {
+		t.Fatalf("expected %d SVs indexed, got %v", len(expected), indexed)
+	}
+	for _, pos := range indexed {
+		if !expected[pos] {
+			t.Errorf("didn't expect the variant at %d to be indexed", pos)
+		}
+	}
+}
+
+func TestReciprocalOverlap(t *testing.T) {
+	tests := []struct {
+		name     string
+		sample   vcf.StructuralVariant
+		other    vcf.StructuralVariant
+		expected float64
+	}{
+		{"identical", vcf.StructuralVariant{Start: 101, End: 200}, vcf.StructuralVariant{Start: 101, End: 200}, 1},
+		{"half", vcf.StructuralVariant{Start: 101, End: 200}, vcf.StructuralVariant{Start: 151, End: 250}, 0.5},
+		{"contained", vcf.StructuralVariant{Start: 101, End: 200}, vcf.StructuralVariant{Start: 126, End: 150}, 0.25},
+		{"apart", vcf.StructuralVariant{Start: 101, End: 200}, vcf.StructuralVariant{Start: 301, End: 400}, 0},
+		{"within CIPOS", vcf.StructuralVariant{Start: 101, End: 200, CIPos: [2]int{-10, 10}}, vcf.StructuralVariant{Start: 111, End: 200}, 1},
+	}
+	for _, test := range tests {
+		if actual := ReciprocalOverlap(&test.sample, &test.other); actual != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kazmiekr/clinvar-matcher/bench"
+	"github.com/spf13/cobra"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	benchmarkDir             string
+	benchmarkClinvarVariants int
+	benchmarkSampleVariants  int
+	benchmarkMatchFraction   float64
+	benchmarkThreads         []int
+)
+
+func init() {
+	benchmarkCmd.Flags().StringVar(&benchmarkDir, "dir", "", "Directory for the synthetic data, kept between runs and regenerated when empty, defaults to a temporary directory that's removed")
+	benchmarkCmd.Flags().IntVar(&benchmarkClinvarVariants, "clinvar-variants", bench.DefaultClinvarVariants, "ClinVar variants to generate")
+	benchmarkCmd.Flags().IntVar(&benchmarkSampleVariants, "sample-variants", bench.DefaultSampleVariants, "Sample variants to generate")
+	benchmarkCmd.Flags().Float64Var(&benchmarkMatchFraction, "match-fraction", 0.01, "Share of the sample variants that are in ClinVar")
+	benchmarkCmd.Flags().IntSliceVar(&benchmarkThreads, "threads", defaultBenchmarkThreads(), "Comma separated numbers of goroutines to parse and match with")
+	rootCmd.AddCommand(benchmarkCmd)
+}
+
+// 1, 2, 4 and so on up to every CPU
+func defaultBenchmarkThreads() []int {
+	threads := []int{}
+	for n := 1; n < runtime.NumCPU(); n *= 2 {
+		threads = append(threads, n)
+	}
+	return append(threads, runtime.NumCPU())
+}
+
+var benchmarkCmd = &cobra.Command{
+	Use:   "benchmark",
+	Short: "Time parsing and matching a synthetic whole genome",
+	Long: `Generate a synthetic ClinVar release and a whole genome sized sample VCF, then time parsing and
+matching the sample with each number of --threads, checking the report comes out the same for all of
+them. For working on performance, so it's left out of the help.`,
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := benchmarkDir
+		if dir == "" {
+			tempDir, err := ioutil.TempDir("", "clinvar-matcher-benchmark")
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(tempDir)
+			dir = tempDir
+		}
+		dataset, err := benchmarkDataset(dir)
+		if err != nil {
+			return err
+		}
+
+		client, memory, err := bench.LoadClinvar(dataset)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("ClinVar: %d variants and %d submissions loaded in %v, holding %s, %.0f bytes a variant\n\n",
+			memory.Variants, memory.Submissions, memory.Load.Round(time.Millisecond), megabytes(memory.Heap), memory.BytesPerVariant())
+
+		results, err := bench.Run(dataset, client, benchmarkThreads)
+		if err != nil {
+			return err
+		}
+		table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(table, "Threads\tVariants\tHeap\tParse\tMatch\tTotal\tVariants/s\tSpeedup")
+		for _, result := range results {
+			fmt.Fprintf(table, "%d\t%d\t%s\t%v\t%v\t%v\t%.0f\t%.2fx\n", result.Workers, result.Variants, megabytes(result.Heap),
+				result.Parse.Round(time.Millisecond), result.Match.Round(time.Millisecond), result.Total().Round(time.Millisecond),
+				result.Throughput(), results[0].Total().Seconds()/result.Total().Seconds())
+		}
+		return table.Flush()
+	},
+}
+
+func megabytes(bytes uint64) string {
+	return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+}
+
+// Reuses the data in --dir when it's there, since generating a genome's worth takes a while
+func benchmarkDataset(dir string) (*bench.Dataset, error) {
+	config := bench.Config{
+		Dir:             dir,
+		ClinvarVariants: benchmarkClinvarVariants,
+		SampleVariants:  benchmarkSampleVariants,
+		MatchFraction:   benchmarkMatchFraction,
+		Seed:            1,
+	}
+	if dataset, ok := bench.Existing(dir); ok {
+		log.Infof("Using the synthetic data in %s", dir)
+		return dataset, nil
+	}
+	log.Infof("Generating %d ClinVar and %d sample variants in %s", config.ClinvarVariants, config.SampleVariants, dir)
+	return bench.Generate(config)
+}
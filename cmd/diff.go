@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kazmiekr/clinvar-matcher/matcher"
+	"github.com/kazmiekr/clinvar-matcher/release"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+	"github.com/spf13/cobra"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	diffOldVcfFile     string
+	diffOldSubmissions string
+	diffNewVcfFile     string
+	diffNewSubmissions string
+	diffOutputFile     string
+	diffFormat         string
+	diffChanges        []string
+	diffKeepDownloads  bool
+)
+
+func init() {
+	diffCmd.Flags().StringVar(&diffOldVcfFile, "old-vcf", "", "ClinVar vcf file for the older release")
+	diffCmd.Flags().StringVar(&diffOldSubmissions, "old-submissions", "", "ClinVar submission summary file for the older release")
+	diffCmd.Flags().StringVar(&diffNewVcfFile, "new-vcf", LatestClinvarVCFUrl, "ClinVar vcf file for the newer release, only used with --old-vcf")
+	diffCmd.Flags().StringVar(&diffNewSubmissions, "new-submissions", LatestClinvarSubmissionSummaryUrl, "ClinVar submission summary file for the newer release, only used with --old-submissions")
+	diffCmd.Flags().StringVarP(&diffOutputFile, "output-file", "o", vcf.StdioPath, "Output file to write, or - for standard output")
+	diffCmd.Flags().StringVar(&diffFormat, "format", release.FormatCSV, fmt.Sprintf("Output format, one of %s", strings.Join(release.Formats, ", ")))
+	diffCmd.Flags().StringSliceVar(&diffChanges, "changes", release.ChangeKinds, "Comma separated changes to report, any of "+strings.Join(release.ChangeKinds, ", "))
+	diffCmd.Flags().BoolVarP(&diffKeepDownloads, "keep-downloads", "k", false, "Keep the ClinVar downloaded files when complete, will be deleted by default")
+	rootCmd.AddCommand(diffCmd)
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Report the variants that changed between two ClinVar releases",
+	Long: `Compare two ClinVar releases and report the variants that were added, removed, reclassified
+(like VUS->LP), or whose star rating or submissions changed. Give the older release with --old-vcf,
+--old-submissions or both, the newer release defaults to the latest one. Classifications and stars
+come from the VCFs and submissions from the submission summaries.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if diffFormat != release.FormatCSV && diffFormat != release.FormatJSON {
+			return fmt.Errorf("unknown format %s, use one of %s", diffFormat, strings.Join(release.Formats, ", "))
+		}
+		if err := release.ValidateChanges(diffChanges); err != nil {
+			return err
+		}
+		if diffOldVcfFile == "" && diffOldSubmissions == "" {
+			return fmt.Errorf("give the older release with --old-vcf, --old-submissions or both")
+		}
+		// Only the parts given for the older release are compared, so the rest isn't downloaded
+		newVcfFile, newSubmissions := diffNewVcfFile, diffNewSubmissions
+		if diffOldVcfFile == "" {
+			newVcfFile = ""
+		}
+		if diffOldSubmissions == "" {
+			newSubmissions = ""
+		}
+
+		// Downloading the newer release over one of the older release's files would compare it with itself
+		for _, newPath := range []string{newVcfFile, newSubmissions} {
+			download, ok := matcher.DownloadPath(newPath)
+			if !ok {
+				continue
+			}
+			for _, oldPath := range []string{diffOldVcfFile, diffOldSubmissions} {
+				if oldDownload, ok := matcher.DownloadPath(oldPath); ok {
+					oldPath = oldDownload
+				}
+				if oldPath != "" && sameFile(download, oldPath) {
+					return fmt.Errorf("downloading %s would overwrite the older release's %s, rename or move it first", newPath, oldPath)
+				}
+			}
+		}
+
+		// The older release is loaded before the newer one is downloaded
+		oldVcfFile, oldSubmissions, oldDownloads, err := matcher.FetchClinvar(diffOldVcfFile, diffOldSubmissions)
+		if err != nil {
+			return err
+		}
+		older, err := release.Load(oldVcfFile, oldSubmissions)
+		if err != nil {
+			return err
+		}
+		newVcfFile, newSubmissions, newDownloads, err := matcher.FetchClinvar(newVcfFile, newSubmissions)
+		if err != nil {
+			return err
+		}
+		newer, err := release.Load(newVcfFile, newSubmissions)
+		if err != nil {
+			return err
+		}
+		if !diffKeepDownloads {
+			for _, f := range append(oldDownloads, newDownloads...) {
+				if err := os.Remove(f); err != nil {
+					return err
+				}
+				log.Infof("Deleted downloaded file %s\n", f)
+			}
+		}
+
+		changes, err := release.Compare(older, newer, diffChanges)
+		if err != nil {
+			return err
+		}
+		log.Infof("%d variants changed between ClinVar %s and %s", len(changes), orUnknown(older.Date), orUnknown(newer.Date))
+
+		output, err := matcher.CreateOutput(diffOutputFile)
+		if err != nil {
+			return err
+		}
+		defer output.Close()
+		if diffFormat == release.FormatJSON {
+			return release.WriteJSON(output, changes)
+		}
+		return release.WriteCSV(output, changes)
+	},
+}
+
+func orUnknown(date string) string {
+	if date == "" {
+		return "unknown release"
+	}
+	return date
+}
+
+// Whether both paths are the same file, by name when neither exists yet
+func sameFile(a string, b string) bool {
+	aInfo, aErr := os.Stat(a)
+	bInfo, bErr := os.Stat(b)
+	if aErr == nil && bErr == nil {
+		return os.SameFile(aInfo, bInfo)
+	}
+	aAbs, aErr := filepath.Abs(a)
+	bAbs, bErr := filepath.Abs(b)
+	return aErr == nil && bErr == nil && aAbs == bAbs
+}
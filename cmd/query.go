@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kazmiekr/clinvar-matcher/lookup"
+	"github.com/kazmiekr/clinvar-matcher/matcher"
+	"github.com/spf13/cobra"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	queryClinvarVcfFile     string
+	queryClinvarSubmissions string
+	queryFormat             string
+	queryKeepDownloads      bool
+)
+
+func init() {
+	queryCmd.Flags().StringVarP(&queryClinvarVcfFile, "clinvar-vcf", "c", LatestClinvarVCFUrl, "ClinVar vcf file, leave blank to download latest")
+	queryCmd.Flags().StringVarP(&queryClinvarSubmissions, "clinvar-submissions", "s", LatestClinvarSubmissionSummaryUrl, "ClinVar submission summary file, leave blank to download latest")
+	queryCmd.Flags().StringVar(&queryFormat, "format", lookup.FormatTable, fmt.Sprintf("Output format, one of %s", strings.Join(lookup.Formats, ", ")))
+	queryCmd.Flags().BoolVarP(&queryKeepDownloads, "keep-downloads", "k", false, "Keep the ClinVar downloaded files when complete, will be deleted by default")
+	rootCmd.AddCommand(queryCmd)
+}
+
+var queryCmd = &cobra.Command{
+	Use:   "query [key...]",
+	Short: "Look up what ClinVar says about positions, rsIDs, HGVS, variation IDs or genes",
+	Long: `Look up what ClinVar says about variants without writing a VCF. Each key can be a position with
+optional alleles (17:41245466 G>A, 17-41245466-G-A or 17:41245466), an rsID (rs80357906), genomic
+HGVS (NC_000017.10:g.41245466G>A), a ClinVar variation ID (55555 or VCV000055555) or a gene symbol
+(BRCA1). Quote keys with spaces in them.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if queryFormat != lookup.FormatTable && queryFormat != lookup.FormatJSON {
+			return fmt.Errorf("unknown format %s, use one of %s", queryFormat, strings.Join(lookup.Formats, ", "))
+		}
+		// Check every key before spending time loading ClinVar
+		keys := make([]*lookup.Key, 0, len(args))
+		for _, arg := range args {
+			key, err := lookup.ParseKey(arg)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, key)
+		}
+
+		clinvarFile, submissionFile, downloads, err := matcher.FetchClinvar(queryClinvarVcfFile, queryClinvarSubmissions)
+		if err != nil {
+			return err
+		}
+		client, err := lookup.Load(clinvarFile, submissionFile, keys)
+		if err != nil {
+			return err
+		}
+		if !queryKeepDownloads {
+			for _, f := range downloads {
+				if err := os.Remove(f); err != nil {
+					return err
+				}
+				log.Infof("Deleted downloaded file %s\n", f)
+			}
+		}
+
+		results := make([]*lookup.QueryResult, 0, len(keys))
+		for _, key := range keys {
+			results = append(results, lookup.Query(client, key))
+		}
+		if queryFormat == lookup.FormatJSON {
+			return lookup.WriteJSON(os.Stdout, results)
+		}
+		return lookup.WriteTable(os.Stdout, results)
+	},
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/matcher"
+	"github.com/kazmiekr/clinvar-matcher/release"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+	"github.com/spf13/cobra"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	reanalyzeClinvarVcfFile     string
+	reanalyzeClinvarSubmissions string
+	reanalyzeOutputFile         string
+	reanalyzeFormat             string
+	reanalyzeKeepDownloads      bool
+)
+
+func init() {
+	reanalyzeCmd.Flags().StringVarP(&reanalyzeClinvarVcfFile, "clinvar-vcf", "c", LatestClinvarVCFUrl, "ClinVar vcf file, leave blank to download latest")
+	reanalyzeCmd.Flags().StringVarP(&reanalyzeClinvarSubmissions, "clinvar-submissions", "s", LatestClinvarSubmissionSummaryUrl, "ClinVar submission summary file, leave blank to download latest")
+	reanalyzeCmd.Flags().StringVarP(&reanalyzeOutputFile, "output-file", "o", vcf.StdioPath, "Output file to write, or - for standard output")
+	reanalyzeCmd.Flags().StringVar(&reanalyzeFormat, "format", release.FormatCSV, fmt.Sprintf("Output format, one of %s", strings.Join(release.Formats, ", ")))
+	reanalyzeCmd.Flags().BoolVarP(&reanalyzeKeepDownloads, "keep-downloads", "k", false, "Keep the ClinVar downloaded files when complete, will be deleted by default")
+	rootCmd.AddCommand(reanalyzeCmd)
+}
+
+var reanalyzeCmd = &cobra.Command{
+	Use:   "reanalyze [reportFile]",
+	Short: "Check an earlier report against a newer ClinVar release",
+	Long: `Look up the variants from an earlier clinvar-matcher report, CSV or JSON, in a newer ClinVar
+release and write the ones whose classification, submission counts or diseases changed, or that were
+removed from ClinVar, with the old and new values side by side.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if reanalyzeFormat != release.FormatCSV && reanalyzeFormat != release.FormatJSON {
+			return fmt.Errorf("unknown format %s, use one of %s", reanalyzeFormat, strings.Join(release.Formats, ", "))
+		}
+		// Read the report first, so a bad one doesn't wait on loading ClinVar
+		report, err := release.ReadReport(args[0])
+		if err != nil {
+			return err
+		}
+
+		clinvarFile, submissionFile, downloads, err := matcher.FetchClinvar(reanalyzeClinvarVcfFile, reanalyzeClinvarSubmissions)
+		if err != nil {
+			return err
+		}
+		client, err := clinvar.NewClinvar(clinvarFile, submissionFile)
+		if err != nil {
+			return err
+		}
+		if !reanalyzeKeepDownloads {
+			for _, f := range downloads {
+				if err := os.Remove(f); err != nil {
+					return err
+				}
+				log.Infof("Deleted downloaded file %s\n", f)
+			}
+		}
+
+		reanalysis, err := release.Reanalyze(client, report)
+		if err != nil {
+			return err
+		}
+		log.Infof("%d of %d variants changed in ClinVar %s", len(reanalysis.Variants), len(report.Rows), orUnknown(client.ReleaseDate))
+
+		output, err := matcher.CreateOutput(reanalyzeOutputFile)
+		if err != nil {
+			return err
+		}
+		defer output.Close()
+		if reanalyzeFormat == release.FormatJSON {
+			return reanalysis.WriteJSON(output)
+		}
+		return reanalysis.WriteCSV(output)
+	},
+}
@@ -3,8 +3,11 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
 	"github.com/kazmiekr/clinvar-matcher/matcher"
+	"github.com/kazmiekr/clinvar-matcher/panel"
 	"github.com/spf13/cobra"
 )
 
@@ -19,30 +22,110 @@ var (
 	outputFile               string
 	includeAllVariants       bool
 	saveDownloads            bool
+	columns                  []string
+	filterExpression         string
+	genes                    []string
+	regionsFile              string
+	reportMode               string
+	sfVersion                string
+	sampleSex                string
+	pedFile                  string
+	rsidFallback             bool
+	alleleTransforms         bool
+	nearbyWindow             int
+	svOverlap                float64
+	minDepth                 int
+	minGenotypeQuality       int
+	clinvarLoading           string
+	manifestFile             string
+	outputDir                string
+	batchJobs                int
+	threads                  int
 )
 
 func init() {
-	rootCmd.Flags().StringVarP(&outputFile, "output-file", "o", "clinvar_assessments.csv", "Output file to write")
+	rootCmd.Flags().StringVarP(&outputFile, "output-file", "o", "clinvar_assessments.csv", "Output file to write, or - for standard output")
 	rootCmd.Flags().StringVarP(&clinvarVcfFile, "clinvar-vcf", "c", LatestClinvarVCFUrl, "ClinVar vcf file, leave blank to download latest")
 	rootCmd.Flags().BoolVarP(&includeAllVariants, "include-all", "a", false, "Include low quality, non passing variants. Will use PASSing variants by default")
 	rootCmd.Flags().BoolVarP(&saveDownloads, "keep-downloads", "k", false, "Keep the ClinVar downloaded files when complete, will be deleted by default")
+	rootCmd.Flags().StringSliceVar(&columns, "columns", nil, "Comma separated report columns in output order, e.g. chromosome,begin,ref,alt,sample.FORMAT.DP,clinvar.INFO.CLNHGVS. Uses the standard report columns by default")
+	rootCmd.Flags().StringVarP(&filterExpression, "filter", "f", "", "Only report matches passing this filter, e.g. 'pathogenicity >= LP && stars >= 2 && gene in (\"BRCA1\",\"BRCA2\") && sample.DP > 20'")
+	rootCmd.Flags().StringSliceVarP(&genes, "genes", "g", nil, "Only report matches in these genes, either comma separated gene symbols or a file with one gene per line")
+	rootCmd.Flags().StringVarP(&regionsFile, "regions", "r", "", "Only report variants overlapping the regions in this BED file, can be gzipped")
+	rootCmd.Flags().StringVarP(&reportMode, "mode", "m", matcher.ModeAssessment, fmt.Sprintf("Report to generate, one of %s", strings.Join(matcher.ReportModes, ", ")))
+	rootCmd.Flags().StringVar(&sfVersion, "sf-version", panel.LatestSecondaryFindingsVersion, fmt.Sprintf("ACMG secondary findings gene list version for secondary-findings mode, one of %s", strings.Join(panel.SecondaryFindingsVersions(), ", ")))
+	rootCmd.Flags().StringVar(&sampleSex, "sex", matcher.SexAuto, "Sample sex for carrier-screening mode, male, female or auto to infer it from the X chromosome")
+	rootCmd.Flags().StringVar(&pedFile, "ped", "", "PED file describing the families in a multi-sample VCF, for trio mode")
+	rootCmd.Flags().BoolVar(&rsidFallback, "rsid-fallback", false, "When a variant's position and alleles aren't in ClinVar, match on its rsID if the alleles agree")
+	rootCmd.Flags().BoolVar(&alleleTransforms, "allele-transforms", false, "When a SNV isn't in ClinVar, try it on the opposite strand and with REF/ALT swapped, skipping A/T and C/G SNPs")
+	rootCmd.Flags().IntVar(&nearbyWindow, "nearby", 0, "Also write the ClinVar variants within this many bp of each sample variant, but not the same variant, to a _nearby.csv next to the output file")
+	rootCmd.Flags().Float64Var(&svOverlap, "sv-overlap", clinvar.DefaultSVOverlap, "Smallest reciprocal overlap, from 0 to 1, for matching SVs like <DEL> and <DUP> against ClinVar deletions and duplications, 0 turns it off")
+	rootCmd.Flags().IntVar(&minDepth, "min-dp", matcher.DefaultMinDepth, "Smallest depth for a reference call to count in coverage mode, uses MIN_DP for gVCF reference blocks")
+	rootCmd.Flags().IntVar(&minGenotypeQuality, "min-gq", matcher.DefaultMinGenotypeQuality, "Smallest GQ for a reference call to count in coverage mode")
+	rootCmd.Flags().StringVar(&clinvarLoading, "clinvar-loading", matcher.LoadingAuto, fmt.Sprintf("How to read the ClinVar VCF, one of %s. Indexed needs a bgzipped ClinVar VCF with a .tbi or .csi and only reads the regions the sample needs", strings.Join(matcher.ClinvarLoadings, ", ")))
+	rootCmd.Flags().StringVar(&manifestFile, "manifest", "", "File listing the inputs to process, one per line")
+	rootCmd.Flags().StringVar(&outputDir, "output-dir", matcher.DefaultBatchOutputDir, "Directory for each sample's report and the cohort summary, when processing several inputs")
+	rootCmd.Flags().IntVarP(&batchJobs, "jobs", "j", 0, "Samples to process at once, when processing several inputs, 0 uses every CPU")
+	rootCmd.Flags().IntVar(&threads, "threads", 0, "Goroutines parsing and matching each sample, 0 uses every CPU, or 1 per sample when processing several inputs")
 	rootCmd.Flags().StringVarP(&clinvarSubmissionSummary, "clinvar-submissions", "s", LatestClinvarSubmissionSummaryUrl, "ClinVar submission summary file, leave blank to download latest")
 }
 
 var rootCmd = &cobra.Command{
-	Use:           "clinvar-matcher [vcfFile]",
+	Use:           "clinvar-matcher [vcfFile...]",
 	Short:         "clinvar-matcher is a tool to match your vcf with the latest ClinVar",
 	SilenceUsage:  true,
 	SilenceErrors: true,
-	Args:          cobra.MinimumNArgs(1),
+	Long: `Clinvar Matcher is a tool to match your vcf with the latest ClinVar. Pass several VCFs, globs
+like 'samples/*.vcf.gz' or a --manifest to load ClinVar once and write a report for each of them to
+--output-dir, along with a cohort summary.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 && manifestFile == "" {
+			return fmt.Errorf("requires a vcf file, or a --manifest listing them")
+		}
+		return nil
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
+		inputs, err := matcher.ExpandInputs(args, manifestFile)
+		if err != nil {
+			return err
+		}
 		reportConfig := matcher.ReportConfig{
-			SourceVcfPath:         args[0],
-			ClinvarVcfPath:        clinvarVcfFile,
-			ClinvarSubmissionPath: clinvarSubmissionSummary,
-			IncludeAllVariants:    includeAllVariants,
-			OutputFile:            outputFile,
-			SaveDownloads:         saveDownloads,
+			SourceVcfPath:            inputs[0],
+			ClinvarVcfPath:           clinvarVcfFile,
+			ClinvarSubmissionPath:    clinvarSubmissionSummary,
+			IncludeAllVariants:       includeAllVariants,
+			OutputFile:               outputFile,
+			SaveDownloads:            saveDownloads,
+			Columns:                  columns,
+			Filter:                   filterExpression,
+			Genes:                    genes,
+			RegionsPath:              regionsFile,
+			Mode:                     reportMode,
+			SecondaryFindingsVersion: sfVersion,
+			Sex:                      sampleSex,
+			PedPath:                  pedFile,
+			RsidFallback:             rsidFallback,
+			AlleleTransforms:         alleleTransforms,
+			NearbyWindow:             nearbyWindow,
+			Workers:                  threads,
+			SVOverlap:                svOverlap,
+			MinDepth:                 minDepth,
+			MinGenotypeQuality:       minGenotypeQuality,
+			ClinvarLoading:           clinvarLoading,
+		}
+		batch := len(inputs) > 1 || manifestFile != ""
+		if batch && reportMode == matcher.ModeCohort {
+			return matcher.GenerateCohortReport(reportConfig, matcher.BatchConfig{
+				Inputs: inputs,
+				Jobs:   batchJobs,
+			})
+		}
+		if batch {
+			return matcher.GenerateBatchReports(reportConfig, matcher.BatchConfig{
+				Inputs:    inputs,
+				OutputDir: outputDir,
+				Jobs:      batchJobs,
+			})
 		}
 		return matcher.GenerateAssessmentReport(reportConfig)
 	},
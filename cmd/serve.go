@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/matcher"
+	"github.com/kazmiekr/clinvar-matcher/server"
+	"github.com/spf13/cobra"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	serveAddr                 string
+	serveClinvarVcfFile       string
+	serveClinvarSubmissions   string
+	serveMaxUploadMB          int64
+	serveMaxConcurrentReports int
+	serveMaxResults           int
+	serveRequestTimeout       time.Duration
+	serveReloadEvery          time.Duration
+	serveAdminToken           string
+	serveKeepDownloads        bool
+)
+
+// Admin token from the environment, so it doesn't show up in the process list
+const adminTokenEnv = "CLINVAR_MATCHER_ADMIN_TOKEN"
+
+func init() {
+	serveCmd.Flags().StringVar(&serveAddr, "addr", server.DefaultAddr, "Address to listen on")
+	serveCmd.Flags().StringVarP(&serveClinvarVcfFile, "clinvar-vcf", "c", LatestClinvarVCFUrl, "ClinVar vcf file, leave blank to download latest")
+	serveCmd.Flags().StringVarP(&serveClinvarSubmissions, "clinvar-submissions", "s", LatestClinvarSubmissionSummaryUrl, "ClinVar submission summary file, leave blank to download latest")
+	serveCmd.Flags().Int64Var(&serveMaxUploadMB, "max-upload-mb", server.DefaultMaxUploadBytes>>20, "Largest VCF upload accepted for a report, in MB")
+	serveCmd.Flags().IntVar(&serveMaxConcurrentReports, "max-reports", server.DefaultMaxConcurrentReports, "Reports that can run at once, more are turned away with a 503")
+	serveCmd.Flags().IntVar(&serveMaxResults, "max-results", server.DefaultMaxResults, "Most variants a lookup returns")
+	serveCmd.Flags().DurationVar(&serveRequestTimeout, "request-timeout", server.DefaultRequestTimeout, "Time allowed to read a request and write its response")
+	serveCmd.Flags().DurationVar(&serveReloadEvery, "reload-every", 0, "Reload ClinVar on this schedule, like 168h for weekly releases, 0 turns it off. SIGHUP also reloads")
+	serveCmd.Flags().StringVar(&serveAdminToken, "admin-token", os.Getenv(adminTokenEnv), "Bearer token for POST /admin/reload, which is turned off without one. Defaults to $"+adminTokenEnv)
+	serveCmd.Flags().BoolVarP(&serveKeepDownloads, "keep-downloads", "k", false, "Keep the ClinVar downloaded files once they're loaded, will be deleted by default")
+	rootCmd.AddCommand(serveCmd)
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Load ClinVar once and serve variant lookups and reports over HTTP",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Downloads again on every reload when the files are URLs, which picks up the latest release
+		load := func() (*clinvar.ClinvarClient, error) {
+			clinvarFile, submissionFile, downloads, err := matcher.FetchClinvar(serveClinvarVcfFile, serveClinvarSubmissions)
+			if err != nil {
+				return nil, err
+			}
+			client, err := clinvar.NewClinvar(clinvarFile, submissionFile)
+			if err != nil {
+				return nil, err
+			}
+			if !serveKeepDownloads {
+				for _, f := range downloads {
+					if err := os.Remove(f); err != nil {
+						return nil, err
+					}
+					log.Infof("Deleted downloaded file %s\n", f)
+				}
+			}
+			return client, nil
+		}
+		client, err := load()
+		if err != nil {
+			return err
+		}
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+		defer signal.Stop(signals)
+		return server.New(server.Config{
+			Addr:                 serveAddr,
+			MaxUploadBytes:       serveMaxUploadMB << 20,
+			MaxConcurrentReports: serveMaxConcurrentReports,
+			MaxResults:           serveMaxResults,
+			RequestTimeout:       serveRequestTimeout,
+			Reload:               load,
+			ReloadEvery:          serveReloadEvery,
+			AdminToken:           serveAdminToken,
+		}, client).Run(signals)
+	},
+}
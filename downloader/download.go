@@ -1,6 +1,7 @@
 package downloader
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
@@ -14,7 +15,16 @@ import (
 )
 
 func DownloadFile(filepath string, url string, description string) error {
-	resp, err := http.Get(url)
+	return DownloadFileContext(context.Background(), filepath, url, description)
+}
+
+// Like DownloadFile, abandoning the download once the context is cancelled
+func DownloadFileContext(ctx context.Context, filepath string, url string, description string) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(request)
 	if err != nil {
 		return err
 	}
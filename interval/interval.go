@@ -0,0 +1,101 @@
+package interval
+
+import "sort"
+
+// A closed interval using 1-based coordinates like VCF, so Start and End are both included
+type Interval struct {
+	Start int
+	End   int
+	Value interface{}
+}
+
+func (interval Interval) Overlaps(start, end int) bool {
+	return interval.Start <= end && interval.End >= start
+}
+
+// Static augmented interval tree. The intervals are sorted by start and treated as an implicit
+// balanced binary tree, with each node tracking the largest end in its subtree so whole
+// branches can be skipped during a query
+type Tree struct {
+	intervals []Interval
+	maxEnd    []int
+}
+
+func NewTree(intervals []Interval) *Tree {
+	sorted := make([]Interval, len(intervals))
+	copy(sorted, intervals)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Start < sorted[j].Start
+	})
+	tree := &Tree{
+		intervals: sorted,
+		maxEnd:    make([]int, len(sorted)),
+	}
+	tree.buildMaxEnd(0, len(sorted))
+	return tree
+}
+
+func (tree *Tree) buildMaxEnd(lo, hi int) int {
+	if lo >= hi {
+		return 0
+	}
+	mid := (lo + hi) / 2
+	maxEnd := tree.intervals[mid].End
+	if left := tree.buildMaxEnd(lo, mid); left > maxEnd {
+		maxEnd = left
+	}
+	if right := tree.buildMaxEnd(mid+1, hi); right > maxEnd {
+		maxEnd = right
+	}
+	tree.maxEnd[mid] = maxEnd
+	return maxEnd
+}
+
+// Every interval in the tree, ordered by start
+func (tree *Tree) Intervals() []Interval {
+	return tree.intervals
+}
+
+func (tree *Tree) Len() int {
+	return len(tree.intervals)
+}
+
+// Returns every interval overlapping start to end, ordered by start
+func (tree *Tree) Overlapping(start, end int) []Interval {
+	found := make([]Interval, 0)
+	tree.query(0, len(tree.intervals), start, end, func(interval Interval) bool {
+		found = append(found, interval)
+		return true
+	})
+	return found
+}
+
+func (tree *Tree) Overlaps(start, end int) bool {
+	overlaps := false
+	tree.query(0, len(tree.intervals), start, end, func(interval Interval) bool {
+		overlaps = true
+		return false
+	})
+	return overlaps
+}
+
+// Walks the overlapping intervals in order, stopping early when visit returns false
+func (tree *Tree) query(lo, hi, start, end int, visit func(Interval) bool) bool {
+	if lo >= hi {
+		return true
+	}
+	mid := (lo + hi) / 2
+	if tree.maxEnd[mid] < start {
+		return true
+	}
+	if !tree.query(lo, mid, start, end, visit) {
+		return false
+	}
+	if tree.intervals[mid].Start > end {
+		return true
+	}
+	if tree.intervals[mid].End >= start && !visit(tree.intervals[mid]) {
+		return false
+	}
+	return tree.query(mid+1, hi, start, end, visit)
+}
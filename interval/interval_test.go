@@ -0,0 +1,91 @@
+package interval
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func values(intervals []Interval) []int {
+	found := make([]int, 0, len(intervals))
+	for _, interval := range intervals {
+		found = append(found, interval.Value.(int))
+	}
+	return found
+}
+
+func TestOverlapping(t *testing.T) {
+	tree := NewTree([]Interval{
+		{Start: 300, End: 400, Value: 3},
+		{Start: 100, End: 200, Value: 1},
+		{Start: 150, End: 1000, Value: 2},
+		{Start: 500, End: 500, Value: 4},
+		{Start: 100, End: 100, Value: 0},
+	})
+	tests := []struct {
+		start    int
+		end      int
+		expected []int
+	}{
+		{1, 99, []int{}},
+		{100, 100, []int{1, 0}},
+		{200, 200, []int{1, 2}},
+		{201, 299, []int{2}},
+		{400, 500, []int{2, 3, 4}},
+		{501, 1000, []int{2}},
+		{1001, 2000, []int{}},
+		{1, 5000, []int{1, 0, 2, 3, 4}},
+	}
+	for _, test := range tests {
+		actual := values(tree.Overlapping(test.start, test.end))
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%d-%d: expected %v, got %v", test.start, test.end, test.expected, actual)
+		}
+		if overlaps := tree.Overlaps(test.start, test.end); overlaps != (len(test.expected) > 0) {
+			t.Errorf("%d-%d: Overlaps returned %v", test.start, test.end, overlaps)
+		}
+	}
+	if tree.Len() != 5 || tree.Intervals()[0].Start != 100 || tree.Intervals()[4].Start != 500 {
+		t.Errorf("expected the intervals sorted by start, got %v", tree.Intervals())
+	}
+}
+
+func TestEmptyTree(t *testing.T) {
+	tree := NewTree(nil)
+	if len(tree.Overlapping(1, 100)) != 0 || tree.Overlaps(1, 100) || tree.Len() != 0 {
+		t.Errorf("expected nothing in an empty tree")
+	}
+}
+
+// Checks the tree against looking through every interval, for random intervals and queries
+func TestOverlappingMatchesScan(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	intervals := make([]Interval, 500)
+	for i := range intervals {
+		start := random.Intn(10000) + 1
+		intervals[i] = Interval{Start: start, End: start + random.Intn(300), Value: i}
+	}
+	tree := NewTree(intervals)
+	for i := 0; i < 1000; i++ {
+		start := random.Intn(10500)
+		end := start + random.Intn(200)
+		expected := make(map[int]bool)
+		for _, interval := range intervals {
+			if interval.Overlaps(start, end) {
+				expected[interval.Value.(int)] = true
+			}
+		}
+		found := tree.Overlapping(start, end)
+		if len(found) != len(expected) {
+			t.Fatalf("%d-%d: expected %d intervals, got %d", start, end, len(expected), len(found))
+		}
+		for j, interval := range found {
+			if !expected[interval.Value.(int)] {
+				t.Fatalf("%d-%d: didn't expect %v", start, end, interval)
+			}
+			if j > 0 && found[j-1].Start > interval.Start {
+				t.Fatalf("%d-%d: not ordered by start", start, end)
+			}
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package lookup
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+const (
+	KeyPosition    = "position"
+	KeyRsid        = "rsid"
+	KeyHgvs        = "hgvs"
+	KeyVariationID = "variation id"
+	KeyGene        = "gene"
+)
+
+var (
+	rsidPattern        = regexp.MustCompile(`(?i)^rs(\d+)$`)
+	variationIDPattern = regexp.MustCompile(`(?i)^(?:VCV0*)?(\d+)(?:\.\d+)?$`)
+	// 17:41245466, 17:41245466 G>A, chr17-41245466-G-A and 17:41245466:G:A
+	positionPattern = regexp.MustCompile(`(?i)^(?:chr)?([0-9]+|X|Y|M|MT)[:\-_ ](\d+)(?:[:\-_ ]?([ACGTN]+)(?:>|[:\-_/ ])([ACGTN]+|<[^>]+>))?$`)
+	hgvsPattern     = regexp.MustCompile(`(?i)^([^:\s]+):g\.(\S+)$`)
+	genePattern     = regexp.MustCompile(`^[A-Za-z0-9_-]*[A-Za-z][A-Za-z0-9_-]*$`)
+	// RefSeq chromosome accessions like NC_000017.10, 23 and 24 are X and Y, 12920 is the mitochondria
+	refSeqPattern = regexp.MustCompile(`(?i)^NC_0*(\d+)(?:\.(\d+))?$`)
+)
+
+// What to look up in ClinVar, parsed from text like 17:41245466 G>A, rs80357906,
+// NC_000017.10:g.41245466G>A, 17661 or BRCA1
+type Key struct {
+	Text string
+	// One of the Key constants
+	Kind  string
+	Chrom string
+	// Position keys can leave off the alleles to get every variant at the position
+	Pos int
+	Ref string
+	Alt string
+	// The rsID, variation ID, gene symbol or the part of an HGVS string after g.
+	Value string
+	// RefSeq accession of an HGVS key, like NC_000017.10, the version says which assembly it's on
+	Accession string
+}
+
+func ParseKey(text string) (*Key, error) {
+	text = strings.TrimSpace(text)
+	key := &Key{Text: text}
+	if match := rsidPattern.FindStringSubmatch(text); match != nil {
+		key.Kind, key.Value = KeyRsid, "rs"+match[1]
+		return key, nil
+	}
+	if match := variationIDPattern.FindStringSubmatch(text); match != nil {
+		key.Kind, key.Value = KeyVariationID, match[1]
+		return key, nil
+	}
+	if match := positionPattern.FindStringSubmatch(text); match != nil {
+		key.Kind = KeyPosition
+		key.Chrom = vcf.NormalizeChrom(match[1])
+		key.Pos, _ = strconv.Atoi(match[2])
+		key.Ref, key.Alt = strings.ToUpper(match[3]), strings.ToUpper(match[4])
+		return key, nil
+	}
+	if strings.Contains(text, ":c.") || strings.Contains(text, ":p.") || strings.Contains(text, ":n.") {
+		return nil, fmt.Errorf("%s: only genomic HGVS like NC_000017.10:g.41245466G>A can be looked up, ClinVar's VCF doesn't have transcript positions", text)
+	}
+	if match := hgvsPattern.FindStringSubmatch(text); match != nil {
+		key.Kind, key.Value = KeyHgvs, match[2]
+		key.Chrom = vcf.NormalizeChrom(match[1])
+		if accession := refSeqPattern.FindStringSubmatch(match[1]); accession != nil {
+			key.Chrom = refSeqChrom(accession[1])
+			key.Accession = strings.ToUpper(match[1])
+		}
+		return key, nil
+	}
+	if genePattern.MatchString(text) {
+		key.Kind, key.Value = KeyGene, strings.ToUpper(text)
+		return key, nil
+	}
+	return nil, fmt.Errorf("%s isn't a position, rsID, HGVS, variation ID or gene symbol", text)
+}
+
+func refSeqChrom(number string) string {
+	switch strings.TrimLeft(number, "0") {
+	case "23":
+		return "X"
+	case "24":
+		return "Y"
+	case "12920":
+		return "MT"
+	}
+	return strings.TrimLeft(number, "0")
+}
+
+// The region a position key covers, for loading just that part of an indexed ClinVar VCF
+func (key *Key) Region() (vcf.Region, bool) {
+	if key.Kind != KeyPosition {
+		return vcf.Region{}, false
+	}
+	return vcf.Region{Chrom: key.Chrom, Start: key.Pos, End: key.Pos}, true
+}
+
+// Finds the ClinVar variants the key refers to
+func Find(client *clinvar.ClinvarClient, key *Key) []*vcf.VcfLine {
+	switch key.Kind {
+	case KeyPosition:
+		variants := make([]*vcf.VcfLine, 0)
+		for _, variant := range client.VariantsAt(key.Chrom, key.Pos) {
+			if key.Ref != "" && !strings.EqualFold(variant.Ref, key.Ref) {
+				continue
+			}
+			if key.Alt != "" && !strings.EqualFold(variant.Alt, key.Alt) {
+				continue
+			}
+			variants = append(variants, variant)
+		}
+		return variants
+	case KeyRsid:
+		return client.VariantsByRsid[strings.ToLower(key.Value)]
+	case KeyVariationID:
+		return client.VariantsByID[key.Value]
+	case KeyGene:
+		return client.VariantsInGene(key.Value)
+	case KeyHgvs:
+		return findHgvs(client, key)
+	}
+	return nil
+}
+
+// The same numeric position means a different base on another assembly, so an HGVS key on a RefSeq
+// accession has to have the version ClinVar's CLNHGVS uses for the chromosome. Keys without a
+// version can't be checked
+func CheckAssembly(client *clinvar.ClinvarClient, key *Key) error {
+	if key.Kind != KeyHgvs || !strings.Contains(key.Accession, ".") {
+		return nil
+	}
+	accession := clinvarAccession(client, key.Chrom)
+	if accession == "" || strings.EqualFold(accession, key.Accession) {
+		return nil
+	}
+	return fmt.Errorf("%s is on a different assembly than the loaded ClinVar, which uses %s for chromosome %s",
+		key.Accession, accession, key.Chrom)
+}
+
+// RefSeq accession ClinVar's CLNHGVS uses for a chromosome, blank when it has none
+func clinvarAccession(client *clinvar.ClinvarClient, chrom string) string {
+	for _, variant := range client.VariantsByChrom[chrom] {
+		for _, hgvs := range strings.Split(variant.GetInfo(clinvar.HgvsKey), "|") {
+			if index := strings.Index(hgvs, ":g."); index >= 0 && refSeqPattern.MatchString(hgvs[:index]) {
+				return hgvs[:index]
+			}
+		}
+	}
+	return ""
+}
+
+// ClinVar's CLNHGVS holds the g. HGVS on the RefSeq accession, so the part after g. is compared on
+// the same chromosome once CheckAssembly has made sure the key is on the same assembly
+func findHgvs(client *clinvar.ClinvarClient, key *Key) []*vcf.VcfLine {
+	variants := make([]*vcf.VcfLine, 0)
+	for _, variant := range client.VariantsByChrom[key.Chrom] {
+		for _, hgvs := range strings.Split(variant.GetInfo(clinvar.HgvsKey), "|") {
+			if index := strings.Index(hgvs, ":g."); index >= 0 && strings.EqualFold(hgvs[index+3:], key.Value) {
+				variants = append(variants, variant)
+				break
+			}
+		}
+	}
+	return variants
+}
@@ -0,0 +1,77 @@
+package lookup
+
+import (
+	"testing"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected Key
+	}{
+		{"rs80357906", Key{Kind: KeyRsid, Value: "rs80357906"}},
+		{"VCV000055555.3", Key{Kind: KeyVariationID, Value: "55555"}},
+		{"17:41245466 G>A", Key{Kind: KeyPosition, Chrom: "17", Pos: 41245466, Ref: "G", Alt: "A"}},
+		{"chr17-41245466-g-a", Key{Kind: KeyPosition, Chrom: "17", Pos: 41245466, Ref: "G", Alt: "A"}},
+		{"X:100", Key{Kind: KeyPosition, Chrom: "X", Pos: 100}},
+		{"NC_000017.10:g.41245466G>A", Key{Kind: KeyHgvs, Chrom: "17", Value: "41245466G>A", Accession: "NC_000017.10"}},
+		{"nc_000023.11:g.100A>G", Key{Kind: KeyHgvs, Chrom: "X", Value: "100A>G", Accession: "NC_000023.11"}},
+		{"NC_012920.1:g.3243A>G", Key{Kind: KeyHgvs, Chrom: "MT", Value: "3243A>G", Accession: "NC_012920.1"}},
+		{"chr17:g.41245466G>A", Key{Kind: KeyHgvs, Chrom: "17", Value: "41245466G>A"}},
+		{"BRCA1", Key{Kind: KeyGene, Value: "BRCA1"}},
+	}
+	for _, test := range tests {
+		key, err := ParseKey(test.text)
+		if err != nil {
+			t.Errorf("%s: %v", test.text, err)
+			continue
+		}
+		test.expected.Text = test.text
+		if *key != test.expected {
+			t.Errorf("%s: expected %+v, got %+v", test.text, test.expected, *key)
+		}
+	}
+	for _, text := range []string{"NM_007294.3:c.5266dupC", "NP_009225.1:p.Gln1756fs", "not a key!"} {
+		if _, err := ParseKey(text); err == nil {
+			t.Errorf("expected %q not to parse", text)
+		}
+	}
+}
+
+func TestCheckAssembly(t *testing.T) {
+	client := &clinvar.ClinvarClient{
+		VariantsByChrom: map[string][]*vcf.VcfLine{
+			"17": {
+				{Chrom: "17", Pos: 100, Ref: "A", Alt: "G", InfoText: "CLNVC=single_nucleotide_variant"},
+				{Chrom: "17", Pos: 41245466, Ref: "G", Alt: "A", InfoText: "CLNHGVS=NC_000017.10:g.41245466G>A"},
+			},
+		},
+	}
+	tests := []struct {
+		text string
+		ok   bool
+	}{
+		{"NC_000017.10:g.41245466G>A", true},
+		{"NC_000017.11:g.41245466G>A", false},
+		{"NC_000017:g.41245466G>A", true},
+		{"chr17:g.41245466G>A", true},
+		{"NC_000001.11:g.100A>G", true},
+		{"17:41245466", true},
+	}
+	for _, test := range tests {
+		key, err := ParseKey(test.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := CheckAssembly(client, key); (err == nil) != test.ok {
+			t.Errorf("%s: expected ok %v, got %v", test.text, test.ok, err)
+		}
+		result := Query(client, key)
+		if (result.Warning == "") != test.ok {
+			t.Errorf("%s: unexpected warning %q", test.text, result.Warning)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package lookup
+
+import (
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+	log "github.com/sirupsen/logrus"
+)
+
+// Loads ClinVar for the keys. When every key is a position and the ClinVar VCF is bgzipped with an
+// index, only those positions are read, otherwise all of ClinVar is loaded
+func Load(clinvarPath string, submissionPath string, keys []*Key) (*clinvar.ClinvarClient, error) {
+	regions := make([]vcf.Region, 0, len(keys))
+	for _, key := range keys {
+		region, ok := key.Region()
+		if !ok {
+			return clinvar.NewClinvar(clinvarPath, submissionPath)
+		}
+		regions = append(regions, region)
+	}
+	if _, ok := vcf.FindIndex(clinvarPath); !ok {
+		return clinvar.NewClinvar(clinvarPath, submissionPath)
+	}
+	if bgzf, err := vcf.IsBgzf(clinvarPath); err != nil || !bgzf {
+		return clinvar.NewClinvar(clinvarPath, submissionPath)
+	}
+	log.Infof("Using the ClinVar index to load %d positions", len(regions))
+	return clinvar.NewClinvarForRegions(clinvarPath, submissionPath, regions)
+}
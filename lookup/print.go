@@ -0,0 +1,153 @@
+package lookup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+)
+
+var Formats = []string{FormatTable, FormatJSON}
+
+// The variants found for one key
+type QueryResult struct {
+	Query          string           `json:"query"`
+	Kind           string           `json:"kind"`
+	ClinvarRelease string           `json:"clinvar_release"`
+	Variants       []*VariantResult `json:"variants"`
+	// Why the key couldn't be looked up, like an HGVS key on a different assembly
+	Warning string `json:"warning,omitempty"`
+}
+
+// Looks up the key, listing every submission unless it's a gene, which would be far too long
+func Query(client *clinvar.ClinvarClient, key *Key) *QueryResult {
+	result := &QueryResult{
+		Query:          key.Text,
+		Kind:           key.Kind,
+		ClinvarRelease: client.ReleaseDate,
+		Variants:       make([]*VariantResult, 0),
+	}
+	if err := CheckAssembly(client, key); err != nil {
+		log.Warnf("Not looking up %s: %v", key.Text, err)
+		result.Warning = err.Error()
+		return result
+	}
+	for _, variant := range Find(client, key) {
+		result.Variants = append(result.Variants, NewVariantResult(client, variant, key.Kind != KeyGene))
+	}
+	return result
+}
+
+func WriteJSON(w io.Writer, results []*QueryResult) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(results)
+}
+
+// Writes each variant with its submissions, or for a gene, a line per variant
+func WriteTable(w io.Writer, results []*QueryResult) error {
+	for i, result := range results {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if result.Warning != "" {
+			fmt.Fprintf(w, "%s: %s\n", result.Query, result.Warning)
+			continue
+		}
+		if len(result.Variants) == 0 {
+			fmt.Fprintf(w, "%s: no ClinVar variants found\n", result.Query)
+			continue
+		}
+		if result.Kind == KeyGene {
+			fmt.Fprintf(w, "%s: %d ClinVar variants\n\n", result.Query, len(result.Variants))
+			writeVariantList(w, result.Variants)
+			continue
+		}
+		for j, variant := range result.Variants {
+			if j > 0 {
+				fmt.Fprintln(w)
+			}
+			writeVariant(w, result.Query, variant)
+		}
+	}
+	return nil
+}
+
+func writeVariantList(w io.Writer, variants []*VariantResult) {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "VARIANT\tVARIATION ID\tRSID\tTYPE\tSIGNIFICANCE\tSTARS\tSUBMISSIONS")
+	for _, variant := range variants {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%d\t%d\n", variantName(variant), variant.VariationID,
+			strings.Join(variant.Rsids, ","), variant.VariantType, variant.ClinicalSignificance, variant.Stars, variant.AssessmentCount)
+	}
+	table.Flush()
+}
+
+func writeVariant(w io.Writer, query string, variant *VariantResult) {
+	fmt.Fprintf(w, "%s (%s)\n", variantName(variant), query)
+	fields := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(fields, "  Variation ID\t%s\t%s\n", variant.VariationID, variant.ClinvarLink)
+	fmt.Fprintf(fields, "  rsID\t%s\n", orDash(strings.Join(variant.Rsids, ", ")))
+	fmt.Fprintf(fields, "  Genes\t%s\n", orDash(strings.Join(variant.Genes, ", ")))
+	fmt.Fprintf(fields, "  Type\t%s\n", variant.VariantType)
+	fmt.Fprintf(fields, "  Significance\t%s\n", variant.ClinicalSignificance)
+	fmt.Fprintf(fields, "  Review status\t%s (%d stars)\n", variant.ReviewStatus, variant.Stars)
+	fmt.Fprintf(fields, "  Submissions\t%s\n", submissionCounts(variant))
+	fmt.Fprintf(fields, "  Diseases\t%s\n", orDash(strings.Join(variant.Diseases, "; ")))
+	fields.Flush()
+	if len(variant.Submissions) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w)
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "  SUBMITTER\tSIGNIFICANCE\tLAST EVALUATED\tREVIEW STATUS\tCONDITION\tSCV")
+	for _, submission := range variant.Submissions {
+		fmt.Fprintf(table, "  %s\t%s\t%s\t%s\t%s\t%s\n", submission.Submitter, submission.ClinicalSignificance,
+			submission.DateLastEvaluated, submission.ReviewStatus, submission.Disease, submission.SCV)
+	}
+	table.Flush()
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func variantName(variant *VariantResult) string {
+	return fmt.Sprintf("%s:%d %s>%s", variant.Chromosome, variant.Position, variant.Ref, variant.Alt)
+}
+
+// Like 3: 2 Pathogenic, 1 Likely Pathogenic, from most to least pathogenic
+func submissionCounts(variant *VariantResult) string {
+	order := []clinvar.Pathogenicity{
+		clinvar.PathogenicityPathogenic,
+		clinvar.PathogenicityLikelyPathogenic,
+		clinvar.PathogenicityVUS,
+		clinvar.PathogenicityLikelyBenign,
+		clinvar.PathogenicityBenign,
+		clinvar.PathogenicityOther,
+	}
+	counts := make([]string, 0)
+	for _, pathogenicity := range order {
+		if count := variant.PathogenicityCounts[pathogenicity.ToString()]; count > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", count, pathogenicity.ToString()))
+		}
+	}
+	if len(counts) == 0 {
+		return fmt.Sprintf("%d", variant.AssessmentCount)
+	}
+	return fmt.Sprintf("%d: %s", variant.AssessmentCount, strings.Join(counts, ", "))
+}
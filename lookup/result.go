@@ -0,0 +1,97 @@
+package lookup
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/matcher"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+// A ClinVar variant with its submissions summarized, the JSON returned by lookups
+type VariantResult struct {
+	Chromosome           string             `json:"chromosome"`
+	Position             int                `json:"position"`
+	Ref                  string             `json:"ref"`
+	Alt                  string             `json:"alt"`
+	VariationID          string             `json:"variation_id"`
+	Rsids                []string           `json:"rsids"`
+	Genes                []string           `json:"genes"`
+	VariantType          string             `json:"var_type"`
+	ClinicalSignificance string             `json:"clinical_significance"`
+	Pathogenicity        string             `json:"pathogenicity"`
+	MaxPathogenicity     string             `json:"max_pathogenicity,omitempty"`
+	ReviewStatus         string             `json:"review_status"`
+	Stars                int                `json:"stars"`
+	AssessmentCount      int                `json:"assessment_count"`
+	PathogenicityCounts  map[string]int     `json:"pathogenicity_counts,omitempty"`
+	Diseases             []string           `json:"diseases"`
+	ClinvarLink          string             `json:"clinvar_link"`
+	Submissions          []SubmissionResult `json:"submissions,omitempty"`
+}
+
+type SubmissionResult struct {
+	Submitter            string `json:"submitter"`
+	SCV                  string `json:"scv"`
+	ClinicalSignificance string `json:"clinical_significance"`
+	DateLastEvaluated    string `json:"date_last_evaluated"`
+	ReviewStatus         string `json:"review_status"`
+	CollectionMethod     string `json:"collection_method"`
+	Disease              string `json:"disease"`
+	MedGenID             string `json:"medgen_id"`
+}
+
+// Summarizes a ClinVar variant and its submissions, only listing each submission when withSubmissions
+// is set since a well studied variant can have dozens
+func NewVariantResult(client *clinvar.ClinvarClient, variant *vcf.VcfLine, withSubmissions bool) *VariantResult {
+	result := &VariantResult{
+		Chromosome:           variant.Chrom,
+		Position:             variant.Pos,
+		Ref:                  variant.Ref,
+		Alt:                  variant.Alt,
+		VariationID:          variant.ID,
+		Rsids:                make([]string, 0),
+		Genes:                clinvar.GeneInfoSymbols(variant.GetInfo(clinvar.GeneInfoKey)),
+		VariantType:          strings.Replace(variant.GetInfo(clinvar.VariantClassificationKey), "_", " ", -1),
+		ClinicalSignificance: strings.Replace(variant.GetInfo(clinvar.SignficanceKey), "_", " ", -1),
+		Pathogenicity:        clinvar.VariantPathogenicity(variant).ToString(),
+		ReviewStatus:         strings.Replace(variant.GetInfo(clinvar.ReviewStatusKey), "_", " ", -1),
+		Stars:                clinvar.ReviewStars(variant.GetInfo(clinvar.ReviewStatusKey)),
+		Diseases:             make([]string, 0),
+		ClinvarLink:          fmt.Sprintf(matcher.ClinvarLinkPattern, variant.ID),
+	}
+	for _, rs := range strings.Split(variant.GetInfo(clinvar.RSIDKey), "|") {
+		if rs != "" {
+			result.Rsids = append(result.Rsids, "rs"+rs)
+		}
+	}
+
+	record, ok := client.LookupVariant(variant)
+	if !ok {
+		return result
+	}
+	result.Genes = record.GeneSymbols()
+	result.MaxPathogenicity = record.Pathogenicity.ToString()
+	result.AssessmentCount = record.AssessmentCount
+	result.Diseases = record.Diseases
+	result.PathogenicityCounts = make(map[string]int)
+	for pathogenicity, count := range record.PathogenicityCounts {
+		result.PathogenicityCounts[pathogenicity.ToString()] = count
+	}
+	if withSubmissions {
+		for _, assessment := range record.Assessments {
+			result.Submissions = append(result.Submissions, SubmissionResult{
+				Submitter:            assessment.Submitter,
+				SCV:                  assessment.SCV,
+				ClinicalSignificance: assessment.ClinicalSignificance,
+				DateLastEvaluated:    assessment.DateLastEvaluated,
+				ReviewStatus:         assessment.ReviewStatus,
+				CollectionMethod:     assessment.CollectionMethod,
+				Disease:              assessment.Disease.DiseaseName,
+				MedGenID:             assessment.Disease.MedGenID,
+			})
+		}
+	}
+	return result
+}
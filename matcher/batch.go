@@ -0,0 +1,325 @@
+package matcher
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/rawdata"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultBatchOutputDir = "clinvar_reports"
+	CohortSummaryFile     = "cohort_summary.csv"
+)
+
+// Extensions taken off an input's file name to name its report, in the order they're removed
+var sampleExtensions = []string{".gz", ".bgz", ".zip", ".vcf", ".bcf", ".txt", ".csv"}
+
+type BatchConfig struct {
+	Inputs []string
+	// Each sample's report is written here, named after the input, along with the cohort summary
+	OutputDir string
+	// Samples processed at once, defaults to the number of CPUs
+	Jobs int
+}
+
+// How one sample in a batch went, a row in the cohort summary
+type SampleSummary struct {
+	Sample     string
+	Input      string
+	OutputFile string
+	Variants   int
+	// Matches written to the sample's report, after the quality, gene, region and report filters and
+	// the report mode, like the carrier or trio findings. Coverage reports count the ClinVar sites the
+	// sample has the variant at
+	Matches             int
+	PathogenicityCounts map[clinvar.Pathogenicity]int
+	Err                 error
+}
+
+// Expands the inputs into files, reading a manifest with one input per line when it's given. Inputs
+// with *, ? or [ are globs, which have to match at least one file
+func ExpandInputs(args []string, manifestPath string) ([]string, error) {
+	patterns := append([]string{}, args...)
+	if manifestPath != "" {
+		lines, err := readManifest(manifestPath)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, lines...)
+	}
+	inputs := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		if !strings.ContainsAny(pattern, "*?[") {
+			inputs = append(inputs, pattern)
+			continue
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %s", pattern)
+		}
+		inputs = append(inputs, matches...)
+	}
+	return inputs, nil
+}
+
+// Skips blank lines and # comments, relative paths are relative to the manifest
+func readManifest(manifestPath string) ([]string, error) {
+	file, err := os.Open(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(filepath.Dir(manifestPath), line)
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// Sample name from the input's file name, like NA12878 for NA12878.vcf.gz
+func SampleName(input string) string {
+	name := filepath.Base(input)
+	for _, extension := range sampleExtensions {
+		if strings.HasSuffix(strings.ToLower(name), extension) && len(name) > len(extension) {
+			name = name[:len(name)-len(extension)]
+		}
+	}
+	return name
+}
+
+// Loads ClinVar once and writes a report for each input, several at a time, then a cohort summary.
+// A sample that fails doesn't stop the others, it's listed in the summary with its error
+func GenerateBatchReports(config ReportConfig, batch BatchConfig) error {
+	return GenerateBatchReportsContext(context.Background(), config, batch)
+}
+
+// Like GenerateBatchReports, stopping with the context's error once it's cancelled. Samples that
+// haven't started are skipped, no summary is written and the downloads are kept to be picked up again
+func GenerateBatchReportsContext(ctx context.Context, config ReportConfig, batch BatchConfig) error {
+	if err := validateConfig(config); err != nil {
+		return err
+	}
+	summaries, err := planBatch(config, batch)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(batch.OutputDir, 0755); err != nil {
+		return err
+	}
+
+	clinvarFile, clinvarSubmissionFile, downloads, err := FetchClinvarContext(ctx, config.ClinvarVcfPath, config.ClinvarSubmissionPath)
+	if err != nil {
+		return err
+	}
+	// The samples between them need most of ClinVar, so the index isn't used
+	if config.ClinvarLoading == LoadingIndexed {
+		log.Infof("Loading all of ClinVar, the index isn't used for a batch")
+	}
+	client, err := clinvar.NewClinvarContext(ctx, clinvarFile, clinvarSubmissionFile, nil)
+	if err != nil {
+		return err
+	}
+
+	jobs := batch.Jobs
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	log.Infof("Processing %d samples, %d at a time", len(summaries), jobs)
+	// The samples already run side by side, so each is matched on one goroutine unless asked otherwise
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	queue := make(chan *SampleSummary)
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for summary := range queue {
+				sampleConfig := config
+				sampleConfig.SourceVcfPath = summary.Input
+				sampleConfig.OutputFile = summary.OutputFile
+				summary.Err = writeSampleReport(ctx, sampleConfig, client, summary)
+				if summary.Err != nil {
+					log.Errorf("%s: %v", summary.Input, summary.Err)
+				}
+			}
+		}()
+	}
+	for _, summary := range summaries {
+		queue <- summary
+	}
+	close(queue)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	summaryPath := filepath.Join(batch.OutputDir, CohortSummaryFile)
+	if err := writeCohortSummary(summaryPath, summaries); err != nil {
+		return err
+	}
+	log.Infof("Wrote the cohort summary to %s", summaryPath)
+
+	if !config.SaveDownloads {
+		for _, f := range downloads {
+			if err := os.Remove(f); err != nil {
+				return err
+			}
+			log.Infof("Deleted downloaded file %s\n", f)
+		}
+	}
+
+	failed := 0
+	for _, summary := range summaries {
+		if summary.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d samples failed, see %s", failed, len(summaries), summaryPath)
+	}
+	return nil
+}
+
+// Names each sample's report after its input, using the output file's extension, and checks no two
+// inputs would write the same report
+func planBatch(config ReportConfig, batch BatchConfig) ([]*SampleSummary, error) {
+	extension := filepath.Ext(config.OutputFile)
+	if extension == "" {
+		extension = ".csv"
+	}
+	summaries := make([]*SampleSummary, 0, len(batch.Inputs))
+	outputs := make(map[string]string)
+	for _, input := range batch.Inputs {
+		if input == vcf.StdioPath {
+			return nil, fmt.Errorf("standard input can't be one of several inputs")
+		}
+		sample := SampleName(input)
+		outputFile := filepath.Join(batch.OutputDir, sample+extension)
+		if other, ok := outputs[outputFile]; ok {
+			return nil, fmt.Errorf("%s and %s would both write %s, rename one of them", other, input, outputFile)
+		}
+		outputs[outputFile] = input
+		summaries = append(summaries, &SampleSummary{Sample: sample, Input: input, OutputFile: outputFile})
+	}
+	return summaries, nil
+}
+
+// Like WriteAssessedVariants, but with ClinVar already loaded, filling in the summary as it goes
+func writeSampleReport(ctx context.Context, config ReportConfig, client *clinvar.ClinvarClient, summary *SampleSummary) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	selector, err := newVariantSelector(config)
+	if err != nil {
+		return err
+	}
+	selector.client = client
+	selector.ctx = ctx
+
+	header, variants, err := readSample(config.SourceVcfPath, client)
+	if err != nil {
+		return err
+	}
+	log.Infof("%s: %d variants", summary.Sample, len(variants))
+
+	summary.Variants = len(variants)
+	// The report counts the matches it writes, rather than matching everything twice
+	summary.PathogenicityCounts = make(map[clinvar.Pathogenicity]int)
+	selector.pathogenicityCounts = summary.PathogenicityCounts
+
+	resultFile, err := CreateOutput(config.OutputFile)
+	if err != nil {
+		return err
+	}
+	defer resultFile.Close()
+	err = writeReport(resultFile, config, selector, header, variants)
+	summary.Matches = selector.reportedMatches
+	if err != nil || config.NearbyWindow <= 0 {
+		return err
+	}
+	return writeNearbyReport(config, selector, variants)
+}
+
+// Reads a VCF, BCF or raw genotype file, with ClinVar already loaded for the raw genotypes
+func readSample(path string, client *clinvar.ClinvarClient) (*vcf.Header, []*vcf.VcfLine, error) {
+	input, err := vcf.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer input.Close()
+	source := bufio.NewReaderSize(input, rawdata.DetectBytes)
+	format, err := rawdata.DetectReaderFormat(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	if format == rawdata.FormatVcf {
+		return vcf.ReadVcfFrom(source)
+	}
+	variants, err := rawdata.ReadRawGenotypesFrom(source, format, client)
+	return vcf.NewHeader(), variants, err
+}
+
+// A row per sample in input order, with how many of its matches have each max pathogenicity
+func writeCohortSummary(summaryPath string, summaries []*SampleSummary) error {
+	file, err := os.Create(summaryPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	pathogenicities := []clinvar.Pathogenicity{
+		clinvar.PathogenicityPathogenic,
+		clinvar.PathogenicityLikelyPathogenic,
+		clinvar.PathogenicityVUS,
+		clinvar.PathogenicityLikelyBenign,
+		clinvar.PathogenicityBenign,
+		clinvar.PathogenicityOther,
+	}
+	writer := csv.NewWriter(file)
+	headers := []string{"Sample", "Input", "Report", "Status", "Variant Count", "Match Count"}
+	for _, pathogenicity := range pathogenicities {
+		headers = append(headers, "# "+pathogenicity.ToString())
+	}
+	writer.Write(append(headers, "Error"))
+	for _, summary := range summaries {
+		status, message := "ok", ""
+		if summary.Err != nil {
+			status, message = "failed", summary.Err.Error()
+		}
+		record := []string{summary.Sample, summary.Input, summary.OutputFile, status,
+			strconv.Itoa(summary.Variants), strconv.Itoa(summary.Matches)}
+		for _, pathogenicity := range pathogenicities {
+			record = append(record, strconv.Itoa(summary.PathogenicityCounts[pathogenicity]))
+		}
+		if err := writer.Write(append(record, message)); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
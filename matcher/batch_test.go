@@ -0,0 +1,89 @@
+package matcher
+
+import (
+	"context"
+	"encoding/csv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+)
+
+const batchClinvarVcf = `##fileformat=VCFv4.1
+##reference=GRCh37
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO
+1	1000	100	A	G	.	.	CLNSIG=Pathogenic;CLNREVSTAT=criteria_provided,_single_submitter;GENEINFO=GENEA:1
+1	2000	101	C	T	.	.	CLNSIG=Benign;CLNREVSTAT=criteria_provided,_single_submitter;GENEINFO=GENEB:2
+2	3000	102	G	A	.	.	CLNSIG=Uncertain_significance;CLNREVSTAT=criteria_provided,_single_submitter;GENEINFO=GENEC:3
+`
+
+const batchSubmissions = `#VariationID	ClinicalSignificance	DateLastEvaluated	Description	SubmittedPhenotypeInfo	ReportedPhenotypeInfo	ReviewStatus	CollectionMethod	OriginCounts	Submitter	SCV	SubmittedGeneSymbol	ExplanationOfInterpretation
+100	Pathogenic	-	-	-	-	criteria provided, single submitter	clinical testing	germline:1	Lab 1	SCV000000001.1	GENEA	-
+101	Benign	-	-	-	-	criteria provided, single submitter	clinical testing	germline:1	Lab 1	SCV000000002.1	GENEB	-
+102	Uncertain significance	-	-	-	-	criteria provided, single submitter	clinical testing	germline:1	Lab 1	SCV000000003.1	GENEC	-
+`
+
+const batchSampleVcf = `##fileformat=VCFv4.2
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	SAMPLE
+1	1000	.	A	G	50	PASS	.	GT	0/1
+1	2000	.	C	T	50	PASS	.	GT	1/1
+2	3000	.	G	A	50	PASS	.	GT	0/1
+`
+
+func TestSampleSummaryCountsReportedMatches(t *testing.T) {
+	dir, err := ioutil.TempDir("", "batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{"clinvar.vcf": batchClinvarVcf, "submission_summary.txt": batchSubmissions, "sample.vcf": batchSampleVcf}
+	for name, text := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client, err := clinvar.NewClinvar(filepath.Join(dir, "clinvar.vcf"), filepath.Join(dir, "submission_summary.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		config     ReportConfig
+		matches    int
+		pathogenic int
+		benign     int
+	}{
+		{"assessments", ReportConfig{}, 3, 1, 1},
+		{"filter", ReportConfig{Filter: "pathogenicity >= VUS"}, 2, 1, 0},
+		{"carrier screening", ReportConfig{Mode: ModeCarrierScreening, Sex: "female"}, 1, 1, 0},
+	}
+	for _, test := range tests {
+		config := test.config
+		config.Workers = 1
+		config.SourceVcfPath = filepath.Join(dir, "sample.vcf")
+		config.OutputFile = filepath.Join(dir, "sample.csv")
+		summary := &SampleSummary{}
+		if err := writeSampleReport(context.Background(), config, client, summary); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		file, err := os.Open(config.OutputFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, err := csv.NewReader(file).ReadAll()
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if summary.Variants != 3 || summary.Matches != test.matches || summary.Matches != len(rows)-1 {
+			t.Errorf("%s: expected %d matches, the rows in the report, got %d with %d rows", test.name, test.matches, summary.Matches, len(rows)-1)
+		}
+		counts := summary.PathogenicityCounts
+		if counts[clinvar.PathogenicityPathogenic] != test.pathogenic || counts[clinvar.PathogenicityBenign] != test.benign {
+			t.Errorf("%s: unexpected pathogenicity counts %v", test.name, counts)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package matcher
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PhaseInTrans  = "trans"
+	PhaseInCis    = "cis"
+	PhaseUnknown  = "unknown"
+	PhaseSetField = "PS"
+)
+
+type carrierFinding struct {
+	gene           string
+	match          *MatchedVariant
+	variantsInGene int
+	interpretation string
+}
+
+// Groups the P/LP matches the sample carries by gene and interprets each one from its zygosity,
+// the sample's sex for X on the assembly and the other P/LP variants in the same gene. Matches
+// without a gene are interpreted on their own, they can't be a second hit in anything
+func findCarrierFindings(matches []*MatchedVariant, sex string, assembly string) []*carrierFinding {
+	byGene := make(map[string][]*MatchedVariant)
+	findings := make([]*carrierFinding, 0)
+	for _, match := range matches {
+		if !match.Record.IsPathogenic() || !match.Line.GetGenotype().HasAlt() {
+			continue
+		}
+		symbols := match.Record.GeneSymbols()
+		if len(symbols) == 0 {
+			findings = append(findings, &carrierFinding{
+				match:          match,
+				variantsInGene: 1,
+				interpretation: interpretCarrierVariant(match, []*MatchedVariant{match}, sex, assembly),
+			})
+			continue
+		}
+		for _, symbol := range symbols {
+			byGene[symbol] = append(byGene[symbol], match)
+		}
+	}
+
+	for gene, geneMatches := range byGene {
+		for _, match := range geneMatches {
+			findings = append(findings, &carrierFinding{
+				gene:           gene,
+				match:          match,
+				variantsInGene: len(geneMatches),
+				interpretation: interpretCarrierVariant(match, geneMatches, sex, assembly),
+			})
+		}
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].gene != findings[j].gene {
+			return findings[i].gene < findings[j].gene
+		}
+		return findings[i].match.Line.Pos < findings[j].match.Line.Pos
+	})
+	return findings
+}
+
+func interpretCarrierVariant(match *MatchedVariant, geneMatches []*MatchedVariant, sex string, assembly string) string {
+	line := match.Line
+	genotype := line.GetGenotype()
+
+	if isHemizygousRegion(assembly, line.Chrom, line.Pos) {
+		switch {
+		case sex == SexMale:
+			return "Hemizygous, X-linked, affected"
+		case genotype.IsHaploid():
+			return "Hemizygous, X-linked, affected"
+		case sex == SexFemale && genotype.IsHomozygousAlt():
+			return "Homozygous, X-linked, affected"
+		case sex == SexFemale:
+			return "Heterozygous, X-linked carrier"
+		case genotype.IsHomozygousAlt():
+			return "Homozygous, X-linked, affected if female, hemizygous if male"
+		default:
+			return "Heterozygous, X-linked carrier if female"
+		}
+	}
+
+	if genotype.IsHomozygousAlt() {
+		return "Homozygous, affected if recessive"
+	}
+
+	others := make([]*MatchedVariant, 0)
+	for _, other := range geneMatches {
+		if other != match && other.Line.GetGenotype().IsHeterozygous() {
+			others = append(others, other)
+		}
+	}
+	if len(others) == 0 {
+		return "Heterozygous, carrier if recessive"
+	}
+	switch variantPhase(match, others) {
+	case PhaseInTrans:
+		return "Compound heterozygote in trans, affected if recessive"
+	case PhaseInCis:
+		return "Heterozygous, in cis with other variants in gene, carrier if recessive"
+	default:
+		return "Possible compound heterozygote, phase unknown"
+	}
+}
+
+// Uses phased genotypes in the same phase set to tell if the other variants are on the opposite
+// haplotype from this one. Any variant in trans wins, otherwise it's only cis if all of them are
+func variantPhase(match *MatchedVariant, others []*MatchedVariant) string {
+	genotype := match.Line.GetGenotype()
+	haplotypes := genotype.AltHaplotypes()
+	if !genotype.Phased || len(haplotypes) != 1 {
+		return PhaseUnknown
+	}
+	phase := PhaseInCis
+	for _, other := range others {
+		otherGenotype := other.Line.GetGenotype()
+		otherHaplotypes := otherGenotype.AltHaplotypes()
+		if !otherGenotype.Phased || len(otherHaplotypes) != 1 ||
+			other.Line.GetSampleData(PhaseSetField) != match.Line.GetSampleData(PhaseSetField) {
+			phase = PhaseUnknown
+			continue
+		}
+		if otherHaplotypes[0] != haplotypes[0] {
+			return PhaseInTrans
+		}
+	}
+	return phase
+}
+
+func resolveSex(config ReportConfig, assembly string, variants []*vcf.VcfLine) (string, error) {
+	sex, err := parseSex(config.Sex)
+	if err != nil {
+		return sex, err
+	}
+	if sex == SexAuto {
+		sex = inferSex(assembly, variants)
+		log.Infof("Inferred sample sex from X chromosome calls: %s", sex)
+	}
+	return sex, nil
+}
+
+func writeCarrierScreening(resultFile io.Writer, config ReportConfig, selector *variantSelector, variants []*vcf.VcfLine) error {
+	assembly := xAssembly(selector.client)
+	sex, err := resolveSex(config, assembly, variants)
+	if err != nil {
+		return err
+	}
+
+	matches, err := collectMatches(selector, variants)
+	if err != nil {
+		return err
+	}
+	findings := findCarrierFindings(matches, sex, assembly)
+
+	writer := csv.NewWriter(resultFile)
+	defer writer.Flush()
+	header := []string{
+		"Gene",
+		"Chromosome",
+		"Begin",
+		"Ref",
+		"Alt",
+		"Zygosity",
+		"Sex",
+		"Clinvar ID",
+		"Clinical Significance",
+		"Stars",
+		"Diseases",
+		"P/LP Variants In Gene",
+		"Interpretation",
+	}
+	writer.Write(header)
+	for _, finding := range findings {
+		line := finding.match.Line
+		record := finding.match.Record
+		selector.reported(record)
+		err := writer.Write([]string{
+			finding.gene,
+			line.Chrom,
+			strconv.Itoa(line.Pos),
+			line.Ref,
+			line.Alt,
+			line.GetSampleData("GT"),
+			sex,
+			record.Variant.ID,
+			record.AggregatePathogenicity().ToString(),
+			strconv.Itoa(record.Stars),
+			strings.Join(record.Diseases, ","),
+			strconv.Itoa(finding.variantsInGene),
+			finding.interpretation,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	log.Infof("Wrote %d carrier screening findings to %s\n", len(findings), config.OutputFile)
+	return writer.Error()
+}
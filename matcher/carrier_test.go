@@ -0,0 +1,174 @@
+package matcher
+
+import (
+	"testing"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+// A pathogenic ClinVar match in the gene, which can be blank, with the sample's GT and phase set
+func carrierMatch(chrom string, pos int, gene string, gt string, phaseSet string) *MatchedVariant {
+	info := "CLNSIG=Pathogenic"
+	if gene != "" {
+		info += ";GENEINFO=" + gene + ":1"
+	}
+	sampleData := map[string]string{"GT": gt}
+	if phaseSet != "" {
+		sampleData[PhaseSetField] = phaseSet
+	}
+	line := &vcf.VcfLine{Chrom: chrom, Pos: pos, Ref: "A", Alt: "G", SampleData: sampleData}
+	record := &clinvar.ClinvarRecord{Variant: &vcf.VcfLine{Chrom: chrom, Pos: pos, Ref: "A", Alt: "G", InfoText: info}}
+	return &MatchedVariant{Line: line, Record: record}
+}
+
+func TestInterpretCarrierVariant(t *testing.T) {
+	tests := []struct {
+		name     string
+		matches  []*MatchedVariant
+		sex      string
+		expected string
+	}{
+		{"heterozygous", []*MatchedVariant{carrierMatch("7", 100, "CFTR", "0/1", "")}, SexUnknown,
+			"Heterozygous, carrier if recessive"},
+		{"homozygous", []*MatchedVariant{carrierMatch("7", 100, "CFTR", "1/1", "")}, SexUnknown,
+			"Homozygous, affected if recessive"},
+		{"male on X", []*MatchedVariant{carrierMatch("X", 31000000, "DMD", "1/1", "")}, SexMale,
+			"Hemizygous, X-linked, affected"},
+		{"haploid X call", []*MatchedVariant{carrierMatch("X", 31000000, "DMD", "1", "")}, SexUnknown,
+			"Hemizygous, X-linked, affected"},
+		{"female het on X", []*MatchedVariant{carrierMatch("X", 31000000, "DMD", "0/1", "")}, SexFemale,
+			"Heterozygous, X-linked carrier"},
+		{"unknown sex het on X", []*MatchedVariant{carrierMatch("X", 31000000, "DMD", "0/1", "")}, SexUnknown,
+			"Heterozygous, X-linked carrier if female"},
+		{"male in the pseudoautosomal region", []*MatchedVariant{carrierMatch("X", 1000000, "SHOX", "0/1", "")}, SexMale,
+			"Heterozygous, carrier if recessive"},
+		{"in trans", []*MatchedVariant{
+			carrierMatch("7", 100, "CFTR", "0|1", "50"),
+			carrierMatch("7", 200, "CFTR", "1|0", "50"),
+		}, SexUnknown, "Compound heterozygote in trans, affected if recessive"},
+		{"in cis", []*MatchedVariant{
+			carrierMatch("7", 100, "CFTR", "0|1", "50"),
+			carrierMatch("7", 200, "CFTR", "0|1", "50"),
+		}, SexUnknown, "Heterozygous, in cis with other variants in gene, carrier if recessive"},
+		{"different phase sets", []*MatchedVariant{
+			carrierMatch("7", 100, "CFTR", "0|1", "50"),
+			carrierMatch("7", 200, "CFTR", "1|0", "150"),
+		}, SexUnknown, "Possible compound heterozygote, phase unknown"},
+		{"unphased", []*MatchedVariant{
+			carrierMatch("7", 100, "CFTR", "0/1", ""),
+			carrierMatch("7", 200, "CFTR", "0/1", ""),
+		}, SexUnknown, "Possible compound heterozygote, phase unknown"},
+	}
+	for _, test := range tests {
+		actual := interpretCarrierVariant(test.matches[0], test.matches, test.sex, clinvar.AssemblyGRCh37)
+		if actual != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestFindCarrierFindingsGroupsByGene(t *testing.T) {
+	benign := carrierMatch("7", 50, "CFTR", "0/1", "")
+	benign.Record.Variant.InfoText = "CLNSIG=Benign;GENEINFO=CFTR:1"
+	matches := []*MatchedVariant{
+		benign,
+		carrierMatch("7", 100, "CFTR", "0/1", ""),
+		carrierMatch("7", 200, "CFTR", "0/1", ""),
+		carrierMatch("7", 300, "CFTR", "0/0", ""),
+		carrierMatch("13", 100, "BRCA2", "0/1", ""),
+	}
+	findings := findCarrierFindings(matches, SexUnknown, clinvar.AssemblyGRCh37)
+	if len(findings) != 3 {
+		t.Fatalf("expected 3 findings, got %d", len(findings))
+	}
+	if findings[0].gene != "BRCA2" || findings[0].variantsInGene != 1 {
+		t.Errorf("expected BRCA2 alone first, got %s with %d", findings[0].gene, findings[0].variantsInGene)
+	}
+	for _, finding := range findings[1:] {
+		if finding.gene != "CFTR" || finding.variantsInGene != 2 ||
+			finding.interpretation != "Possible compound heterozygote, phase unknown" {
+			t.Errorf("unexpected CFTR finding %s:%d, %d in gene, %s", finding.gene, finding.match.Line.Pos,
+				finding.variantsInGene, finding.interpretation)
+		}
+	}
+}
+
+// Gene-less hits on different chromosomes have nothing to do with each other
+func TestFindCarrierFindingsWithoutGene(t *testing.T) {
+	matches := []*MatchedVariant{
+		carrierMatch("1", 100, "", "0/1", ""),
+		carrierMatch("2", 100, "", "0/1", ""),
+	}
+	findings := findCarrierFindings(matches, SexUnknown, clinvar.AssemblyGRCh37)
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %d", len(findings))
+	}
+	for _, finding := range findings {
+		if finding.variantsInGene != 1 || finding.interpretation != "Heterozygous, carrier if recessive" {
+			t.Errorf("%s:%d: expected to be interpreted alone, got %d in gene, %s", finding.match.Line.Chrom,
+				finding.match.Line.Pos, finding.variantsInGene, finding.interpretation)
+		}
+	}
+}
+
+// X calls for the sex inference, the first heterozygous ones out of the total
+func sexCalls(chrom string, total int, heterozygous int) []*vcf.VcfLine {
+	lines := make([]*vcf.VcfLine, 0, total)
+	for i := 0; i < total; i++ {
+		gt := "1/1"
+		if i < heterozygous {
+			gt = "0/1"
+		}
+		lines = append(lines, &vcf.VcfLine{Chrom: chrom, Pos: 10000000 + i, SampleData: map[string]string{"GT": gt}})
+	}
+	return lines
+}
+
+func TestInferSex(t *testing.T) {
+	tests := []struct {
+		name     string
+		variants []*vcf.VcfLine
+		expected string
+	}{
+		{"mostly homozygous", sexCalls("X", 40, 2), SexMale},
+		{"mostly heterozygous", sexCalls("X", 40, 20), SexFemale},
+		{"in between", sexCalls("X", 40, 8), SexUnknown},
+		{"too few calls", sexCalls("X", 10, 0), SexUnknown},
+		{"autosomes don't count", sexCalls("7", 40, 0), SexUnknown},
+		{"pseudoautosomal calls don't count", func() []*vcf.VcfLine {
+			lines := sexCalls("X", 40, 20)
+			for _, line := range lines {
+				line.Pos = 100000
+			}
+			return lines
+		}(), SexUnknown},
+	}
+	for _, test := range tests {
+		if actual := inferSex(clinvar.AssemblyGRCh37, test.variants); actual != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestResolveSex(t *testing.T) {
+	variants := sexCalls("X", 40, 0)
+	tests := []struct {
+		sex      string
+		expected string
+	}{
+		{"", SexMale},
+		{"auto", SexMale},
+		{"F", SexFemale},
+		{"male", SexMale},
+	}
+	for _, test := range tests {
+		actual, err := resolveSex(ReportConfig{Sex: test.sex}, clinvar.AssemblyGRCh37, variants)
+		if err != nil || actual != test.expected {
+			t.Errorf("%q: expected %s, got %s, %v", test.sex, test.expected, actual, err)
+		}
+	}
+	if _, err := resolveSex(ReportConfig{Sex: "other"}, clinvar.AssemblyGRCh37, variants); err == nil {
+		t.Errorf("expected an error for an unknown sex")
+	}
+}
@@ -0,0 +1,405 @@
+package matcher
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+	log "github.com/sirupsen/logrus"
+)
+
+// Columns describing each ClinVar variant in the cohort report, before the carrier counts
+var cohortColumns = []string{"chromosome", "begin", "ref", "alt", "clinvar_id", "genes", "max_pathogenicity", "stars", "diseases", "clinvar_link"}
+
+// A sample carrying a ClinVar variant and its zygosity
+type cohortCarrier struct {
+	sample   string
+	zygosity string
+}
+
+// A ClinVar variant carried by at least one sample in the cohort
+type cohortVariant struct {
+	// The first carrier's match, for the variant columns
+	match    *MatchedVariant
+	carriers []cohortCarrier
+}
+
+// The samples in the cohort and the ClinVar variants they carry
+type cohort struct {
+	samples  []string
+	variants map[string]*cohortVariant
+}
+
+func newCohort() *cohort {
+	return &cohort{samples: make([]string, 0), variants: make(map[string]*cohortVariant)}
+}
+
+// The header's sample names, or the input's file name for files without any like raw genotypes
+func cohortSampleNames(header *vcf.Header, input string) []string {
+	if len(header.SampleNames) > 0 {
+		return header.SampleNames
+	}
+	return []string{SampleName(input)}
+}
+
+// Matches every sample column of the variants, counting the samples that carry each ClinVar variant.
+// Stops with the context's error once the selector's run is cancelled
+func (c *cohort) addSamples(selector *variantSelector, names []string, variants []*vcf.VcfLine) error {
+	c.samples = append(c.samples, names...)
+	for v, variant := range variants {
+		if v%matchBatchVariants == 0 {
+			if err := selector.cancelled(); err != nil {
+				return err
+			}
+		}
+		for i, name := range names {
+			line := variant.ForSample(i)
+			if !line.GetGenotype().HasAlt() {
+				continue
+			}
+			match, ok := selector.Match(line)
+			if !ok {
+				continue
+			}
+			selector.found(match)
+			zygosity := match.Line.GetGenotype().Zygosity()
+			if zygosity == vcf.ZygosityReference || zygosity == vcf.ZygosityNoCall {
+				continue
+			}
+			key := clinvar.ToClinvarKey(match.Record.Variant)
+			observed, ok := c.variants[key]
+			if !ok {
+				observed = &cohortVariant{match: match}
+				c.variants[key] = observed
+			}
+			observed.carriers = append(observed.carriers, cohortCarrier{sample: name, zygosity: zygosity})
+		}
+	}
+	return nil
+}
+
+// Adds another input's samples, naming any that clash with an existing sample after the input too,
+// like NA12878.vcf:SAMPLE1
+func (c *cohort) merge(other *cohort, input string) {
+	used := make(map[string]bool, len(c.samples))
+	for _, sample := range c.samples {
+		used[sample] = true
+	}
+	renamed := make(map[string]string)
+	for _, sample := range other.samples {
+		name := sample
+		if used[name] {
+			name = filepath.Base(input) + ":" + sample
+			renamed[sample] = name
+		}
+		used[name] = true
+		c.samples = append(c.samples, name)
+	}
+	for key, variant := range other.variants {
+		observed, ok := c.variants[key]
+		if !ok {
+			observed = &cohortVariant{match: variant.match}
+			c.variants[key] = observed
+		}
+		for _, carrier := range variant.carriers {
+			if name, ok := renamed[carrier.sample]; ok {
+				carrier.sample = name
+			}
+			observed.carriers = append(observed.carriers, carrier)
+		}
+	}
+}
+
+// The observed variants in ClinVar's position order
+func (c *cohort) sortedVariants() []*cohortVariant {
+	variants := make([]*cohortVariant, 0, len(c.variants))
+	for _, variant := range c.variants {
+		variants = append(variants, variant)
+	}
+	sort.Slice(variants, func(i, j int) bool {
+		a, b := variants[i].match.Record.Variant, variants[j].match.Record.Variant
+		if a.Chrom != b.Chrom {
+			return vcf.ChromLess(a.Chrom, b.Chrom)
+		}
+		if a.Pos != b.Pos {
+			return a.Pos < b.Pos
+		}
+		return clinvar.ToClinvarKey(a) < clinvar.ToClinvarKey(b)
+	})
+	return variants
+}
+
+// _genes.csv next to the cohort report, like clinvar_assessments_genes.csv
+func cohortGenesOutputFile(outputFile string) string {
+	extension := filepath.Ext(outputFile)
+	return fmt.Sprintf("%s_genes%s", strings.TrimSuffix(outputFile, extension), extension)
+}
+
+func writeCohortReport(resultFile io.Writer, config ReportConfig, selector *variantSelector, header *vcf.Header, variants []*vcf.VcfLine) error {
+	c := newCohort()
+	if err := c.addSamples(selector, cohortSampleNames(header, config.SourceVcfPath), variants); err != nil {
+		return err
+	}
+	return writeCohortTables(resultFile, config, c)
+}
+
+// Loads ClinVar once and aggregates the samples in all the inputs, reading several inputs at a time,
+// into one cohort report
+func GenerateCohortReport(config ReportConfig, batch BatchConfig) error {
+	return GenerateCohortReportContext(context.Background(), config, batch)
+}
+
+// Like GenerateCohortReport, stopping with the context's error once it's cancelled. Inputs that
+// haven't started are skipped and the downloads are kept to be picked up again
+func GenerateCohortReportContext(ctx context.Context, config ReportConfig, batch BatchConfig) error {
+	if err := validateConfig(config); err != nil {
+		return err
+	}
+	for _, input := range batch.Inputs {
+		if input == vcf.StdioPath {
+			return fmt.Errorf("standard input can't be one of several inputs")
+		}
+	}
+
+	clinvarFile, clinvarSubmissionFile, downloads, err := FetchClinvarContext(ctx, config.ClinvarVcfPath, config.ClinvarSubmissionPath)
+	if err != nil {
+		return err
+	}
+	client, err := clinvar.NewClinvarContext(ctx, clinvarFile, clinvarSubmissionFile, nil)
+	if err != nil {
+		return err
+	}
+
+	jobs := batch.Jobs
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	log.Infof("Aggregating %d inputs, %d at a time", len(batch.Inputs), jobs)
+	parts := make([]*cohort, len(batch.Inputs))
+	errs := make([]error, len(batch.Inputs))
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range queue {
+				parts[index], errs[index] = readCohortInput(ctx, config, client, batch.Inputs[index])
+			}
+		}()
+	}
+	for index := range batch.Inputs {
+		queue <- index
+	}
+	close(queue)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// A missing sample would skew every frequency, so any failure stops the report
+	c := newCohort()
+	for index, input := range batch.Inputs {
+		if errs[index] != nil {
+			return fmt.Errorf("%s: %v", input, errs[index])
+		}
+		c.merge(parts[index], input)
+	}
+
+	resultFile, err := CreateOutput(config.OutputFile)
+	if err != nil {
+		return err
+	}
+	defer resultFile.Close()
+	if err := writeCohortTables(resultFile, config, c); err != nil {
+		return err
+	}
+
+	if !config.SaveDownloads {
+		for _, f := range downloads {
+			if err := os.Remove(f); err != nil {
+				return err
+			}
+			log.Infof("Deleted downloaded file %s\n", f)
+		}
+	}
+	return nil
+}
+
+func readCohortInput(ctx context.Context, config ReportConfig, client *clinvar.ClinvarClient, input string) (*cohort, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	selector, err := newVariantSelector(config)
+	if err != nil {
+		return nil, err
+	}
+	selector.client = client
+	selector.ctx = ctx
+	header, variants, err := readSample(input, client)
+	if err != nil {
+		return nil, err
+	}
+	names := cohortSampleNames(header, input)
+	log.Infof("%s: %d samples, %d variants", input, len(names), len(variants))
+	c := newCohort()
+	if err := c.addSamples(selector, names, variants); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Writes the variant table to the result file and the gene table next to the output file
+func writeCohortTables(resultFile io.Writer, config ReportConfig, c *cohort) error {
+	variants := c.sortedVariants()
+	if err := writeCohortVariants(resultFile, c, variants); err != nil {
+		return err
+	}
+	log.Infof("Wrote %d ClinVar variants carried by %d samples to %s\n", len(variants), len(c.samples), config.OutputFile)
+
+	genesFile := cohortGenesOutputFile(config.OutputFile)
+	genesOutput, err := CreateOutput(genesFile)
+	if err != nil {
+		return err
+	}
+	defer genesOutput.Close()
+	genes, err := writeCohortGenes(genesOutput, c, variants)
+	if err != nil {
+		return err
+	}
+	log.Infof("Wrote %d genes to %s\n", genes, genesFile)
+	return nil
+}
+
+func writeCohortVariants(resultFile io.Writer, c *cohort, variants []*cohortVariant) error {
+	columns, err := ResolveColumns(cohortColumns)
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(resultFile)
+	headers := ColumnHeaders(columns)
+	headers = append(headers, "Heterozygous", "Homozygous", "Hemizygous", "Carriers", "Carrier Frequency", "Carrier Samples")
+	writer.Write(headers)
+	for _, variant := range variants {
+		counts := make(map[string]int)
+		samples := make([]string, 0, len(variant.carriers))
+		for _, carrier := range variant.carriers {
+			counts[carrier.zygosity]++
+			samples = append(samples, carrier.sample)
+		}
+		record := ExtractRecord(columns, variant.match.Line, variant.match.Record)
+		record = append(record,
+			strconv.Itoa(counts[vcf.ZygosityHeterozygous]),
+			strconv.Itoa(counts[vcf.ZygosityHomozygous]),
+			strconv.Itoa(counts[vcf.ZygosityHemizygous]),
+			strconv.Itoa(len(variant.carriers)),
+			frequency(len(variant.carriers), len(c.samples)),
+			strings.Join(samples, ";"),
+		)
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Per gene, the samples carrying any observed variant and those carrying a pathogenic or likely
+// pathogenic one, with the P/LP homozygous and hemizygous samples that may be affected by a
+// recessive condition
+type cohortGene struct {
+	variants             int
+	pathogenic           int
+	carriers             map[string]bool
+	pathogenicCarriers   map[string]bool
+	pathogenicHomozygous map[string]bool
+}
+
+func writeCohortGenes(output io.Writer, c *cohort, variants []*cohortVariant) (int, error) {
+	genes := make(map[string]*cohortGene)
+	for _, variant := range variants {
+		pathogenic := variant.match.Record.IsPathogenic()
+		for _, symbol := range variant.match.Record.GeneSymbols() {
+			gene, ok := genes[symbol]
+			if !ok {
+				gene = &cohortGene{
+					carriers:             make(map[string]bool),
+					pathogenicCarriers:   make(map[string]bool),
+					pathogenicHomozygous: make(map[string]bool),
+				}
+				genes[symbol] = gene
+			}
+			gene.variants++
+			if pathogenic {
+				gene.pathogenic++
+			}
+			for _, carrier := range variant.carriers {
+				gene.carriers[carrier.sample] = true
+				if !pathogenic {
+					continue
+				}
+				gene.pathogenicCarriers[carrier.sample] = true
+				if carrier.zygosity != vcf.ZygosityHeterozygous {
+					gene.pathogenicHomozygous[carrier.sample] = true
+				}
+			}
+		}
+	}
+	symbols := make([]string, 0, len(genes))
+	for symbol := range genes {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	writer := csv.NewWriter(output)
+	writer.Write([]string{"Gene", "ClinVar Variants", "P/LP Variants", "Carriers", "P/LP Carriers",
+		"P/LP Carrier Frequency", "P/LP Homozygous or Hemizygous", "P/LP Carrier Samples"})
+	for _, symbol := range symbols {
+		gene := genes[symbol]
+		record := []string{
+			symbol,
+			strconv.Itoa(gene.variants),
+			strconv.Itoa(gene.pathogenic),
+			strconv.Itoa(len(gene.carriers)),
+			strconv.Itoa(len(gene.pathogenicCarriers)),
+			frequency(len(gene.pathogenicCarriers), len(c.samples)),
+			strconv.Itoa(len(gene.pathogenicHomozygous)),
+			strings.Join(c.inOrder(gene.pathogenicCarriers), ";"),
+		}
+		if err := writer.Write(record); err != nil {
+			return 0, err
+		}
+	}
+	writer.Flush()
+	return len(symbols), writer.Error()
+}
+
+// The samples in the set, in cohort order
+func (c *cohort) inOrder(samples map[string]bool) []string {
+	ordered := make([]string, 0, len(samples))
+	for _, sample := range c.samples {
+		if samples[sample] {
+			ordered = append(ordered, sample)
+		}
+	}
+	return ordered
+}
+
+// Fraction of all the cohort's samples, so a sample whose VCF doesn't have the variant counts as not
+// carrying it
+func frequency(carriers int, samples int) string {
+	if samples == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(float64(carriers)/float64(samples), 'f', 4, 64)
+}
@@ -0,0 +1,255 @@
+package matcher
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+const (
+	SampleColumnPrefix  = "sample."
+	ClinvarColumnPrefix = "clinvar."
+	InfoColumnField     = "INFO"
+	FormatColumnField   = "FORMAT"
+)
+
+// Pulls a single report value out of a sample variant and its ClinVar match
+type ColumnExtractor func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) string
+
+type Column struct {
+	Name    string
+	Header  string
+	Extract ColumnExtractor
+}
+
+// Columns written when the user doesn't choose their own, in report order
+var DefaultColumns = []string{
+	"chromosome",
+	"begin",
+	"end",
+	"var_type",
+	"quality",
+	"filter",
+	"ref",
+	"alt",
+	"rsid",
+	"zygosity",
+	"clinvar_id",
+	"assessment_count",
+	"max_pathogenicity",
+	"benign_count",
+	"likely_benign_count",
+	"vus_count",
+	"likely_pathogenic_count",
+	"pathogenic_count",
+	"other_count",
+	"diseases",
+	"genes",
+	"clinvar_link",
+	"dbsnp_link",
+	"snpedia_link",
+	"af_esp",
+	"af_exac",
+	"af_tgp",
+}
+
+var columnRegistry = make(map[string]Column)
+
+func init() {
+	RegisterColumn(Column{"chromosome", "Chromosome", func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) string {
+		return line.Chrom
+	}})
+	RegisterColumn(Column{"begin", "Begin", func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) string {
+		return strconv.Itoa(line.Pos)
+	}})
+	RegisterColumn(Column{"end", "End", func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) string {
+		if sv, ok := line.StructuralVariant(); ok {
+			return strconv.Itoa(sv.End + 1)
+		}
+		return strconv.Itoa(line.Pos + len(line.Ref))
+	}})
+	RegisterColumn(Column{"var_type", "Var Type", clinvarInfoExtractor(clinvar.VariantClassificationKey)})
+	RegisterColumn(Column{"quality", "Quality", func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) string {
+		return line.Qual
+	}})
+	RegisterColumn(Column{"filter", "Filter", func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) string {
+		return line.Filter
+	}})
+	RegisterColumn(Column{"ref", "Ref", func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) string {
+		return line.Ref
+	}})
+	RegisterColumn(Column{"alt", "Alt", func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) string {
+		return line.Alt
+	}})
+	RegisterColumn(Column{"rsid", "Rsid", func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) string {
+		return getRsid(line.ID, record.Variant.GetInfo(clinvar.RSIDKey))
+	}})
+	RegisterColumn(Column{"zygosity", "Zygosity", sampleFormatExtractor("GT")})
+	RegisterColumn(Column{"clinvar_id", "Clinvar ID", func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) string {
+		return record.Variant.ID
+	}})
+	RegisterColumn(Column{"assessment_count", "Assessment Count", func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) string {
+		return strconv.Itoa(record.AssessmentCount)
+	}})
+	RegisterColumn(Column{"max_pathogenicity", "Max Pathogenicity", func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) string {
+		return record.Pathogenicity.ToString()
+	}})
+	RegisterColumn(Column{"stars", "Stars", func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) string {
+		return strconv.Itoa(record.Stars)
+	}})
+	RegisterColumn(Column{"review_status", "Review Status", clinvarInfoExtractor(clinvar.ReviewStatusKey)})
+	RegisterColumn(Column{"match_method", "Match Method", func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) string {
+		return record.MatchMethod
+	}})
+	RegisterColumn(Column{"match_transform", "Match Transform", func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) string {
+		return record.MatchTransform
+	}})
+	RegisterColumn(Column{"reciprocal_overlap", "Reciprocal Overlap", func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) string {
+		if record.MatchMethod != clinvar.MatchMethodOverlap {
+			return ""
+		}
+		return strconv.FormatFloat(record.ReciprocalOverlap, 'f', 2, 64)
+	}})
+	RegisterColumn(Column{"benign_count", "# Benign", pathogenicityCountExtractor(clinvar.PathogenicityBenign)})
+	RegisterColumn(Column{"likely_benign_count", "# Likely Benign", pathogenicityCountExtractor(clinvar.PathogenicityLikelyBenign)})
+	RegisterColumn(Column{"vus_count", "# VUS", pathogenicityCountExtractor(clinvar.PathogenicityVUS)})
+	RegisterColumn(Column{"likely_pathogenic_count", "# Likely Path", pathogenicityCountExtractor(clinvar.PathogenicityLikelyPathogenic)})
+	RegisterColumn(Column{"pathogenic_count", "# Pathogenic", pathogenicityCountExtractor(clinvar.PathogenicityPathogenic)})
+	RegisterColumn(Column{"other_count", "# Other", pathogenicityCountExtractor(clinvar.PathogenicityOther)})
+	RegisterColumn(Column{"diseases", "Diseases", func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) string {
+		return strings.Join(record.Diseases, ",")
+	}})
+	RegisterColumn(Column{"genes", "Genes", func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) string {
+		return strings.Join(record.Genes, ",")
+	}})
+	RegisterColumn(Column{"clinvar_link", "Clinvar Link", func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) string {
+		return fmt.Sprintf(ClinvarLinkPattern, record.Variant.ID)
+	}})
+	RegisterColumn(Column{"dbsnp_link", "dbSNP Link", rsidLinkExtractor(DbSNPLinkPattern)})
+	RegisterColumn(Column{"snpedia_link", "Snpedia Link", rsidLinkExtractor(SnpediaLinkPattern)})
+	RegisterColumn(Column{"af_esp", "AF_ESP", clinvarInfoExtractor(clinvar.AFEspKey)})
+	RegisterColumn(Column{"af_exac", "AF_EXAC", clinvarInfoExtractor(clinvar.AFExacKey)})
+	RegisterColumn(Column{"af_tgp", "AF_TGP", clinvarInfoExtractor(clinvar.AFTgpKey)})
+}
+
+// Adds a column to the registry so it can be chosen by name, replacing any existing column with the same name
+func RegisterColumn(column Column) {
+	columnRegistry[column.Name] = column
+}
+
+// The columns the user asked for, otherwise the defaults plus the columns explaining how variants
+// were matched when any of the fallback matching methods are turned on
+func reportColumnNames(config ReportConfig) []string {
+	if len(config.Columns) > 0 {
+		return config.Columns
+	}
+	names := append([]string{}, DefaultColumns...)
+	if config.RsidFallback {
+		names = append(names, "match_method")
+	}
+	if config.AlleleTransforms {
+		names = append(names, "match_transform")
+	}
+	return names
+}
+
+// Turns a list of column names into columns, in the order given. Besides the registered names,
+// sample.INFO.<key>, sample.FORMAT.<key>, clinvar.INFO.<key> and clinvar.FORMAT.<key> pull
+// arbitrary fields out of the sample or ClinVar VCF
+func ResolveColumns(names []string) ([]Column, error) {
+	if len(names) == 0 {
+		names = DefaultColumns
+	}
+	columns := make([]Column, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if column, ok := columnRegistry[name]; ok {
+			columns = append(columns, column)
+			continue
+		}
+		column, err := parseFieldColumn(name)
+		if err != nil {
+			return columns, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+func parseFieldColumn(name string) (Column, error) {
+	parts := strings.SplitN(name, ".", 3)
+	if len(parts) != 3 || parts[2] == "" {
+		return Column{}, fmt.Errorf("unknown column %q", name)
+	}
+	source, field, key := parts[0]+".", strings.ToUpper(parts[1]), parts[2]
+
+	var extract ColumnExtractor
+	switch {
+	case source == SampleColumnPrefix && field == InfoColumnField:
+		extract = sampleInfoExtractor(key)
+	case source == SampleColumnPrefix && field == FormatColumnField:
+		extract = sampleFormatExtractor(key)
+	case source == ClinvarColumnPrefix && field == InfoColumnField:
+		extract = clinvarInfoExtractor(key)
+	case source == ClinvarColumnPrefix && field == FormatColumnField:
+		extract = func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) string {
+			return record.Variant.GetSampleData(key)
+		}
+	default:
+		return Column{}, fmt.Errorf("unknown column %q, field columns look like sample.FORMAT.DP or clinvar.INFO.CLNHGVS", name)
+	}
+	return Column{Name: name, Header: name, Extract: extract}, nil
+}
+
+func ColumnHeaders(columns []Column) []string {
+	headers := make([]string, len(columns))
+	for i, column := range columns {
+		headers[i] = column.Header
+	}
+	return headers
+}
+
+func ExtractRecord(columns []Column, line *vcf.VcfLine, record *clinvar.ClinvarRecord) []string {
+	values := make([]string, len(columns))
+	for i, column := range columns {
+		values[i] = column.Extract(line, record)
+	}
+	return values
+}
+
+func sampleInfoExtractor(key string) ColumnExtractor {
+	return func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) string {
+		return line.GetInfo(key)
+	}
+}
+
+func sampleFormatExtractor(key string) ColumnExtractor {
+	return func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) string {
+		return line.GetSampleData(key)
+	}
+}
+
+func clinvarInfoExtractor(key string) ColumnExtractor {
+	return func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) string {
+		return record.Variant.GetInfo(key)
+	}
+}
+
+func pathogenicityCountExtractor(p clinvar.Pathogenicity) ColumnExtractor {
+	return func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) string {
+		return strconv.Itoa(record.PathogenicityCounts[p])
+	}
+}
+
+func rsidLinkExtractor(pattern string) ColumnExtractor {
+	return func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) string {
+		rsid := getRsid(line.ID, record.Variant.GetInfo(clinvar.RSIDKey))
+		if rsid == "" {
+			return ""
+		}
+		return fmt.Sprintf(pattern, rsid)
+	}
+}
@@ -0,0 +1,261 @@
+package matcher
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/interval"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	CoverageVariant      = "Variant"
+	CoverageOtherVariant = "Other variant"
+	CoverageReference    = "Reference"
+	CoverageLowQuality   = "Low quality"
+	CoverageNoCall       = "No call"
+)
+
+const (
+	DefaultMinDepth           = 10
+	DefaultMinGenotypeQuality = 20
+)
+
+// Whether the sample was called at a ClinVar site, and the record that says so
+type siteCoverage struct {
+	record  *clinvar.ClinvarRecord
+	status  string
+	call    *vcf.VcfLine
+	depth   string
+	quality string
+}
+
+// Sample records for each normalized chromosome over the positions they cover, which for gVCF
+// reference blocks runs to END
+func buildCoverageIndex(variants []*vcf.VcfLine) map[string]*interval.Tree {
+	intervals := make(map[string][]interval.Interval)
+	for _, line := range variants {
+		chrom := vcf.NormalizeChrom(line.Chrom)
+		intervals[chrom] = append(intervals[chrom], interval.Interval{Start: line.Pos, End: line.BlockEnd(), Value: line})
+	}
+	trees := make(map[string]*interval.Tree)
+	for chrom, chromIntervals := range intervals {
+		trees[chrom] = interval.NewTree(chromIntervals)
+	}
+	return trees
+}
+
+// Index of the ClinVar ALT among the sample record's alleles, or 0 if the record doesn't have it
+func altIndex(call *vcf.VcfLine, site *vcf.VcfLine) int {
+	if call.Pos != site.Pos || !strings.EqualFold(call.Ref, site.Ref) {
+		return 0
+	}
+	for i, alt := range strings.Split(call.Alt, ",") {
+		if strings.EqualFold(alt, site.Alt) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func carriesAllele(genotype vcf.Genotype, index int) bool {
+	for _, allele := range genotype.Alleles {
+		if allele == index {
+			return true
+		}
+	}
+	return false
+}
+
+// Works out whether the sample has the ClinVar variant, a different variant, or confidently has the
+// reference at the site, from the variant records and reference blocks overlapping it
+func classifyCoverage(config ReportConfig, tree *interval.Tree, site *vcf.VcfLine) (string, *vcf.VcfLine) {
+	if tree == nil {
+		return CoverageNoCall, nil
+	}
+	end := site.Pos + len(site.Ref) - 1
+	calls := tree.Overlapping(site.Pos, end)
+	if len(calls) == 0 {
+		return CoverageNoCall, nil
+	}
+	for _, found := range calls {
+		call := found.Value.(*vcf.VcfLine)
+		if index := altIndex(call, site); index > 0 && carriesAllele(call.GetGenotype(), index) {
+			return CoverageVariant, call
+		}
+	}
+	for _, found := range calls {
+		call := found.Value.(*vcf.VcfLine)
+		if !call.IsReferenceBlock() && call.GetGenotype().HasAlt() {
+			return CoverageOtherVariant, call
+		}
+	}
+
+	// Every record over the site is reference, it's only as good as the worst of them
+	for _, found := range calls {
+		call := found.Value.(*vcf.VcfLine)
+		if call.GetGenotype().IsNoCall() {
+			return CoverageNoCall, call
+		}
+	}
+	for _, found := range calls {
+		call := found.Value.(*vcf.VcfLine)
+		if !config.IncludeAllVariants && !isPassingCoverageFilter(call) {
+			return CoverageLowQuality, call
+		}
+		if depth, ok := call.Depth(); ok && depth < config.MinDepth {
+			return CoverageLowQuality, call
+		}
+		if quality, ok := call.GenotypeQuality(); ok && quality < config.MinGenotypeQuality {
+			return CoverageLowQuality, call
+		}
+	}
+	return CoverageReference, calls[0].Value.(*vcf.VcfLine)
+}
+
+// GATK and DeepVariant leave FILTER as . on reference blocks, so for those only MIN_DP and GQ say
+// how good the call is
+func isPassingCoverageFilter(call *vcf.VcfLine) bool {
+	if call.IsReferenceBlock() && (call.Filter == "." || call.Filter == "") {
+		return true
+	}
+	return isPassingVariantFilter(call.Filter)
+}
+
+// The ClinVar P/LP sites to check, limited to the panel's genes and regions and the filter
+// expression when they're given
+func coverageSites(selector *variantSelector) []*clinvar.ClinvarRecord {
+	chroms := make([]string, 0, len(selector.client.VariantsByChrom))
+	for chrom := range selector.client.VariantsByChrom {
+		chroms = append(chroms, chrom)
+	}
+	sort.Slice(chroms, func(i, j int) bool {
+		return vcf.ChromLess(chroms[i], chroms[j])
+	})
+
+	sites := make([]*clinvar.ClinvarRecord, 0)
+	for _, chrom := range chroms {
+		for _, variant := range selector.client.VariantsByChrom[chrom] {
+			p := clinvar.VariantPathogenicity(variant)
+			if p != clinvar.PathogenicityLikelyPathogenic && p != clinvar.PathogenicityPathogenic {
+				continue
+			}
+			if selector.regions != nil && !selector.regions.ContainsVariant(variant) {
+				continue
+			}
+			record, ok := selector.client.LookupVariant(variant)
+			if !ok {
+				record = &clinvar.ClinvarRecord{Variant: variant, Stars: clinvar.ReviewStars(variant.GetInfo(clinvar.ReviewStatusKey))}
+			}
+			if !selector.acceptsRecord(variant, record) {
+				continue
+			}
+			sites = append(sites, record)
+		}
+	}
+	return sites
+}
+
+// Stops with the context's error once the selector's run is cancelled
+func findSiteCoverage(config ReportConfig, selector *variantSelector, variants []*vcf.VcfLine) ([]*siteCoverage, error) {
+	index := buildCoverageIndex(variants)
+	coverage := make([]*siteCoverage, 0)
+	for i, record := range coverageSites(selector) {
+		if i%matchBatchVariants == 0 {
+			if err := selector.cancelled(); err != nil {
+				return nil, err
+			}
+		}
+		site := record.Variant
+		status, call := classifyCoverage(config, index[vcf.NormalizeChrom(site.Chrom)], site)
+		result := &siteCoverage{record: record, status: status, call: call}
+		if status == CoverageVariant {
+			selector.found(&MatchedVariant{Line: call, Record: record})
+		}
+		if call != nil {
+			if depth, ok := call.Depth(); ok {
+				result.depth = strconv.Itoa(depth)
+			}
+			if quality, ok := call.GenotypeQuality(); ok {
+				result.quality = strconv.Itoa(quality)
+			}
+		}
+		coverage = append(coverage, result)
+	}
+	return coverage, nil
+}
+
+func writeCoverageReport(resultFile io.Writer, config ReportConfig, selector *variantSelector, variants []*vcf.VcfLine) error {
+	coverage, err := findSiteCoverage(config, selector, variants)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(resultFile)
+	defer writer.Flush()
+	header := []string{
+		"Chromosome",
+		"Begin",
+		"Ref",
+		"Alt",
+		"Genes",
+		"Clinvar ID",
+		"Clinical Significance",
+		"Stars",
+		"Coverage",
+		"Sample Begin",
+		"Sample Ref",
+		"Sample Alt",
+		"Zygosity",
+		"Depth",
+		"GQ",
+	}
+	writer.Write(header)
+	counts := make(map[string]int)
+	for _, site := range coverage {
+		counts[site.status]++
+		// Every ClinVar site gets a row, but only the ones the sample has are matches
+		if site.status == CoverageVariant {
+			selector.reported(site.record)
+		}
+		variant := site.record.Variant
+		record := []string{
+			variant.Chrom,
+			strconv.Itoa(variant.Pos),
+			variant.Ref,
+			variant.Alt,
+			strings.Join(site.record.GeneSymbols(), ","),
+			variant.ID,
+			site.record.AggregatePathogenicity().ToString(),
+			strconv.Itoa(site.record.Stars),
+			site.status,
+			"",
+			"",
+			"",
+			"",
+			site.depth,
+			site.quality,
+		}
+		if site.call != nil {
+			record[9] = strconv.Itoa(site.call.Pos)
+			record[10] = site.call.Ref
+			record[11] = site.call.Alt
+			record[12] = site.call.GetSampleData("GT")
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	log.Infof("Checked %d ClinVar P/LP sites: %d variant, %d other variant, %d reference, %d low quality, %d no call",
+		len(coverage), counts[CoverageVariant], counts[CoverageOtherVariant], counts[CoverageReference],
+		counts[CoverageLowQuality], counts[CoverageNoCall])
+	log.Infof("Wrote coverage of %d sites to %s\n", len(coverage), config.OutputFile)
+	return writer.Error()
+}
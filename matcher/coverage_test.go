@@ -0,0 +1,98 @@
+package matcher
+
+import (
+	"testing"
+
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+func coverageCall(pos int, ref string, alt string, filter string, info string, sample map[string]string) *vcf.VcfLine {
+	return &vcf.VcfLine{Chrom: "1", Pos: pos, Ref: ref, Alt: alt, Filter: filter, InfoText: info, SampleData: sample}
+}
+
+func TestClassifyCoverage(t *testing.T) {
+	site := &vcf.VcfLine{Chrom: "1", Pos: 1000, Ref: "C", Alt: "T"}
+	config := ReportConfig{MinDepth: DefaultMinDepth, MinGenotypeQuality: DefaultMinGenotypeQuality}
+	tests := []struct {
+		name     string
+		calls    []*vcf.VcfLine
+		config   ReportConfig
+		expected string
+	}{
+		{"nothing called", nil, config, CoverageNoCall},
+		{
+			"has the variant",
+			[]*vcf.VcfLine{coverageCall(1000, "C", "T", "PASS", ".", map[string]string{"GT": "0/1", "DP": "30", "GQ": "99"})},
+			config, CoverageVariant,
+		},
+		{
+			"has the variant as the second ALT",
+			[]*vcf.VcfLine{coverageCall(1000, "C", "G,T", "PASS", ".", map[string]string{"GT": "0/2"})},
+			config, CoverageVariant,
+		},
+		{
+			"different ALT",
+			[]*vcf.VcfLine{coverageCall(1000, "C", "G", "PASS", ".", map[string]string{"GT": "0/1"})},
+			config, CoverageOtherVariant,
+		},
+		{
+			"deletion over the site",
+			[]*vcf.VcfLine{coverageCall(998, "AGCT", "A", "PASS", ".", map[string]string{"GT": "0/1"})},
+			config, CoverageOtherVariant,
+		},
+		{
+			"GATK reference block",
+			[]*vcf.VcfLine{coverageCall(900, "A", "<NON_REF>", ".", "END=1100", map[string]string{"GT": "0/0", "MIN_DP": "25", "GQ": "60"})},
+			config, CoverageReference,
+		},
+		{
+			"DeepVariant reference block",
+			[]*vcf.VcfLine{coverageCall(900, "A", "<*>", ".", "END=1100", map[string]string{"GT": "0/0", "MIN_DP": "25", "GQ": "60"})},
+			config, CoverageReference,
+		},
+		{
+			"filtered reference block",
+			[]*vcf.VcfLine{coverageCall(900, "A", "<NON_REF>", "LowGQ", "END=1100", map[string]string{"GT": "0/0", "MIN_DP": "25", "GQ": "60"})},
+			config, CoverageLowQuality,
+		},
+		{
+			"filtered reference block included",
+			[]*vcf.VcfLine{coverageCall(900, "A", "<NON_REF>", "LowGQ", "END=1100", map[string]string{"GT": "0/0", "MIN_DP": "25", "GQ": "60"})},
+			ReportConfig{IncludeAllVariants: true, MinDepth: DefaultMinDepth, MinGenotypeQuality: DefaultMinGenotypeQuality},
+			CoverageReference,
+		},
+		{
+			"shallow reference block",
+			[]*vcf.VcfLine{coverageCall(900, "A", "<NON_REF>", ".", "END=1100", map[string]string{"GT": "0/0", "MIN_DP": "4", "DP": "30", "GQ": "60"})},
+			config, CoverageLowQuality,
+		},
+		{
+			"low GQ reference block",
+			[]*vcf.VcfLine{coverageCall(900, "A", "<NON_REF>", ".", "END=1100", map[string]string{"GT": "0/0", "MIN_DP": "25", "GQ": "10"})},
+			config, CoverageLowQuality,
+		},
+		{
+			"no call reference block",
+			[]*vcf.VcfLine{coverageCall(900, "A", "<NON_REF>", ".", "END=1100", map[string]string{"GT": "./.", "MIN_DP": "0"})},
+			config, CoverageNoCall,
+		},
+		{
+			"unfiltered reference call that isn't a block",
+			[]*vcf.VcfLine{coverageCall(1000, "C", "T", ".", ".", map[string]string{"GT": "0/0", "DP": "30", "GQ": "99"})},
+			config, CoverageLowQuality,
+		},
+		{
+			"block ends before the site",
+			[]*vcf.VcfLine{coverageCall(900, "A", "<NON_REF>", ".", "END=999", map[string]string{"GT": "0/0", "MIN_DP": "25", "GQ": "60"})},
+			config, CoverageNoCall,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tree := buildCoverageIndex(test.calls)["1"]
+			if actual, _ := classifyCoverage(test.config, tree, site); actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
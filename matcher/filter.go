@@ -0,0 +1,502 @@
+package matcher
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+// A compiled filter expression that decides whether a matched variant makes it into the report, e.g.
+//
+//	pathogenicity >= LP && stars >= 2 && gene in ("BRCA1","BRCA2") && sample.DP > 20
+//
+// Supports &&, ||, !, parentheses, ==, !=, <, <=, >, >= and in (...). Fields that hold more than
+// one value, like gene, match when any of their values match
+type Filter struct {
+	expression string
+	root       filterNode
+}
+
+func CompileFilter(expression string) (*Filter, error) {
+	tokens, err := tokenizeFilter(expression)
+	if err != nil {
+		return nil, err
+	}
+	parser := &filterParser{tokens: tokens}
+	root, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if parser.peek().kind != tokenEnd {
+		return nil, fmt.Errorf("unexpected %q in filter at position %d", parser.peek().text, parser.peek().pos)
+	}
+	return &Filter{expression: expression, root: root}, nil
+}
+
+func (filter *Filter) Match(line *vcf.VcfLine, record *clinvar.ClinvarRecord) bool {
+	return filter.root.eval(line, record)
+}
+
+func (filter *Filter) String() string {
+	return filter.expression
+}
+
+// Returns the values for a field, with multi valued fields split out. Pathogenicity fields
+// are flagged so comparisons use the pathogenicity order instead of comparing strings
+type filterField func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) []string
+
+type filterOperand struct {
+	field         filterField
+	literal       string
+	pathogenicity bool
+}
+
+func (operand filterOperand) values(line *vcf.VcfLine, record *clinvar.ClinvarRecord) []string {
+	if operand.field != nil {
+		return operand.field(line, record)
+	}
+	return []string{operand.literal}
+}
+
+var filterFields = map[string]filterField{
+	"pathogenicity": func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) []string {
+		return []string{record.Pathogenicity.ToString()}
+	},
+	"stars": func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) []string {
+		return []string{strconv.Itoa(record.Stars)}
+	},
+	"gene": func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) []string {
+		return record.Genes
+	},
+	"disease": func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) []string {
+		return record.Diseases
+	},
+	"chrom": func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) []string {
+		return []string{line.Chrom}
+	},
+	"pos": func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) []string {
+		return []string{strconv.Itoa(line.Pos)}
+	},
+	"ref": func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) []string {
+		return []string{line.Ref}
+	},
+	"alt": func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) []string {
+		return []string{line.Alt}
+	},
+	"qual": func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) []string {
+		return []string{line.Qual}
+	},
+	"filter": func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) []string {
+		return []string{line.Filter}
+	},
+	"gt": func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) []string {
+		return []string{line.GetSampleData("GT")}
+	},
+	"assessments": func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) []string {
+		return []string{strconv.Itoa(record.AssessmentCount)}
+	},
+}
+
+var pathogenicityFilterFields = map[string]bool{
+	"pathogenicity": true,
+}
+
+func init() {
+	filterFields["genes"] = filterFields["gene"]
+	filterFields["diseases"] = filterFields["disease"]
+	filterFields["zygosity"] = filterFields["gt"]
+}
+
+// Fields are the names above, sample.<FORMAT key>, sample.INFO.<key>, sample.FORMAT.<key>,
+// clinvar.<INFO key>, clinvar.INFO.<key> or any report column name. Anything else is a literal
+func resolveFilterOperand(name string) filterOperand {
+	lower := strings.ToLower(name)
+	if field, ok := filterFields[lower]; ok {
+		return filterOperand{field: field, pathogenicity: pathogenicityFilterFields[lower]}
+	}
+	if column, ok := columnRegistry[lower]; ok {
+		return filterOperand{field: columnFilterField(column.Extract), pathogenicity: lower == "max_pathogenicity"}
+	}
+	if column, err := parseFieldColumn(name); err == nil {
+		return filterOperand{field: columnFilterField(column.Extract)}
+	}
+	if strings.HasPrefix(lower, SampleColumnPrefix) && !strings.Contains(name[len(SampleColumnPrefix):], ".") {
+		return filterOperand{field: columnFilterField(sampleFormatExtractor(name[len(SampleColumnPrefix):]))}
+	}
+	if strings.HasPrefix(lower, ClinvarColumnPrefix) && !strings.Contains(name[len(ClinvarColumnPrefix):], ".") {
+		return filterOperand{field: columnFilterField(clinvarInfoExtractor(name[len(ClinvarColumnPrefix):]))}
+	}
+	return filterOperand{literal: name}
+}
+
+func columnFilterField(extract ColumnExtractor) filterField {
+	return func(line *vcf.VcfLine, record *clinvar.ClinvarRecord) []string {
+		return []string{extract(line, record)}
+	}
+}
+
+type filterNode interface {
+	eval(line *vcf.VcfLine, record *clinvar.ClinvarRecord) bool
+}
+
+type andNode struct {
+	left, right filterNode
+}
+
+func (node andNode) eval(line *vcf.VcfLine, record *clinvar.ClinvarRecord) bool {
+	return node.left.eval(line, record) && node.right.eval(line, record)
+}
+
+type orNode struct {
+	left, right filterNode
+}
+
+func (node orNode) eval(line *vcf.VcfLine, record *clinvar.ClinvarRecord) bool {
+	return node.left.eval(line, record) || node.right.eval(line, record)
+}
+
+type notNode struct {
+	node filterNode
+}
+
+func (node notNode) eval(line *vcf.VcfLine, record *clinvar.ClinvarRecord) bool {
+	return !node.node.eval(line, record)
+}
+
+// A bare field with no comparison is true when it has a value
+type presentNode struct {
+	operand filterOperand
+}
+
+func (node presentNode) eval(line *vcf.VcfLine, record *clinvar.ClinvarRecord) bool {
+	for _, value := range node.operand.values(line, record) {
+		if !isMissingFilterValue(value) {
+			return true
+		}
+	}
+	return false
+}
+
+type compareNode struct {
+	op          string
+	left, right filterOperand
+}
+
+func (node compareNode) eval(line *vcf.VcfLine, record *clinvar.ClinvarRecord) bool {
+	if node.op == "!=" {
+		return !compareNode{"==", node.left, node.right}.eval(line, record)
+	}
+	pathogenicity := node.left.pathogenicity || node.right.pathogenicity
+	for _, left := range node.left.values(line, record) {
+		for _, right := range node.right.values(line, record) {
+			if compareFilterValues(node.op, left, right, pathogenicity) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type inNode struct {
+	operand filterOperand
+	list    []filterOperand
+}
+
+func (node inNode) eval(line *vcf.VcfLine, record *clinvar.ClinvarRecord) bool {
+	for _, item := range node.list {
+		if (compareNode{"==", node.operand, item}).eval(line, record) {
+			return true
+		}
+	}
+	return false
+}
+
+func isMissingFilterValue(value string) bool {
+	return value == "" || value == "."
+}
+
+func compareFilterValues(op, left, right string, pathogenicity bool) bool {
+	if pathogenicity {
+		leftPathogenicity, leftOk := clinvar.ParsePathogenicity(left)
+		rightPathogenicity, rightOk := clinvar.ParsePathogenicity(right)
+		if !leftOk || !rightOk {
+			return false
+		}
+		if op == "==" {
+			return leftPathogenicity == rightPathogenicity
+		}
+		// Other isn't part of the benign to pathogenic scale, so it can't be ordered
+		if leftPathogenicity == clinvar.PathogenicityOther || rightPathogenicity == clinvar.PathogenicityOther {
+			return false
+		}
+		return compareOrdered(op, float64(leftPathogenicity), float64(rightPathogenicity))
+	}
+
+	leftNumber, leftErr := strconv.ParseFloat(left, 64)
+	rightNumber, rightErr := strconv.ParseFloat(right, 64)
+	numeric := leftErr == nil && rightErr == nil
+	if op == "==" {
+		if numeric {
+			return leftNumber == rightNumber
+		}
+		return strings.EqualFold(left, right)
+	}
+	if !numeric {
+		return false
+	}
+	return compareOrdered(op, leftNumber, rightNumber)
+}
+
+func compareOrdered(op string, left, right float64) bool {
+	switch op {
+	case "<":
+		return left < right
+	case "<=":
+		return left <= right
+	case ">":
+		return left > right
+	case ">=":
+		return left >= right
+	}
+	return false
+}
+
+type filterTokenKind int
+
+const (
+	tokenEnd filterTokenKind = iota
+	tokenWord
+	tokenString
+	tokenOperator
+	tokenLeftParen
+	tokenRightParen
+	tokenComma
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	text string
+	pos  int
+}
+
+var filterOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "="}
+
+func tokenizeFilter(expression string) ([]filterToken, error) {
+	tokens := make([]filterToken, 0)
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, filterToken{tokenLeftParen, "(", i})
+			i++
+		case r == ')':
+			tokens = append(tokens, filterToken{tokenRightParen, ")", i})
+			i++
+		case r == ',':
+			tokens = append(tokens, filterToken{tokenComma, ",", i})
+			i++
+		case r == '"' || r == '\'':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end == len(runes) {
+				return tokens, fmt.Errorf("unterminated string in filter at position %d", i)
+			}
+			tokens = append(tokens, filterToken{tokenString, string(runes[i+1 : end]), i})
+			i = end + 1
+		case isFilterWordRune(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			end := i + 1
+			for end < len(runes) && isFilterWordRune(runes[end]) {
+				end++
+			}
+			tokens = append(tokens, filterToken{tokenWord, string(runes[i:end]), i})
+			i = end
+		default:
+			matched := false
+			for _, op := range filterOperators {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					text := op
+					// Allow a single = as shorthand for ==
+					if op == "=" {
+						text = "=="
+					}
+					tokens = append(tokens, filterToken{tokenOperator, text, i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return tokens, fmt.Errorf("unexpected %q in filter at position %d", r, i)
+			}
+		}
+	}
+	return append(tokens, filterToken{tokenEnd, "", len(runes)}), nil
+}
+
+func isFilterWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.'
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (parser *filterParser) peek() filterToken {
+	return parser.tokens[parser.pos]
+}
+
+func (parser *filterParser) next() filterToken {
+	token := parser.tokens[parser.pos]
+	if token.kind != tokenEnd {
+		parser.pos++
+	}
+	return token
+}
+
+func (parser *filterParser) parseOr() (filterNode, error) {
+	left, err := parser.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for parser.peek().kind == tokenOperator && parser.peek().text == "||" {
+		parser.next()
+		right, err := parser.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (parser *filterParser) parseAnd() (filterNode, error) {
+	left, err := parser.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for parser.peek().kind == tokenOperator && parser.peek().text == "&&" {
+		parser.next()
+		right, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (parser *filterParser) parseUnary() (filterNode, error) {
+	token := parser.peek()
+	if token.kind == tokenOperator && token.text == "!" {
+		parser.next()
+		node, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{node}, nil
+	}
+	if token.kind == tokenLeftParen {
+		parser.next()
+		node, err := parser.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := parser.next(); closing.kind != tokenRightParen {
+			return nil, fmt.Errorf("expected ) in filter at position %d", closing.pos)
+		}
+		return node, nil
+	}
+	return parser.parseComparison()
+}
+
+func (parser *filterParser) parseComparison() (filterNode, error) {
+	left, err := parser.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	token := parser.peek()
+	if token.kind == tokenWord && strings.ToLower(token.text) == "in" {
+		parser.next()
+		list, err := parser.parseList(left)
+		if err != nil {
+			return nil, err
+		}
+		return inNode{left, list}, nil
+	}
+	if token.kind != tokenOperator || token.text == "&&" || token.text == "||" || token.text == "!" {
+		if left.field == nil {
+			return nil, fmt.Errorf("%q is not a filter field", left.literal)
+		}
+		return presentNode{left}, nil
+	}
+
+	parser.next()
+	right, err := parser.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPathogenicityOperands(left, right); err != nil {
+		return nil, err
+	}
+	return compareNode{token.text, left, right}, nil
+}
+
+func (parser *filterParser) parseList(operand filterOperand) ([]filterOperand, error) {
+	if open := parser.next(); open.kind != tokenLeftParen {
+		return nil, fmt.Errorf("expected ( after in at position %d", open.pos)
+	}
+	list := make([]filterOperand, 0)
+	for {
+		item, err := parser.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkPathogenicityOperands(operand, item); err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+		token := parser.next()
+		if token.kind == tokenRightParen {
+			return list, nil
+		}
+		if token.kind != tokenComma {
+			return nil, fmt.Errorf("expected , or ) in filter at position %d", token.pos)
+		}
+	}
+}
+
+func (parser *filterParser) parseOperand() (filterOperand, error) {
+	token := parser.next()
+	switch token.kind {
+	case tokenString:
+		return filterOperand{literal: token.text}, nil
+	case tokenWord:
+		return resolveFilterOperand(token.text), nil
+	case tokenEnd:
+		return filterOperand{}, fmt.Errorf("unexpected end of filter")
+	}
+	return filterOperand{}, fmt.Errorf("unexpected %q in filter at position %d", token.text, token.pos)
+}
+
+// Catches typos like pathogenicity >= LPP when the filter is compiled rather than silently matching nothing
+func checkPathogenicityOperands(left, right filterOperand) error {
+	for _, pair := range [][2]filterOperand{{left, right}, {right, left}} {
+		if pair[0].pathogenicity && pair[1].field == nil {
+			if _, ok := clinvar.ParsePathogenicity(pair[1].literal); !ok {
+				return fmt.Errorf("%q is not a pathogenicity, use one of B, LB, VUS, LP, P or Other", pair[1].literal)
+			}
+		}
+	}
+	return nil
+}
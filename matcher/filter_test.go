@@ -0,0 +1,121 @@
+package matcher
+
+import (
+	"testing"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+func filterFixture() (*vcf.VcfLine, *clinvar.ClinvarRecord) {
+	line := &vcf.VcfLine{
+		Chrom:      "17",
+		Pos:        41245466,
+		Ref:        "G",
+		Alt:        "A",
+		Qual:       "60",
+		Filter:     "PASS",
+		SampleData: map[string]string{"GT": "0/1", "DP": "35", "GQ": "99"},
+	}
+	variant := &vcf.VcfLine{Chrom: "17", Pos: 41245466, Ref: "G", Alt: "A", InfoText: "CLNSIG=Pathogenic;GENEINFO=BRCA1:672"}
+	record := &clinvar.ClinvarRecord{
+		Variant:         variant,
+		Pathogenicity:   clinvar.PathogenicityLikelyPathogenic,
+		AssessmentCount: 4,
+		Stars:           2,
+		Genes:           []string{"BRCA1", "NBR2"},
+		Diseases:        []string{"Hereditary breast ovarian cancer syndrome"},
+	}
+	return line, record
+}
+
+func TestFilterMatch(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   bool
+	}{
+		{"pathogenicity >= LP", true},
+		{"pathogenicity > LP", false},
+		{"pathogenicity == likely_pathogenic", true},
+		{"pathogenicity = lp", true},
+		{"pathogenicity != P", true},
+		{"pathogenicity < VUS", false},
+		{"stars >= 2", true},
+		{"stars > 2", false},
+		{"assessments == 4.0", true},
+		{"gene == NBR2", true},
+		{"gene in (\"BRCA2\", \"BRCA1\")", true},
+		{"gene in (TP53, PTEN)", false},
+		{"gene != BRCA1", false},
+		{"sample.DP > 20", true},
+		{"sample.DP > 40", false},
+		{"sample.AD", false},
+		{"sample.GQ", true},
+		{"chrom == 17 && pos >= 41245000 && pos <= 41246000", true},
+		{"ref == 'g' && alt == 'a'", true},
+		{"filter == PASS || qual < 10", true},
+		{"filter != PASS || qual < 10", false},
+		{"!(stars < 2)", true},
+		{"!stars", false},
+		{"stars >= 3 || pathogenicity >= LP && sample.DP > 20", true},
+		{"(stars >= 3 || pathogenicity >= LP) && sample.DP > 40", false},
+		{"clinvar.CLNSIG == Pathogenic", true},
+		{"pos > -1", true},
+		{"disease == 'hereditary breast ovarian cancer syndrome'", true},
+	}
+	line, record := filterFixture()
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			filter, err := CompileFilter(test.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := filter.Match(line, record); actual != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestFilterOtherIsUnordered(t *testing.T) {
+	line, record := filterFixture()
+	record.Pathogenicity = clinvar.PathogenicityOther
+	for _, expression := range []string{"pathogenicity >= B", "pathogenicity <= P"} {
+		filter, err := CompileFilter(expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filter.Match(line, record) {
+			t.Errorf("%s matched Other", expression)
+		}
+	}
+	filter, err := CompileFilter("pathogenicity == other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filter.Match(line, record) {
+		t.Errorf("pathogenicity == other didn't match Other")
+	}
+}
+
+func TestCompileFilterErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"pathogenicity >=",
+		"pathogenicity >= LPP",
+		"gene in BRCA1",
+		"gene in (BRCA1 BRCA2)",
+		"(stars >= 2",
+		"stars >= 2)",
+		"gene == 'BRCA1",
+		"stars >= 2 &&",
+		"stars ~ 2",
+		"notafield",
+		"stars >= 2 stars",
+	}
+	for _, expression := range tests {
+		if _, err := CompileFilter(expression); err == nil {
+			t.Errorf("expected %q not to compile", expression)
+		}
+	}
+}
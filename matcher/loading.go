@@ -0,0 +1,86 @@
+package matcher
+
+import (
+	"fmt"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/rawdata"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	LoadingAuto    = "auto"
+	LoadingFull    = "full"
+	LoadingIndexed = "indexed"
+)
+
+var ClinvarLoadings = []string{LoadingAuto, LoadingFull, LoadingIndexed}
+
+// Past this many regions, one pass through all of ClinVar is quicker than seeking around the index
+const maxIndexedRegions = 10000
+
+// Sample variants closer than this share a query, since they're likely in the same BGZF blocks
+const indexedRegionGap = 1000
+
+// The parts of ClinVar a report needs, or why it needs all of it
+func indexedRegions(config ReportConfig, selector *variantSelector, format string, variants []*vcf.VcfLine) ([]vcf.Region, error) {
+	switch {
+	case format != rawdata.FormatVcf:
+		return nil, fmt.Errorf("raw genotype files need all of ClinVar to work out their alleles")
+	case config.RsidFallback:
+		return nil, fmt.Errorf("rsID matching needs all of ClinVar")
+	case config.Mode == ModeCoverage:
+		if selector.regions == nil {
+			return nil, fmt.Errorf("coverage mode checks every P/LP site unless there's a BED file of regions")
+		}
+		return vcf.MergeRegions(selector.regions.List(), indexedRegionGap), nil
+	}
+
+	regions := make([]vcf.Region, 0)
+	for _, line := range variants {
+		if line.IsReferenceBlock() || !selector.acceptsVariant(line) {
+			continue
+		}
+		start, end := line.Pos, line.BlockEnd()
+		if sv, ok := line.StructuralVariant(); ok && sv.CIPos[0] < 0 {
+			start += sv.CIPos[0]
+		}
+		regions = append(regions, vcf.Region{
+			Chrom: line.Chrom,
+			Start: start - config.NearbyWindow,
+			End:   end + config.NearbyWindow,
+		})
+	}
+	return vcf.MergeRegions(regions, indexedRegionGap), nil
+}
+
+// Loads all of ClinVar, or with a bgzipped ClinVar VCF and its index, only the regions the sample's
+// variants are in. Auto uses the index when there is one and the report doesn't need too many regions
+func loadClinvar(config ReportConfig, selector *variantSelector, clinvarPath string, submissionPath string, format string, variants []*vcf.VcfLine) (*clinvar.ClinvarClient, error) {
+	if config.ClinvarLoading == LoadingFull {
+		return clinvar.NewClinvarContext(selector.ctx, clinvarPath, submissionPath, selector.observer)
+	}
+	regions, err := indexedRegions(config, selector, format, variants)
+	if err == nil {
+		if _, ok := vcf.FindIndex(clinvarPath); !ok {
+			err = fmt.Errorf("there's no %s or %s index next to %s", vcf.TabixIndexSuffix, vcf.CsiIndexSuffix, clinvarPath)
+		} else if bgzf, bgzfErr := vcf.IsBgzf(clinvarPath); bgzfErr != nil || !bgzf {
+			err = fmt.Errorf("%s isn't compressed with bgzip", clinvarPath)
+		}
+	}
+	if err == nil && config.ClinvarLoading != LoadingIndexed && len(regions) > maxIndexedRegions {
+		err = fmt.Errorf("the sample needs %d regions", len(regions))
+	}
+
+	if err != nil {
+		if config.ClinvarLoading == LoadingIndexed {
+			return nil, fmt.Errorf("can't use the ClinVar index, %v", err)
+		}
+		log.Infof("Loading all of ClinVar, %v", err)
+		return clinvar.NewClinvarContext(selector.ctx, clinvarPath, submissionPath, selector.observer)
+	}
+	log.Infof("Using the ClinVar index to load the %d regions the sample needs", len(regions))
+	return clinvar.NewClinvarForRegionsContext(selector.ctx, clinvarPath, submissionPath, regions, selector.observer)
+}
@@ -1,15 +1,20 @@
 package matcher
 
 import (
+	"bufio"
+	"context"
 	"encoding/csv"
 	"fmt"
+	"io"
 	"os"
 	"path"
-	"strconv"
 	"strings"
 
 	"github.com/kazmiekr/clinvar-matcher/clinvar"
 	"github.com/kazmiekr/clinvar-matcher/downloader"
+	"github.com/kazmiekr/clinvar-matcher/panel"
+	"github.com/kazmiekr/clinvar-matcher/progress"
+	"github.com/kazmiekr/clinvar-matcher/rawdata"
 	"github.com/kazmiekr/clinvar-matcher/vcf"
 
 	log "github.com/sirupsen/logrus"
@@ -22,6 +27,17 @@ const (
 	PassFilter         = "pass"
 )
 
+const (
+	ModeAssessment        = "assessment"
+	ModeSecondaryFindings = "secondary-findings"
+	ModeCarrierScreening  = "carrier-screening"
+	ModeTrio              = "trio"
+	ModeCoverage          = "coverage"
+	ModeCohort            = "cohort"
+)
+
+var ReportModes = []string{ModeAssessment, ModeSecondaryFindings, ModeCarrierScreening, ModeTrio, ModeCoverage, ModeCohort}
+
 type ReportConfig struct {
 	SourceVcfPath         string
 	OutputFile            string
@@ -29,6 +45,36 @@ type ReportConfig struct {
 	ClinvarSubmissionPath string
 	IncludeAllVariants    bool
 	SaveDownloads         bool
+	Columns               []string
+	Filter                string
+	Genes                 []string
+	// Genes are only gene symbols and never gene list files, for input that can't be trusted with
+	// the filesystem like the server's
+	GeneSymbolsOnly bool
+	RegionsPath     string
+	// Match on rsID when the position and alleles aren't found in ClinVar
+	RsidFallback bool
+	// Try the opposite strand and swapped REF/ALT for SNVs that don't match as they are
+	AlleleTransforms bool
+	// Smallest reciprocal overlap for matching SVs with symbolic alleles against ClinVar, 0 turns it off
+	SVOverlap float64
+	// One of the ReportModes, defaults to ModeAssessment
+	Mode                     string
+	SecondaryFindingsVersion string
+	// Sample sex for carrier screening, male, female or auto to infer it from the X chromosome
+	Sex string
+	// PED file describing the families in a multi-sample VCF, for trio mode
+	PedPath string
+	// Reference calls below these in coverage mode are low quality, MIN_DP is used for gVCF blocks
+	MinDepth           int
+	MinGenotypeQuality int
+	// One of ClinvarLoadings, whether to load all of ClinVar or use its index to load what's needed
+	ClinvarLoading string
+	// When above 0, ClinVar variants within this many bp of a sample variant are written to a
+	// separate nearby report
+	NearbyWindow int
+	// Goroutines parsing and matching the sample's variants, 0 uses every CPU
+	Workers int
 }
 
 // Will return the clinvar rsid first, otherwise it'll try to use the vcf id
@@ -43,36 +89,205 @@ func isPassingVariantFilter(filter string) bool {
 	return strings.ToLower(filter) == PassFilter
 }
 
-func GenerateAssessmentReport(config ReportConfig) error {
+// An empty expression means no filter
+func compileReportFilter(expression string) (*Filter, error) {
+	if strings.TrimSpace(expression) == "" {
+		return nil, nil
+	}
+	return CompileFilter(expression)
+}
 
-	downloads := make([]string, 0)
-	// If user did not specify clinvar VCF file, download latest
-	clinvarFile := config.ClinvarVcfPath
-	if strings.Index(clinvarFile, "https://") != -1 {
-		description := "Downloading Clinvar VCF"
-		localFile := path.Base(clinvarFile)
-		err := downloader.DownloadFile(localFile, clinvarFile, description)
-		if err != nil {
-			return err
-		}
-		downloads = append(downloads, localFile)
-		clinvarFile = localFile
+// A sample variant along with what ClinVar has for it
+type MatchedVariant struct {
+	Line   *vcf.VcfLine
+	Record *clinvar.ClinvarRecord
+}
+
+// Decides which sample variants make it into a report, applying the quality filter, panel and
+// filter expression around the ClinVar lookup
+type variantSelector struct {
+	client             *clinvar.ClinvarClient
+	matchOptions       clinvar.MatchOptions
+	includeAllVariants bool
+	filter             *Filter
+	genes              panel.GeneSet
+	regions            *panel.Regions
+	workers            int
+	// Cancels the run and hears about its progress, either can be nil
+	ctx      context.Context
+	observer progress.Observer
+	matches  int
+	// Matches written to the report, and how many of them have each max pathogenicity, which is only
+	// counted when it's set
+	reportedMatches     int
+	pathogenicityCounts map[clinvar.Pathogenicity]int
+}
+
+func newVariantSelector(config ReportConfig) (*variantSelector, error) {
+	filter, err := compileReportFilter(config.Filter)
+	if err != nil {
+		return nil, err
+	}
+	genes, regions, err := loadPanel(config)
+	if err != nil {
+		return nil, err
 	}
+	return &variantSelector{
+		matchOptions: clinvar.MatchOptions{
+			RsidFallback:     config.RsidFallback,
+			AlleleTransforms: config.AlleleTransforms,
+			SVOverlap:        config.SVOverlap,
+		},
+		includeAllVariants: config.IncludeAllVariants,
+		filter:             filter,
+		genes:              genes,
+		regions:            regions,
+		workers:            config.Workers,
+	}, nil
+}
 
-	// If user did not specify clinvar submissions file, download latest
-	clinvarSubmissionFile := config.ClinvarSubmissionPath
-	if strings.Index(clinvarSubmissionFile, "https://") != -1 {
-		description := "Downloading Clinvar Submissions"
-		localFile := path.Base(clinvarSubmissionFile)
-		err := downloader.DownloadFile(localFile, clinvarSubmissionFile, description)
-		if err != nil {
+// The matched line has the alleles and genotype as ClinVar has them, when a transform was needed to match
+func (selector *variantSelector) Match(line *vcf.VcfLine) (*MatchedVariant, bool) {
+	if !selector.acceptsVariant(line) {
+		return nil, false
+	}
+	clinvarMatch, ok := selector.client.Match(line, selector.matchOptions)
+	if !ok {
+		return nil, false
+	}
+	line = clinvar.ApplyTransform(line, clinvarMatch)
+	if !selector.acceptsRecord(line, clinvarMatch) {
+		return nil, false
+	}
+	return &MatchedVariant{Line: line, Record: clinvarMatch}, true
+}
+
+// Checks the sample variant on its own, before it's looked up in ClinVar
+func (selector *variantSelector) acceptsVariant(line *vcf.VcfLine) bool {
+	// Quality filter
+	if selector.includeAllVariants == false && isPassingVariantFilter(line.Filter) == false {
+		return false
+	}
+	return selector.regions == nil || selector.regions.ContainsVariant(line)
+}
+
+func (selector *variantSelector) acceptsRecord(line *vcf.VcfLine, record *clinvar.ClinvarRecord) bool {
+	if len(selector.genes) > 0 && !selector.genes.ContainsAny(record.GeneSymbols()) {
+		return false
+	}
+	return selector.filter == nil || selector.filter.Match(line, record)
+}
+
+func collectMatches(selector *variantSelector, variants []*vcf.VcfLine) ([]*MatchedVariant, error) {
+	matches := make([]*MatchedVariant, 0)
+	err := selector.matchInOrder(variants, func(match *MatchedVariant) error {
+		matches = append(matches, match)
+		return nil
+	})
+	return matches, err
+}
+
+// Loads the gene list and BED regions to restrict matching to, either can be empty
+func loadPanel(config ReportConfig) (panel.GeneSet, *panel.Regions, error) {
+	parseGenes := panel.ParseGenes
+	if config.GeneSymbolsOnly {
+		parseGenes = panel.ParseGeneSymbols
+	}
+	genes, err := parseGenes(config.Genes)
+	if err != nil {
+		return genes, nil, err
+	}
+	if len(genes) > 0 {
+		log.Infof("Restricting matches to %d genes", len(genes))
+	}
+	if config.RegionsPath == "" {
+		return genes, nil, nil
+	}
+	regions, err := panel.ReadBed(config.RegionsPath)
+	if err != nil {
+		return genes, nil, err
+	}
+	log.Infof("Restricting matches to %d regions from %s", regions.Len(), config.RegionsPath)
+	return genes, regions, nil
+}
+
+// Checks the config before spending time on downloads
+func validateConfig(config ReportConfig) error {
+	if _, err := ResolveColumns(reportColumnNames(config)); err != nil {
+		return err
+	}
+	if _, err := compileReportFilter(config.Filter); err != nil {
+		return err
+	}
+	switch config.ClinvarLoading {
+	case "", LoadingAuto, LoadingFull, LoadingIndexed:
+	default:
+		return fmt.Errorf("unknown ClinVar loading %s, use one of %s", config.ClinvarLoading, strings.Join(ClinvarLoadings, ", "))
+	}
+	if config.SVOverlap < 0 || config.SVOverlap > 1 {
+		return fmt.Errorf("the SV overlap has to be a fraction between 0 and 1, got %v", config.SVOverlap)
+	}
+	if config.NearbyWindow < 0 {
+		return fmt.Errorf("the nearby window can't be negative, got %d", config.NearbyWindow)
+	}
+	if config.NearbyWindow > 0 && config.OutputFile == vcf.StdioPath {
+		return fmt.Errorf("the nearby report is written next to the output file, so it needs an output file rather than -")
+	}
+	stdinInputs := 0
+	for _, input := range []string{config.SourceVcfPath, config.ClinvarVcfPath, config.ClinvarSubmissionPath} {
+		if input == vcf.StdioPath {
+			stdinInputs++
+		}
+	}
+	if stdinInputs > 1 {
+		return fmt.Errorf("only one input can be read from standard input")
+	}
+	switch config.Mode {
+	case "", ModeAssessment:
+	case ModeSecondaryFindings:
+		if _, err := panel.SecondaryFindingsGenes(config.SecondaryFindingsVersion); err != nil {
 			return err
 		}
-		downloads = append(downloads, localFile)
-		clinvarSubmissionFile = localFile
+	case ModeCarrierScreening:
+		if _, err := parseSex(config.Sex); err != nil {
+			return err
+		}
+	case ModeTrio:
+		if config.PedPath == "" {
+			return fmt.Errorf("trio mode needs a PED file describing the families")
+		}
+	case ModeCoverage:
+		if config.MinDepth < 0 || config.MinGenotypeQuality < 0 {
+			return fmt.Errorf("the minimum depth and genotype quality can't be negative")
+		}
+	case ModeCohort:
+		if config.OutputFile == vcf.StdioPath {
+			return fmt.Errorf("the cohort gene table is written next to the output file, so it needs an output file rather than -")
+		}
+	default:
+		return fmt.Errorf("unknown report mode %s, use one of %s", config.Mode, strings.Join(ReportModes, ", "))
+	}
+	return nil
+}
+
+func GenerateAssessmentReport(config ReportConfig) error {
+	return GenerateAssessmentReportContext(context.Background(), config, nil)
+}
+
+// Like GenerateAssessmentReport, stopping with the context's error once it's cancelled, and telling
+// the observer, which can be nil, how reading the sample and ClinVar and matching are going. The
+// downloads aren't deleted when it's cancelled, so they can be picked up again
+func GenerateAssessmentReportContext(ctx context.Context, config ReportConfig, observer progress.Observer) error {
+	if err := validateConfig(config); err != nil {
+		return err
+	}
+
+	clinvarFile, clinvarSubmissionFile, downloads, err := FetchClinvarContext(ctx, config.ClinvarVcfPath, config.ClinvarSubmissionPath)
+	if err != nil {
+		return err
 	}
 
-	err := WriteAssessedVariants(config, clinvarFile, clinvarSubmissionFile)
+	err = writeAssessedVariants(ctx, observer, config, clinvarFile, clinvarSubmissionFile)
 	if err != nil {
 		return err
 	}
@@ -89,134 +304,202 @@ func GenerateAssessmentReport(config ReportConfig) error {
 	return err
 }
 
+// Downloads the ClinVar VCF and submission summary when they're URLs, returning the local paths and
+// the files that were downloaded
+func FetchClinvar(clinvarPath string, submissionPath string) (string, string, []string, error) {
+	return FetchClinvarContext(context.Background(), clinvarPath, submissionPath)
+}
+
+// Like FetchClinvar, abandoning a download once the context is cancelled
+func FetchClinvarContext(ctx context.Context, clinvarPath string, submissionPath string) (string, string, []string, error) {
+	downloads := make([]string, 0)
+	// If user did not specify clinvar VCF file, download latest
+	clinvarFile := clinvarPath
+	if localFile, ok := DownloadPath(clinvarFile); ok {
+		description := "Downloading Clinvar VCF"
+		err := downloader.DownloadFileContext(ctx, localFile, clinvarFile, description)
+		if err != nil {
+			return clinvarFile, submissionPath, downloads, err
+		}
+		downloads = append(downloads, localFile)
+		clinvarFile = localFile
+	}
+
+	// If user did not specify clinvar submissions file, download latest
+	clinvarSubmissionFile := submissionPath
+	if localFile, ok := DownloadPath(clinvarSubmissionFile); ok {
+		description := "Downloading Clinvar Submissions"
+		err := downloader.DownloadFileContext(ctx, localFile, clinvarSubmissionFile, description)
+		if err != nil {
+			return clinvarFile, clinvarSubmissionFile, downloads, err
+		}
+		downloads = append(downloads, localFile)
+		clinvarSubmissionFile = localFile
+	}
+	return clinvarFile, clinvarSubmissionFile, downloads, nil
+}
+
+// Where FetchClinvar downloads a ClinVar URL to, false when the path isn't a URL
+func DownloadPath(clinvarPath string) (string, bool) {
+	if strings.Index(clinvarPath, "https://") == -1 {
+		return "", false
+	}
+	return path.Base(clinvarPath), true
+}
+
 func WriteAssessedVariants(config ReportConfig, localClinvarVcfPath string, localSubmissionPath string) error {
-	log.Infof("Loading vcf from %s", config.SourceVcfPath)
-	variants, err := vcf.ReadVcf(config.SourceVcfPath)
+	return writeAssessedVariants(context.Background(), nil, config, localClinvarVcfPath, localSubmissionPath)
+}
+
+func writeAssessedVariants(ctx context.Context, observer progress.Observer, config ReportConfig, localClinvarVcfPath string, localSubmissionPath string) error {
+	selector, err := newVariantSelector(config)
+	if err != nil {
+		return err
+	}
+	selector.ctx = ctx
+	selector.observer = observer
+
+	// The input is opened once so it can be standard input, which can't be read twice
+	tracker := progress.NewTracker(ctx, observer, config.SourceVcfPath)
+	input, err := vcf.OpenTracked(config.SourceVcfPath, tracker)
 	if err != nil {
 		return err
 	}
-	log.Infof("Variant Count: %d\n", len(variants))
+	defer input.Close()
+	source := bufio.NewReaderSize(input, rawdata.DetectBytes)
+	format, err := rawdata.DetectReaderFormat(source)
+	if err != nil {
+		return fmt.Errorf("%s: %v", config.SourceVcfPath, err)
+	}
 
-	clinvarClient, err := clinvar.NewClinvar(localClinvarVcfPath, localSubmissionPath)
+	header := vcf.NewHeader()
+	variants := make([]*vcf.VcfLine, 0)
+	if format == rawdata.FormatVcf {
+		log.Infof("Loading vcf from %s", config.SourceVcfPath)
+		header, variants, err = vcf.ReadVcfFromTracked(source, config.Workers, tracker)
+		if err != nil {
+			return err
+		}
+		log.Infof("Variant Count: %d\n", len(variants))
+	}
+
+	clinvarClient, err := loadClinvar(config, selector, localClinvarVcfPath, localSubmissionPath, format, variants)
 	if err != nil {
 		return err
 	}
+	selector.client = clinvarClient
 
-	matches := 0
-	counts := make(map[string]int)
+	// Raw genotype files need ClinVar loaded to know the REF and ALT at each position
+	if format != rawdata.FormatVcf {
+		log.Infof("Loading %s raw data from %s", format, config.SourceVcfPath)
+		variants, err = rawdata.ReadRawGenotypesFrom(source, format, clinvarClient)
+		if err != nil {
+			return fmt.Errorf("%s: %v", config.SourceVcfPath, err)
+		}
+		if err := tracker.Records(len(variants)); err != nil {
+			return err
+		}
+		if err := tracker.Done(); err != nil {
+			return err
+		}
+		log.Infof("Variant Count: %d\n", len(variants))
+	}
 
-	resultFile, err := os.Create(config.OutputFile)
+	resultFile, err := CreateOutput(config.OutputFile)
 	if err != nil {
 		return err
 	}
 	defer resultFile.Close()
-	writer := csv.NewWriter(resultFile)
-	defer writer.Flush()
 
-	header := []string{
-		"Chromosome",
-		"Begin",
-		"End",
-		"Var Type",
-		"Quality",
-		"Filter",
-		"Ref",
-		"Alt",
-		"Rsid",
-		"Zygosity",
-		"Clinvar ID",
-		"Assessment Count",
-		"Max Pathogenicity",
-		"# Benign",
-		"# Likely Benign",
-		"# VUS",
-		"# Likely Path",
-		"# Pathogenic",
-		"# Other",
-		"Diseases",
-		"Genes",
-		"Clinvar Link",
-		"dbSNP Link",
-		"Snpedia Link",
-		"AF_ESP",
-		"AF_EXAC",
-		"AF_TGP",
-	}
-	writer.Write(header)
+	err = writeReport(resultFile, config, selector, header, variants)
+	if err != nil || config.NearbyWindow <= 0 {
+		return err
+	}
+	return writeNearbyReport(config, selector, variants)
+}
+
+// Writes the report for sample variants against an already loaded ClinVar, for callers like the
+// server that keep ClinVar loaded between reports
+func WriteReport(resultFile io.Writer, config ReportConfig, client *clinvar.ClinvarClient, header *vcf.Header, variants []*vcf.VcfLine) error {
+	return WriteReportContext(context.Background(), resultFile, config, client, header, variants)
+}
+
+// Like WriteReport, stopping with the context's error once it's cancelled
+func WriteReportContext(ctx context.Context, resultFile io.Writer, config ReportConfig, client *clinvar.ClinvarClient, header *vcf.Header, variants []*vcf.VcfLine) error {
+	if err := validateConfig(config); err != nil {
+		return err
+	}
+	selector, err := newVariantSelector(config)
+	if err != nil {
+		return err
+	}
+	selector.client = client
+	selector.ctx = ctx
+	return writeReport(resultFile, config, selector, header, variants)
+}
 
+func writeReport(resultFile io.Writer, config ReportConfig, selector *variantSelector, header *vcf.Header, variants []*vcf.VcfLine) error {
 	if config.IncludeAllVariants {
 		log.Infof("Including ALL variants, regardless of variant quality")
 	} else {
 		log.Infof("Filtering only PASSing variants based on VCF Filter")
 	}
+	if selector.filter != nil {
+		log.Infof("Filtering matched variants with %s", selector.filter)
+	}
 
-	for _, line := range variants {
-		// Quality filter
-		if config.IncludeAllVariants == false && isPassingVariantFilter(line.Filter) == false {
-			continue
-		}
-		if clinvarMatch, ok := clinvarClient.Lookup(clinvar.ToClinvarKey(line)); ok {
-			matches++
-			pathogenicity := clinvarMatch.Variant.Info[clinvar.SignficanceKey]
-			if _, ok := counts[pathogenicity]; !ok {
-				counts[pathogenicity] = 0
-			}
-			counts[pathogenicity]++
-
-			sigs := make([]string, 0)
-			diseases := make([]string, 0)
-			genes := make([]string, 0)
-			for _, assessment := range clinvarMatch.Assessments {
-				diseases = append(diseases, assessment.ReportedPhenotypeInfo)
-				sigs = append(sigs, assessment.ClinicalSignificance)
-				genes = append(genes, assessment.SubmittedGeneSymbol)
-			}
+	switch config.Mode {
+	case ModeSecondaryFindings:
+		return writeSecondaryFindings(resultFile, config, selector, variants)
+	case ModeCarrierScreening:
+		return writeCarrierScreening(resultFile, config, selector, variants)
+	case ModeTrio:
+		return writeTrioReport(resultFile, config, selector, header, variants)
+	case ModeCoverage:
+		return writeCoverageReport(resultFile, config, selector, variants)
+	case ModeCohort:
+		return writeCohortReport(resultFile, config, selector, header, variants)
+	default:
+		return writeAssessments(resultFile, config, selector, variants)
+	}
+}
 
-			rsid := getRsid(line.ID, clinvarMatch.Variant.Info[clinvar.RSIDKey])
-			snpediaLink := ""
-			dbSNPLink := ""
-			if rsid != "" {
-				snpediaLink = fmt.Sprintf(SnpediaLinkPattern, rsid)
-				dbSNPLink = fmt.Sprintf(DbSNPLinkPattern, rsid)
-			}
+type nopWriteCloser struct {
+	io.Writer
+}
 
-			varEnd := line.Pos + len(line.Ref)
-			record := []string{
-				line.Chrom,
-				strconv.Itoa(line.Pos),
-				strconv.Itoa(varEnd),
-				clinvarMatch.Variant.Info[clinvar.VariantClassificationKey],
-				line.Qual,
-				line.Filter,
-				line.Ref,
-				line.Alt,
-				rsid,
-				line.GetSampleData("GT"),
-				clinvarMatch.Variant.ID,
-				strconv.Itoa(clinvarMatch.AssessmentCount),
-				clinvarMatch.Pathogenicity.ToString(),
-				strconv.Itoa(clinvarMatch.PathogenicityCounts[clinvar.PathogenicityBenign]),
-				strconv.Itoa(clinvarMatch.PathogenicityCounts[clinvar.PathogenicityLikelyBenign]),
-				strconv.Itoa(clinvarMatch.PathogenicityCounts[clinvar.PathogenicityVUS]),
-				strconv.Itoa(clinvarMatch.PathogenicityCounts[clinvar.PathogenicityLikelyPathogenic]),
-				strconv.Itoa(clinvarMatch.PathogenicityCounts[clinvar.PathogenicityPathogenic]),
-				strconv.Itoa(clinvarMatch.PathogenicityCounts[clinvar.PathogenicityOther]),
-				strings.Join(clinvarMatch.Diseases, ","),
-				strings.Join(clinvarMatch.Genes, ","),
-				fmt.Sprintf(ClinvarLinkPattern, clinvarMatch.Variant.ID),
-				dbSNPLink,
-				snpediaLink,
-				clinvarMatch.Variant.Info[clinvar.AFEspKey],
-				clinvarMatch.Variant.Info[clinvar.AFExacKey],
-				clinvarMatch.Variant.Info[clinvar.AFTgpKey],
-			}
-			err := writer.Write(record)
-			if err != nil {
-				return err
-			}
-		}
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// Creates the report file, or writes to standard output for -, which is left open
+func CreateOutput(outputFile string) (io.WriteCloser, error) {
+	if outputFile == vcf.StdioPath {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	return os.Create(outputFile)
+}
+
+func writeAssessments(resultFile io.Writer, config ReportConfig, selector *variantSelector, variants []*vcf.VcfLine) error {
+	columns, err := ResolveColumns(reportColumnNames(config))
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(resultFile)
+	defer writer.Flush()
+	writer.Write(ColumnHeaders(columns))
+
+	matches := 0
+	err = selector.matchInOrder(variants, func(match *MatchedVariant) error {
+		matches++
+		selector.reported(match.Record)
+		return writer.Write(ExtractRecord(columns, match.Line, match.Record))
+	})
+	if err != nil {
+		return err
 	}
 	writer.Flush()
 	log.Infof("Wrote %d assessed variants to %s\n", matches, config.OutputFile)
-	return nil
+	return writer.Error()
 }
@@ -0,0 +1,105 @@
+package matcher
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// A ClinVar variant close to a sample variant without being the same variant
+type nearbyVariant struct {
+	line     *vcf.VcfLine
+	record   *clinvar.ClinvarRecord
+	distance int
+}
+
+// The nearby report goes next to the main report, clinvar_assessments.csv gets
+// clinvar_assessments_nearby.csv
+func nearbyOutputFile(outputFile string) string {
+	extension := filepath.Ext(outputFile)
+	return fmt.Sprintf("%s_nearby%s", strings.TrimSuffix(outputFile, extension), extension)
+}
+
+func findNearbyVariants(selector *variantSelector, variants []*vcf.VcfLine, window int) []*nearbyVariant {
+	nearby := make([]*nearbyVariant, 0)
+	for _, line := range variants {
+		if line.IsReferenceBlock() || !selector.acceptsVariant(line) {
+			continue
+		}
+		for _, variant := range selector.client.VariantsNear(line, window) {
+			record, ok := selector.client.LookupVariant(variant)
+			if !ok || !selector.acceptsRecord(line, record) {
+				continue
+			}
+			nearby = append(nearby, &nearbyVariant{line: line, record: record, distance: clinvar.Distance(line, variant)})
+		}
+	}
+	return nearby
+}
+
+func writeNearbyReport(config ReportConfig, selector *variantSelector, variants []*vcf.VcfLine) error {
+	outputFile := nearbyOutputFile(config.OutputFile)
+	resultFile, err := os.Create(outputFile)
+	if err != nil {
+		return err
+	}
+	defer resultFile.Close()
+
+	nearby := findNearbyVariants(selector, variants, config.NearbyWindow)
+
+	writer := csv.NewWriter(resultFile)
+	defer writer.Flush()
+	header := []string{
+		"Chromosome",
+		"Begin",
+		"Ref",
+		"Alt",
+		"Zygosity",
+		"Clinvar Begin",
+		"Clinvar Ref",
+		"Clinvar Alt",
+		"Distance",
+		"Clinvar ID",
+		"Clinical Significance",
+		"Stars",
+		"Genes",
+		"Diseases",
+		"Clinvar Link",
+	}
+	writer.Write(header)
+	for _, variant := range nearby {
+		line := variant.line
+		clinvarLine := variant.record.Variant
+		err := writer.Write([]string{
+			line.Chrom,
+			strconv.Itoa(line.Pos),
+			line.Ref,
+			line.Alt,
+			line.GetSampleData("GT"),
+			strconv.Itoa(clinvarLine.Pos),
+			clinvarLine.Ref,
+			clinvarLine.Alt,
+			strconv.Itoa(variant.distance),
+			clinvarLine.ID,
+			variant.record.AggregatePathogenicity().ToString(),
+			strconv.Itoa(variant.record.Stars),
+			strings.Join(variant.record.GeneSymbols(), ","),
+			strings.Join(variant.record.Diseases, ","),
+			fmt.Sprintf(ClinvarLinkPattern, clinvarLine.ID),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	log.Infof("Wrote %d ClinVar variants within %dbp of sample variants to %s\n", len(nearby), config.NearbyWindow, outputFile)
+	return writer.Error()
+}
@@ -0,0 +1,122 @@
+package matcher
+
+import (
+	"runtime"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+// Sample variants handed to a matching goroutine at a time
+const matchBatchVariants = 1024
+
+// Variants matched on one of the workers, done is closed once matches is set
+type matchBatch struct {
+	variants []*vcf.VcfLine
+	matches  []*MatchedVariant
+	done     chan struct{}
+}
+
+// Matches the variants on the selector's workers, calling emit with each match in the same order as
+// the variants, so the report comes out the same however many workers there are. Stops at the
+// first error from emit, or once the selector's context is cancelled
+func (selector *variantSelector) matchInOrder(variants []*vcf.VcfLine, emit func(*MatchedVariant) error) error {
+	workers := selector.workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers == 1 || len(variants) <= matchBatchVariants {
+		for i, line := range variants {
+			if i%matchBatchVariants == 0 {
+				if err := selector.cancelled(); err != nil {
+					return err
+				}
+			}
+			if match, ok := selector.Match(line); ok {
+				selector.found(match)
+				if err := emit(match); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	work := make(chan *matchBatch, workers)
+	ordered := make(chan *matchBatch, workers*2)
+	stop := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		go func() {
+			for batch := range work {
+				for _, line := range batch.variants {
+					if match, ok := selector.Match(line); ok {
+						batch.matches = append(batch.matches, match)
+					}
+				}
+				close(batch.done)
+			}
+		}()
+	}
+	go func() {
+		defer close(work)
+		defer close(ordered)
+		for start := 0; start < len(variants); start += matchBatchVariants {
+			end := start + matchBatchVariants
+			if end > len(variants) {
+				end = len(variants)
+			}
+			batch := &matchBatch{variants: variants[start:end], done: make(chan struct{})}
+			select {
+			case ordered <- batch:
+			case <-stop:
+				return
+			}
+			work <- batch
+		}
+	}()
+
+	var err error
+	for batch := range ordered {
+		<-batch.done
+		if err != nil {
+			continue
+		}
+		if err = selector.cancelled(); err != nil {
+			close(stop)
+			continue
+		}
+		for _, match := range batch.matches {
+			selector.found(match)
+			if err = emit(match); err != nil {
+				close(stop)
+				break
+			}
+		}
+	}
+	return err
+}
+
+// Counts a match for the observer, from one goroutine at a time
+func (selector *variantSelector) found(match *MatchedVariant) {
+	selector.matches++
+	if selector.observer != nil {
+		selector.observer.MatchesFound(selector.matches)
+	}
+}
+
+// Counts a match the report writes, after any cut the report mode makes, like keeping only carrier
+// findings
+func (selector *variantSelector) reported(record *clinvar.ClinvarRecord) {
+	selector.reportedMatches++
+	if selector.pathogenicityCounts != nil {
+		selector.pathogenicityCounts[record.Pathogenicity]++
+	}
+}
+
+// The context's error once the run is cancelled
+func (selector *variantSelector) cancelled() error {
+	if selector.ctx == nil {
+		return nil
+	}
+	return selector.ctx.Err()
+}
@@ -0,0 +1,33 @@
+package matcher
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/pedigree"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+func TestCancelledSelectorStops(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	selector := &variantSelector{ctx: ctx, workers: 1}
+	variants := []*vcf.VcfLine{trioLine("1", 100, "0/1", "0/1", "0/0")}
+
+	if _, err := collectMatches(selector, variants); err != context.Canceled {
+		t.Errorf("collectMatches returned %v, expected %v", err, context.Canceled)
+	}
+	if err := newCohort().addSamples(selector, []string{"a", "b", "c"}, variants); err != context.Canceled {
+		t.Errorf("addSamples returned %v, expected %v", err, context.Canceled)
+	}
+	trio := &sampleTrio{trio: &pedigree.Trio{Child: &pedigree.Individual{Sex: pedigree.SexFemale}}, child: 0, father: 1, mother: 2}
+	if _, err := findTrioFindings(trio, selector, variants); err != context.Canceled {
+		t.Errorf("findTrioFindings returned %v, expected %v", err, context.Canceled)
+	}
+	err := WriteReportContext(ctx, ioutil.Discard, ReportConfig{Workers: 1}, &clinvar.ClinvarClient{}, vcf.NewHeader(), variants)
+	if err != context.Canceled {
+		t.Errorf("WriteReportContext returned %v, expected %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,169 @@
+package matcher
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/panel"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type secondaryFinding struct {
+	gene   panel.SecondaryFindingGene
+	match  *MatchedVariant
+	reason string
+}
+
+// Groups the P/LP matches by ACMG SF gene and keeps the ones that are reportable given the gene's
+// rules and the sample's zygosity
+func findSecondaryFindings(matches []*MatchedVariant, genes map[string]panel.SecondaryFindingGene) []*secondaryFinding {
+	candidates := make(map[string][]*MatchedVariant)
+	for _, match := range matches {
+		if !match.Record.IsPathogenic() || !match.Line.GetGenotype().HasAlt() {
+			continue
+		}
+		for _, symbol := range match.Record.GeneSymbols() {
+			gene, ok := genes[symbol]
+			if !ok || !isReportableVariant(gene, match.Record) {
+				continue
+			}
+			candidates[symbol] = append(candidates[symbol], match)
+		}
+	}
+
+	findings := make([]*secondaryFinding, 0)
+	for symbol, geneMatches := range candidates {
+		gene := genes[symbol]
+		for _, match := range geneMatches {
+			if reason, ok := secondaryFindingReason(gene, match, len(geneMatches)); ok {
+				findings = append(findings, &secondaryFinding{gene: gene, match: match, reason: reason})
+			}
+		}
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].gene.Symbol != findings[j].gene.Symbol {
+			return findings[i].gene.Symbol < findings[j].gene.Symbol
+		}
+		return findings[i].match.Line.Pos < findings[j].match.Line.Pos
+	})
+	return findings
+}
+
+func isReportableVariant(gene panel.SecondaryFindingGene, record *clinvar.ClinvarRecord) bool {
+	if len(gene.VariationIDs) > 0 {
+		found := false
+		for _, id := range gene.VariationIDs {
+			if id == record.Variant.ID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if gene.Reportable == panel.ReportTruncating {
+		return record.IsTruncating()
+	}
+	return true
+}
+
+// Explains why a variant is reportable, variantsInGene is the number of reportable P/LP variants
+// the sample has in the same gene
+func secondaryFindingReason(gene panel.SecondaryFindingGene, match *MatchedVariant, variantsInGene int) (string, bool) {
+	genotype := match.Line.GetGenotype()
+	zygosity := genotype.Zygosity()
+	switch gene.Reportable {
+	case panel.ReportHomozygous:
+		if !genotype.IsHomozygousAlt() {
+			return "", false
+		}
+		return fmt.Sprintf("%s P/LP variant, only homozygous variants are reportable", zygosity), true
+	case panel.ReportBiallelic:
+		if genotype.IsHomozygousAlt() || genotype.IsHaploid() {
+			return fmt.Sprintf("%s P/LP variant in %s gene", zygosity, gene.Inheritance), true
+		}
+		if variantsInGene > 1 {
+			return fmt.Sprintf("Possible compound heterozygote, %d P/LP variants in gene, phase unknown", variantsInGene), true
+		}
+		return "", false
+	case panel.ReportTruncating:
+		return fmt.Sprintf("%s truncating P/LP variant in %s gene", zygosity, gene.Inheritance), true
+	default:
+		return fmt.Sprintf("%s P/LP variant in %s gene", zygosity, gene.Inheritance), true
+	}
+}
+
+func writeSecondaryFindings(resultFile io.Writer, config ReportConfig, selector *variantSelector, variants []*vcf.VcfLine) error {
+	genes, err := panel.SecondaryFindingsGenes(config.SecondaryFindingsVersion)
+	if err != nil {
+		return err
+	}
+	version := config.SecondaryFindingsVersion
+	if version == "" {
+		version = panel.LatestSecondaryFindingsVersion
+	}
+	log.Infof("Screening %d genes from ACMG SF v%s", len(genes), version)
+
+	matches, err := collectMatches(selector, variants)
+	if err != nil {
+		return err
+	}
+	findings := findSecondaryFindings(matches, genes)
+
+	writer := csv.NewWriter(resultFile)
+	defer writer.Flush()
+	header := []string{
+		"Gene",
+		"Category",
+		"Phenotype",
+		"Inheritance",
+		"Reportable Variants",
+		"Chromosome",
+		"Begin",
+		"Ref",
+		"Alt",
+		"Zygosity",
+		"Clinvar ID",
+		"Clinical Significance",
+		"Stars",
+		"Diseases",
+		"Reason",
+	}
+	writer.Write(header)
+	for _, finding := range findings {
+		line := finding.match.Line
+		record := finding.match.Record
+		selector.reported(record)
+		err := writer.Write([]string{
+			finding.gene.Symbol,
+			finding.gene.Category,
+			finding.gene.Phenotype,
+			string(finding.gene.Inheritance),
+			finding.gene.Reportable.ToString(),
+			line.Chrom,
+			strconv.Itoa(line.Pos),
+			line.Ref,
+			line.Alt,
+			line.GetSampleData("GT"),
+			record.Variant.ID,
+			record.AggregatePathogenicity().ToString(),
+			strconv.Itoa(record.Stars),
+			strings.Join(record.Diseases, ","),
+			finding.reason,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	log.Infof("Wrote %d secondary findings to %s\n", len(findings), config.OutputFile)
+	return writer.Error()
+}
@@ -0,0 +1,109 @@
+package matcher
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+const (
+	SexAuto    = "auto"
+	SexMale    = "male"
+	SexFemale  = "female"
+	SexUnknown = "unknown"
+)
+
+// Pseudoautosomal regions on X for each assembly, which behave like autosomes in males
+var pseudoautosomalRegionsX = map[string][][2]int{
+	clinvar.AssemblyGRCh37: {
+		{60001, 2699520},
+		{154931044, 155260560},
+	},
+	clinvar.AssemblyGRCh38: {
+		{10001, 2781479},
+		{155701383, 156030895},
+	},
+}
+
+// Need at least this many called non-reference variants on X before guessing the sex
+const minSexInferenceVariants = 20
+
+func isXChrom(chrom string) bool {
+	return vcf.NormalizeChrom(chrom) == "X"
+}
+
+// The samples are matched against ClinVar, so they're on its assembly. GRCh37 is assumed when
+// ClinVar's doesn't say, like the default download
+func xAssembly(client *clinvar.ClinvarClient) string {
+	if client == nil || client.Assembly == "" {
+		return clinvar.AssemblyGRCh37
+	}
+	return client.Assembly
+}
+
+func isPseudoautosomal(assembly string, chrom string, pos int) bool {
+	if !isXChrom(chrom) {
+		return false
+	}
+	regions, ok := pseudoautosomalRegionsX[assembly]
+	if !ok {
+		regions = pseudoautosomalRegionsX[clinvar.AssemblyGRCh37]
+	}
+	for _, region := range regions {
+		if pos >= region[0] && pos <= region[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// X outside of the pseudoautosomal regions, where males only have one copy
+func isHemizygousRegion(assembly string, chrom string, pos int) bool {
+	return isXChrom(chrom) && !isPseudoautosomal(assembly, chrom, pos)
+}
+
+func parseSex(sex string) (string, error) {
+	sex = strings.ToLower(strings.TrimSpace(sex))
+	switch sex {
+	case "", SexAuto:
+		return SexAuto, nil
+	case SexMale, "m":
+		return SexMale, nil
+	case SexFemale, "f":
+		return SexFemale, nil
+	}
+	return "", fmt.Errorf("unknown sex %s, use male, female or auto", sex)
+}
+
+// Guesses the sample's sex from its X calls. Males only have one X, so outside the pseudoautosomal
+// regions their calls are haploid or homozygous, while females have plenty of heterozygous calls
+func inferSex(assembly string, variants []*vcf.VcfLine) string {
+	called := 0
+	heterozygous := 0
+	for _, line := range variants {
+		if !isHemizygousRegion(assembly, line.Chrom, line.Pos) {
+			continue
+		}
+		genotype := line.GetGenotype()
+		if !genotype.HasAlt() {
+			continue
+		}
+		called++
+		if genotype.IsHeterozygous() {
+			heterozygous++
+		}
+	}
+	if called < minSexInferenceVariants {
+		return SexUnknown
+	}
+	ratio := float64(heterozygous) / float64(called)
+	if ratio < 0.1 {
+		return SexMale
+	}
+	if ratio > 0.3 {
+		return SexFemale
+	}
+	return SexUnknown
+}
@@ -0,0 +1,50 @@
+package matcher
+
+import (
+	"testing"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+)
+
+func TestIsHemizygousRegion(t *testing.T) {
+	tests := []struct {
+		assembly string
+		chrom    string
+		pos      int
+		expected bool
+	}{
+		{clinvar.AssemblyGRCh37, "X", 60000, true},
+		{clinvar.AssemblyGRCh37, "X", 60001, false},
+		{clinvar.AssemblyGRCh37, "chrX", 2699520, false},
+		{clinvar.AssemblyGRCh37, "X", 2699521, true},
+		{clinvar.AssemblyGRCh37, "X", 2750000, true},
+		{clinvar.AssemblyGRCh37, "X", 155260560, false},
+		{clinvar.AssemblyGRCh37, "X", 155500000, true},
+		{clinvar.AssemblyGRCh38, "X", 10000, true},
+		{clinvar.AssemblyGRCh38, "X", 10001, false},
+		{clinvar.AssemblyGRCh38, "X", 2750000, false},
+		{clinvar.AssemblyGRCh38, "X", 2781480, true},
+		{clinvar.AssemblyGRCh38, "X", 155260560, true},
+		{clinvar.AssemblyGRCh38, "chrX", 155701383, false},
+		{clinvar.AssemblyGRCh38, "X", 156030896, true},
+		{"", "X", 2750000, true},
+		{clinvar.AssemblyGRCh37, "7", 100, false},
+	}
+	for _, test := range tests {
+		if actual := isHemizygousRegion(test.assembly, test.chrom, test.pos); actual != test.expected {
+			t.Errorf("%s %s:%d: expected hemizygous %v, got %v", test.assembly, test.chrom, test.pos, test.expected, actual)
+		}
+	}
+}
+
+func TestXAssembly(t *testing.T) {
+	if actual := xAssembly(nil); actual != clinvar.AssemblyGRCh37 {
+		t.Errorf("expected %s without ClinVar, got %s", clinvar.AssemblyGRCh37, actual)
+	}
+	if actual := xAssembly(&clinvar.ClinvarClient{}); actual != clinvar.AssemblyGRCh37 {
+		t.Errorf("expected %s when ClinVar doesn't say, got %s", clinvar.AssemblyGRCh37, actual)
+	}
+	if actual := xAssembly(&clinvar.ClinvarClient{Assembly: clinvar.AssemblyGRCh38}); actual != clinvar.AssemblyGRCh38 {
+		t.Errorf("expected %s, got %s", clinvar.AssemblyGRCh38, actual)
+	}
+}
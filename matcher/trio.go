@@ -0,0 +1,251 @@
+package matcher
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/pedigree"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	InheritanceDeNovo              = "De novo"
+	InheritanceMaternal            = "Inherited from mother"
+	InheritancePaternal            = "Inherited from father"
+	InheritanceBothParents         = "Inherited, both parents carry"
+	InheritanceHomozygousRecessive = "Homozygous recessive"
+	InheritanceHomozygousOneParent = "Homozygous, only one parent carries"
+	InheritanceXLinked             = "X-linked, inherited from mother"
+	InheritanceXLinkedDeNovo       = "X-linked, de novo"
+	InheritanceUnknown             = "Unknown"
+)
+
+// A trio from the PED file with the sample column of each person, parents are -1 when missing
+type sampleTrio struct {
+	trio   *pedigree.Trio
+	child  int
+	father int
+	mother int
+}
+
+type trioFinding struct {
+	match          *MatchedVariant
+	inheritance    string
+	compoundHet    string
+	fatherGenotype string
+	motherGenotype string
+}
+
+func buildSampleTrios(ped *pedigree.Pedigree, header *vcf.Header) []*sampleTrio {
+	inVcf := func(id string) bool {
+		return header.SampleIndex(id) != -1
+	}
+	sampleTrios := make([]*sampleTrio, 0)
+	for _, trio := range ped.Trios(inVcf) {
+		sample := &sampleTrio{
+			trio:   trio,
+			child:  header.SampleIndex(trio.Child.ID),
+			father: -1,
+			mother: -1,
+		}
+		if trio.Father != nil {
+			sample.father = header.SampleIndex(trio.Father.ID)
+		}
+		if trio.Mother != nil {
+			sample.mother = header.SampleIndex(trio.Mother.ID)
+		}
+		sampleTrios = append(sampleTrios, sample)
+	}
+	return sampleTrios
+}
+
+// Returns nil when the parent isn't in the VCF
+func parentGenotype(line *vcf.VcfLine, index int) *vcf.Genotype {
+	if index < 0 {
+		return nil
+	}
+	genotype := line.ForSample(index).GetGenotype()
+	return &genotype
+}
+
+func carriesVariant(genotype *vcf.Genotype) bool {
+	return genotype != nil && genotype.HasAlt()
+}
+
+func calledReference(genotype *vcf.Genotype) bool {
+	return genotype != nil && !genotype.IsNoCall() && !genotype.HasAlt()
+}
+
+func classifyInheritance(line *vcf.VcfLine, trio *sampleTrio, assembly string) string {
+	child := line.ForSample(trio.child).GetGenotype()
+	father := parentGenotype(line, trio.father)
+	mother := parentGenotype(line, trio.mother)
+
+	// Sons get their only X from their mother. Daughters have an X from each parent, so the X-linked
+	// labels are only for sons and daughters are classified like any other chromosome
+	if trio.trio.Child.Sex == pedigree.SexMale && isHemizygousRegion(assembly, line.Chrom, line.Pos) {
+		switch {
+		case carriesVariant(mother):
+			return InheritanceXLinked
+		case calledReference(mother):
+			return InheritanceXLinkedDeNovo
+		default:
+			return InheritanceUnknown
+		}
+	}
+
+	if child.IsHomozygousAlt() {
+		switch {
+		case carriesVariant(mother) && carriesVariant(father):
+			return InheritanceHomozygousRecessive
+		case carriesVariant(mother) && calledReference(father), carriesVariant(father) && calledReference(mother):
+			return InheritanceHomozygousOneParent
+		case calledReference(mother) && calledReference(father):
+			return InheritanceDeNovo
+		default:
+			return InheritanceUnknown
+		}
+	}
+
+	switch {
+	case carriesVariant(mother) && carriesVariant(father):
+		return InheritanceBothParents
+	case carriesVariant(mother) && !carriesVariant(father):
+		return InheritanceMaternal
+	case carriesVariant(father) && !carriesVariant(mother):
+		return InheritancePaternal
+	case calledReference(mother) && calledReference(father):
+		return InheritanceDeNovo
+	default:
+		return InheritanceUnknown
+	}
+}
+
+// Checks the other heterozygous P/LP variants the child has in the same gene. Parental origin is
+// used first, falling back on the child's phased genotypes
+func classifyCompoundHet(finding *trioFinding, geneFindings []*trioFinding) string {
+	if !finding.match.Record.IsPathogenic() || !finding.match.Line.GetGenotype().IsHeterozygous() {
+		return ""
+	}
+	others := make([]*MatchedVariant, 0)
+	inTrans := false
+	for _, other := range geneFindings {
+		if other == finding || !other.match.Record.IsPathogenic() || !other.match.Line.GetGenotype().IsHeterozygous() {
+			continue
+		}
+		others = append(others, other.match)
+		if (finding.inheritance == InheritanceMaternal && other.inheritance == InheritancePaternal) ||
+			(finding.inheritance == InheritancePaternal && other.inheritance == InheritanceMaternal) {
+			inTrans = true
+		}
+	}
+	if len(others) == 0 {
+		return ""
+	}
+	if inTrans {
+		return "Yes, in trans from parental genotypes"
+	}
+	switch variantPhase(finding.match, others) {
+	case PhaseInTrans:
+		return "Yes, in trans from phased genotypes"
+	case PhaseInCis:
+		return "No, in cis"
+	default:
+		return "Possible, phase unknown"
+	}
+}
+
+// Stops with the context's error once the selector's run is cancelled
+func findTrioFindings(trio *sampleTrio, selector *variantSelector, variants []*vcf.VcfLine) ([]*trioFinding, error) {
+	findings := make([]*trioFinding, 0)
+	byGene := make(map[string][]*trioFinding)
+	assembly := xAssembly(selector.client)
+	for i, variant := range variants {
+		if i%matchBatchVariants == 0 {
+			if err := selector.cancelled(); err != nil {
+				return nil, err
+			}
+		}
+		line := variant.ForSample(trio.child)
+		if !line.GetGenotype().HasAlt() {
+			continue
+		}
+		match, ok := selector.Match(line)
+		if !ok {
+			continue
+		}
+		selector.found(match)
+		// Keep the parents' genotypes on the same alleles as the child's
+		variant = clinvar.ApplyTransform(variant, match.Record)
+		finding := &trioFinding{
+			match:       match,
+			inheritance: classifyInheritance(variant, trio, assembly),
+		}
+		if trio.father >= 0 {
+			finding.fatherGenotype = variant.ForSample(trio.father).GetSampleData("GT")
+		}
+		if trio.mother >= 0 {
+			finding.motherGenotype = variant.ForSample(trio.mother).GetSampleData("GT")
+		}
+		findings = append(findings, finding)
+		for _, gene := range match.Record.GeneSymbols() {
+			byGene[gene] = append(byGene[gene], finding)
+		}
+	}
+	for _, geneFindings := range byGene {
+		for _, finding := range geneFindings {
+			if compoundHet := classifyCompoundHet(finding, geneFindings); compoundHet != "" {
+				finding.compoundHet = compoundHet
+			}
+		}
+	}
+	return findings, nil
+}
+
+func writeTrioReport(resultFile io.Writer, config ReportConfig, selector *variantSelector, header *vcf.Header, variants []*vcf.VcfLine) error {
+	columns, err := ResolveColumns(reportColumnNames(config))
+	if err != nil {
+		return err
+	}
+	ped, err := pedigree.ReadPed(config.PedPath)
+	if err != nil {
+		return err
+	}
+	trios := buildSampleTrios(ped, header)
+	if len(trios) == 0 {
+		return fmt.Errorf("no one in %s has both their own and a parent's sample in the VCF", config.PedPath)
+	}
+	log.Infof("Found %d families and %d children with parents in the VCF", len(ped.Families()), len(trios))
+
+	writer := csv.NewWriter(resultFile)
+	defer writer.Flush()
+	reportHeader := []string{"Family", "Proband", "Proband Sex"}
+	reportHeader = append(reportHeader, ColumnHeaders(columns)...)
+	reportHeader = append(reportHeader, "Father GT", "Mother GT", "Inheritance", "Compound Heterozygous")
+	writer.Write(reportHeader)
+
+	total := 0
+	for _, trio := range trios {
+		findings, err := findTrioFindings(trio, selector, variants)
+		if err != nil {
+			return err
+		}
+		for _, finding := range findings {
+			selector.reported(finding.match.Record)
+			record := []string{trio.trio.FamilyID, trio.trio.Child.ID, trio.trio.Child.Sex}
+			record = append(record, ExtractRecord(columns, finding.match.Line, finding.match.Record)...)
+			record = append(record, finding.fatherGenotype, finding.motherGenotype, finding.inheritance, finding.compoundHet)
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+		total += len(findings)
+	}
+	writer.Flush()
+	log.Infof("Wrote %d trio findings to %s\n", total, config.OutputFile)
+	return writer.Error()
+}
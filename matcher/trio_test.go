@@ -0,0 +1,95 @@
+package matcher
+
+import (
+	"testing"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/pedigree"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+func trioLine(chrom string, pos int, genotypes ...string) *vcf.VcfLine {
+	samples := make([]map[string]string, len(genotypes))
+	for i, genotype := range genotypes {
+		samples[i] = map[string]string{"GT": genotype}
+	}
+	return &vcf.VcfLine{Chrom: chrom, Pos: pos, Ref: "A", Alt: "G", Samples: samples, SampleData: samples[0]}
+}
+
+func TestClassifyInheritance(t *testing.T) {
+	tests := []struct {
+		name     string
+		chrom    string
+		pos      int
+		sex      string
+		child    string
+		father   string
+		mother   string
+		expected string
+	}{
+		{"both parents het", "1", 100, pedigree.SexFemale, "0/1", "0/1", "0/1", InheritanceBothParents},
+		{"mother het", "1", 100, pedigree.SexFemale, "0/1", "0/0", "0/1", InheritanceMaternal},
+		{"father het", "1", 100, pedigree.SexFemale, "0/1", "0/1", "0/0", InheritancePaternal},
+		{"father het mother no call", "1", 100, pedigree.SexFemale, "0/1", "0/1", "./.", InheritancePaternal},
+		{"de novo", "1", 100, pedigree.SexFemale, "0/1", "0/0", "0/0", InheritanceDeNovo},
+		{"het with no call parent", "1", 100, pedigree.SexFemale, "0/1", "0/0", "./.", InheritanceUnknown},
+		{"homozygous recessive", "1", 100, pedigree.SexFemale, "1/1", "0/1", "0/1", InheritanceHomozygousRecessive},
+		{"homozygous one parent", "1", 100, pedigree.SexFemale, "1/1", "0/0", "0/1", InheritanceHomozygousOneParent},
+		{"homozygous other parent", "1", 100, pedigree.SexFemale, "1/1", "0/1", "0/0", InheritanceHomozygousOneParent},
+		{"homozygous both reference", "1", 100, pedigree.SexFemale, "1/1", "0/0", "0/0", InheritanceDeNovo},
+		{"homozygous reference and no call", "1", 100, pedigree.SexFemale, "1/1", "0/0", "./.", InheritanceUnknown},
+		{"homozygous carrier and no call", "1", 100, pedigree.SexFemale, "1/1", "./.", "0/1", InheritanceUnknown},
+		{"homozygous both no call", "1", 100, pedigree.SexFemale, "1/1", "./.", "./.", InheritanceUnknown},
+		{"son x-linked", "X", 5000000, pedigree.SexMale, "1", "0", "0/1", InheritanceXLinked},
+		{"son x-linked de novo", "X", 5000000, pedigree.SexMale, "1", "0", "0/0", InheritanceXLinkedDeNovo},
+		{"son x-linked mother no call", "X", 5000000, pedigree.SexMale, "1", "1", "./.", InheritanceUnknown},
+		{"son pseudoautosomal", "X", 100000, pedigree.SexMale, "0/1", "0/1", "0/0", InheritancePaternal},
+		{"daughter x from hemizygous father", "X", 5000000, pedigree.SexFemale, "0/1", "1", "0/0", InheritancePaternal},
+		{"daughter x from mother", "X", 5000000, pedigree.SexFemale, "0/1", "0", "0/1", InheritanceMaternal},
+		{"daughter x de novo", "X", 5000000, pedigree.SexFemale, "0/1", "0", "0/0", InheritanceDeNovo},
+		{"daughter x homozygous", "X", 5000000, pedigree.SexFemale, "1/1", "1", "0/1", InheritanceHomozygousRecessive},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			trio := &sampleTrio{
+				trio: &pedigree.Trio{
+					Child:  &pedigree.Individual{ID: "child", Sex: test.sex},
+					Father: &pedigree.Individual{ID: "father", Sex: pedigree.SexMale},
+					Mother: &pedigree.Individual{ID: "mother", Sex: pedigree.SexFemale},
+				},
+				child:  0,
+				father: 1,
+				mother: 2,
+			}
+			line := trioLine(test.chrom, test.pos, test.child, test.father, test.mother)
+			if actual := classifyInheritance(line, trio, clinvar.AssemblyGRCh37); actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestClassifyInheritanceMissingParent(t *testing.T) {
+	trio := &sampleTrio{
+		trio:   &pedigree.Trio{Child: &pedigree.Individual{ID: "child", Sex: pedigree.SexFemale}},
+		child:  0,
+		father: -1,
+		mother: 1,
+	}
+	tests := []struct {
+		child    string
+		mother   string
+		expected string
+	}{
+		{"0/1", "0/1", InheritanceMaternal},
+		{"0/1", "0/0", InheritanceUnknown},
+		{"1/1", "0/1", InheritanceUnknown},
+		{"1/1", "0/0", InheritanceUnknown},
+	}
+	for _, test := range tests {
+		line := trioLine("1", 100, test.child, test.mother)
+		if actual := classifyInheritance(line, trio, clinvar.AssemblyGRCh37); actual != test.expected {
+			t.Errorf("child %s mother %s: expected %q, got %q", test.child, test.mother, test.expected, actual)
+		}
+	}
+}
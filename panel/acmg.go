@@ -0,0 +1,192 @@
+package panel
+
+import (
+	"fmt"
+	"sort"
+)
+
+type Inheritance string
+
+const (
+	InheritanceAutosomalDominant  Inheritance = "AD"
+	InheritanceAutosomalRecessive Inheritance = "AR"
+	InheritanceXLinked            Inheritance = "XL"
+)
+
+// Which P/LP variants in a gene are reportable as a secondary finding
+type ReportableVariants int
+
+const (
+	// Any P/LP variant, including a single heterozygous one
+	ReportAllVariants ReportableVariants = iota
+	// Two P/LP variants, or one homozygous variant
+	ReportBiallelic
+	// Only homozygous variants
+	ReportHomozygous
+	// Only truncating variants, like nonsense, frameshift and canonical splice site changes
+	ReportTruncating
+)
+
+var ReportableVariantsToString = map[ReportableVariants]string{
+	ReportAllVariants: "All P/LP",
+	ReportBiallelic:   "Biallelic P/LP",
+	ReportHomozygous:  "Homozygous P/LP",
+	ReportTruncating:  "Truncating P/LP",
+}
+
+func (reportable ReportableVariants) ToString() string {
+	return ReportableVariantsToString[reportable]
+}
+
+const (
+	CategoryCancer         = "Cancer"
+	CategoryCardiovascular = "Cardiovascular"
+	CategoryMetabolism     = "Inborn errors of metabolism"
+	CategoryMiscellaneous  = "Miscellaneous"
+)
+
+type SecondaryFindingGene struct {
+	Symbol      string
+	Category    string
+	Phenotype   string
+	Inheritance Inheritance
+	Reportable  ReportableVariants
+	// When set, only these ClinVar variation IDs are reportable for the gene
+	VariationIDs []string
+}
+
+const LatestSecondaryFindingsVersion = "3.2"
+
+// ACMG recommendations for reporting secondary findings in clinical exome and genome sequencing
+var secondaryFindingsV3_0 = []SecondaryFindingGene{
+	{"APC", CategoryCancer, "Familial adenomatous polyposis", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"RET", CategoryCancer, "Familial medullary thyroid cancer / Multiple endocrine neoplasia type 2", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"BRCA1", CategoryCancer, "Hereditary breast and ovarian cancer", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"BRCA2", CategoryCancer, "Hereditary breast and ovarian cancer", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"PALB2", CategoryCancer, "Hereditary breast and ovarian cancer", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"SDHD", CategoryCancer, "Hereditary paraganglioma-pheochromocytoma syndrome", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"SDHAF2", CategoryCancer, "Hereditary paraganglioma-pheochromocytoma syndrome", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"SDHC", CategoryCancer, "Hereditary paraganglioma-pheochromocytoma syndrome", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"SDHB", CategoryCancer, "Hereditary paraganglioma-pheochromocytoma syndrome", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"MAX", CategoryCancer, "Hereditary paraganglioma-pheochromocytoma syndrome", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"TMEM127", CategoryCancer, "Hereditary paraganglioma-pheochromocytoma syndrome", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"BMPR1A", CategoryCancer, "Juvenile polyposis syndrome", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"SMAD4", CategoryCancer, "Juvenile polyposis syndrome", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"TP53", CategoryCancer, "Li-Fraumeni syndrome", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"MLH1", CategoryCancer, "Lynch syndrome", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"MSH2", CategoryCancer, "Lynch syndrome", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"MSH6", CategoryCancer, "Lynch syndrome", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"PMS2", CategoryCancer, "Lynch syndrome", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"MEN1", CategoryCancer, "Multiple endocrine neoplasia type 1", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"MUTYH", CategoryCancer, "MUTYH-associated polyposis", InheritanceAutosomalRecessive, ReportBiallelic, nil},
+	{"NF2", CategoryCancer, "Neurofibromatosis type 2", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"STK11", CategoryCancer, "Peutz-Jeghers syndrome", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"PTEN", CategoryCancer, "PTEN hamartoma tumor syndrome", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"RB1", CategoryCancer, "Retinoblastoma", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"TSC1", CategoryCancer, "Tuberous sclerosis complex", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"TSC2", CategoryCancer, "Tuberous sclerosis complex", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"VHL", CategoryCancer, "von Hippel-Lindau syndrome", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"WT1", CategoryCancer, "WT1-related Wilms tumor", InheritanceAutosomalDominant, ReportAllVariants, nil},
+
+	{"FBN1", CategoryCardiovascular, "Aortopathies", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"TGFBR1", CategoryCardiovascular, "Aortopathies", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"TGFBR2", CategoryCardiovascular, "Aortopathies", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"SMAD3", CategoryCardiovascular, "Aortopathies", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"ACTA2", CategoryCardiovascular, "Aortopathies", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"MYH11", CategoryCardiovascular, "Aortopathies", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"COL3A1", CategoryCardiovascular, "Ehlers-Danlos syndrome, vascular type", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"ACTC1", CategoryCardiovascular, "Hypertrophic cardiomyopathy", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"MYBPC3", CategoryCardiovascular, "Hypertrophic cardiomyopathy", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"MYH7", CategoryCardiovascular, "Hypertrophic cardiomyopathy", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"MYL2", CategoryCardiovascular, "Hypertrophic cardiomyopathy", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"MYL3", CategoryCardiovascular, "Hypertrophic cardiomyopathy", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"PRKAG2", CategoryCardiovascular, "Hypertrophic cardiomyopathy", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"TNNI3", CategoryCardiovascular, "Hypertrophic cardiomyopathy", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"TNNT2", CategoryCardiovascular, "Hypertrophic cardiomyopathy", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"TPM1", CategoryCardiovascular, "Hypertrophic cardiomyopathy", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"FLNC", CategoryCardiovascular, "Dilated cardiomyopathy", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"LMNA", CategoryCardiovascular, "Dilated cardiomyopathy", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"TTN", CategoryCardiovascular, "Dilated cardiomyopathy", InheritanceAutosomalDominant, ReportTruncating, nil},
+	{"DSC2", CategoryCardiovascular, "Arrhythmogenic right ventricular cardiomyopathy", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"DSG2", CategoryCardiovascular, "Arrhythmogenic right ventricular cardiomyopathy", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"DSP", CategoryCardiovascular, "Arrhythmogenic right ventricular cardiomyopathy", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"PKP2", CategoryCardiovascular, "Arrhythmogenic right ventricular cardiomyopathy", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"TMEM43", CategoryCardiovascular, "Arrhythmogenic right ventricular cardiomyopathy", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"RYR2", CategoryCardiovascular, "Catecholaminergic polymorphic ventricular tachycardia", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"CASQ2", CategoryCardiovascular, "Catecholaminergic polymorphic ventricular tachycardia", InheritanceAutosomalRecessive, ReportBiallelic, nil},
+	{"TRDN", CategoryCardiovascular, "Catecholaminergic polymorphic ventricular tachycardia", InheritanceAutosomalRecessive, ReportBiallelic, nil},
+	{"KCNQ1", CategoryCardiovascular, "Long QT syndrome types 1 and 2", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"KCNH2", CategoryCardiovascular, "Long QT syndrome types 1 and 2", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"SCN5A", CategoryCardiovascular, "Long QT syndrome type 3 / Brugada syndrome", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"LDLR", CategoryCardiovascular, "Familial hypercholesterolemia", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"APOB", CategoryCardiovascular, "Familial hypercholesterolemia", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"PCSK9", CategoryCardiovascular, "Familial hypercholesterolemia", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"ACVRL1", CategoryCardiovascular, "Hereditary hemorrhagic telangiectasia", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"ENG", CategoryCardiovascular, "Hereditary hemorrhagic telangiectasia", InheritanceAutosomalDominant, ReportAllVariants, nil},
+
+	{"GLA", CategoryMetabolism, "Fabry disease", InheritanceXLinked, ReportAllVariants, nil},
+	{"OTC", CategoryMetabolism, "Ornithine transcarbamylase deficiency", InheritanceXLinked, ReportAllVariants, nil},
+	{"BTD", CategoryMetabolism, "Biotinidase deficiency", InheritanceAutosomalRecessive, ReportBiallelic, nil},
+	{"GAA", CategoryMetabolism, "Pompe disease", InheritanceAutosomalRecessive, ReportBiallelic, nil},
+
+	// Only p.C282Y homozygotes are reportable for HFE, which is ClinVar variation 9
+	{"HFE", CategoryMiscellaneous, "Hereditary hemochromatosis", InheritanceAutosomalRecessive, ReportHomozygous, []string{"9"}},
+	{"ATP7B", CategoryMiscellaneous, "Wilson disease", InheritanceAutosomalRecessive, ReportBiallelic, nil},
+	{"HNF1A", CategoryMiscellaneous, "Maturity-onset diabetes of the young", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"RYR1", CategoryMiscellaneous, "Malignant hyperthermia", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"CACNA1S", CategoryMiscellaneous, "Malignant hyperthermia", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"RPE65", CategoryMiscellaneous, "RPE65-related retinopathy", InheritanceAutosomalRecessive, ReportBiallelic, nil},
+}
+
+var secondaryFindingsV3_1Additions = []SecondaryFindingGene{
+	{"BAG3", CategoryCardiovascular, "Dilated cardiomyopathy", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"DES", CategoryCardiovascular, "Dilated cardiomyopathy", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"RBM20", CategoryCardiovascular, "Dilated cardiomyopathy", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"TNNC1", CategoryCardiovascular, "Dilated and hypertrophic cardiomyopathy", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"TTR", CategoryMiscellaneous, "Hereditary transthyretin-related amyloidosis", InheritanceAutosomalDominant, ReportAllVariants, nil},
+}
+
+var secondaryFindingsV3_2Additions = []SecondaryFindingGene{
+	{"CALM1", CategoryCardiovascular, "Long QT syndrome / Catecholaminergic polymorphic ventricular tachycardia", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"CALM2", CategoryCardiovascular, "Long QT syndrome / Catecholaminergic polymorphic ventricular tachycardia", InheritanceAutosomalDominant, ReportAllVariants, nil},
+	{"CALM3", CategoryCardiovascular, "Long QT syndrome / Catecholaminergic polymorphic ventricular tachycardia", InheritanceAutosomalDominant, ReportAllVariants, nil},
+}
+
+var secondaryFindingsLists = map[string][]SecondaryFindingGene{
+	"3.0": secondaryFindingsV3_0,
+	"3.1": appendGenes(secondaryFindingsV3_0, secondaryFindingsV3_1Additions),
+	"3.2": appendGenes(secondaryFindingsV3_0, secondaryFindingsV3_1Additions, secondaryFindingsV3_2Additions),
+}
+
+func appendGenes(lists ...[]SecondaryFindingGene) []SecondaryFindingGene {
+	genes := make([]SecondaryFindingGene, 0)
+	for _, list := range lists {
+		genes = append(genes, list...)
+	}
+	return genes
+}
+
+func SecondaryFindingsVersions() []string {
+	versions := make([]string, 0, len(secondaryFindingsLists))
+	for version := range secondaryFindingsLists {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// Returns the ACMG SF gene list keyed by gene symbol, an empty version means the latest
+func SecondaryFindingsGenes(version string) (map[string]SecondaryFindingGene, error) {
+	if version == "" {
+		version = LatestSecondaryFindingsVersion
+	}
+	list, ok := secondaryFindingsLists[version]
+	if !ok {
+		return nil, fmt.Errorf("unknown ACMG SF version %s, available versions are %v", version, SecondaryFindingsVersions())
+	}
+	genes := make(map[string]SecondaryFindingGene, len(list))
+	for _, gene := range list {
+		genes[gene.Symbol] = gene
+	}
+	return genes, nil
+}
@@ -0,0 +1,106 @@
+package panel
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kazmiekr/clinvar-matcher/interval"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+// Target regions from a BED file, indexed per chromosome for overlap lookups
+type Regions struct {
+	trees map[string]*interval.Tree
+	count int
+}
+
+// Reads a BED file, optionally gzipped. BED is 0-based and half open, the regions are stored 1-based
+// and closed to line up with VCF positions
+func ReadBed(bedPath string) (*Regions, error) {
+	reader, err := vcf.Open(bedPath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	intervals := make(map[string][]interval.Interval)
+	count := 0
+	lineNumber := 0
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if line == "" || line[0] == '#' || strings.HasPrefix(line, "track") || strings.HasPrefix(line, "browser") {
+			continue
+		}
+		parts := strings.Fields(line)
+		if len(parts) < 3 {
+			return nil, fmt.Errorf("bed line %d needs chrom, start and end", lineNumber)
+		}
+		start, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("bed line %d: %v", lineNumber, err)
+		}
+		end, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("bed line %d: %v", lineNumber, err)
+		}
+		region := interval.Interval{Start: start + 1, End: end}
+		if len(parts) > 3 {
+			region.Value = parts[3]
+		}
+		chrom := vcf.NormalizeChrom(parts[0])
+		intervals[chrom] = append(intervals[chrom], region)
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	regions := &Regions{
+		trees: make(map[string]*interval.Tree),
+		count: count,
+	}
+	for chrom, chromIntervals := range intervals {
+		regions.trees[chrom] = interval.NewTree(chromIntervals)
+	}
+	return regions, nil
+}
+
+func (regions *Regions) Len() int {
+	return regions.count
+}
+
+// Checks if any region overlaps the 1-based closed range on the chromosome
+func (regions *Regions) Overlaps(chrom string, start, end int) bool {
+	tree, ok := regions.trees[vcf.NormalizeChrom(chrom)]
+	if !ok {
+		return false
+	}
+	return tree.Overlaps(start, end)
+}
+
+// Checks if the variant's reference allele, or the span of a structural variant, overlaps any region
+func (regions *Regions) ContainsVariant(line *vcf.VcfLine) bool {
+	if sv, ok := line.StructuralVariant(); ok {
+		return regions.Overlaps(line.Chrom, sv.Start, sv.End)
+	}
+	end := line.Pos + len(line.Ref) - 1
+	if end < line.Pos {
+		end = line.Pos
+	}
+	return regions.Overlaps(line.Chrom, line.Pos, end)
+}
+
+// Every region as a VCF style region, for reading just those parts of an indexed VCF
+func (regions *Regions) List() []vcf.Region {
+	list := make([]vcf.Region, 0, regions.count)
+	for chrom, tree := range regions.trees {
+		for _, region := range tree.Intervals() {
+			list = append(list, vcf.Region{Chrom: chrom, Start: region.Start, End: region.End})
+		}
+	}
+	return list
+}
@@ -0,0 +1,112 @@
+package panel
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Set of upper cased gene symbols
+type GeneSet map[string]struct{}
+
+// Gene symbols have no dots or slashes, which is what tells them apart from a gene list file
+var geneSymbolPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func IsGeneSymbol(value string) bool {
+	return geneSymbolPattern.MatchString(value)
+}
+
+// Each value is either a path to a gene list file, or gene symbols separated by commas. Gene list files
+// have symbols separated by new lines, commas or whitespace, with # starting a comment. Values that
+// are only gene symbols are never looked for on disk, so a gene list file without an extension needs
+// a path like ./genes. Anything else has to be a file that can be read, so a mistyped path fails
+// rather than becoming a panel that matches nothing
+func ParseGenes(values []string) (GeneSet, error) {
+	genes := make(GeneSet)
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if onlyGeneSymbols(value) {
+			genes.addAll(value)
+			continue
+		}
+		if info, err := os.Stat(value); err != nil || info.IsDir() {
+			return genes, fmt.Errorf("%q isn't a gene symbol or a readable file", value)
+		}
+		if err := readGeneFile(value, genes); err != nil {
+			return genes, err
+		}
+	}
+	return genes, nil
+}
+
+// Like ParseGenes for values that can't name files, like ones from the server, so it never touches
+// the filesystem and fails on anything that isn't a gene symbol
+func ParseGeneSymbols(values []string) (GeneSet, error) {
+	genes := make(GeneSet)
+	for _, value := range values {
+		for _, field := range splitGenes(value) {
+			if !IsGeneSymbol(field) {
+				return genes, fmt.Errorf("%q isn't a gene symbol", field)
+			}
+		}
+		genes.addAll(value)
+	}
+	return genes, nil
+}
+
+func onlyGeneSymbols(value string) bool {
+	for _, field := range splitGenes(value) {
+		if !IsGeneSymbol(field) {
+			return false
+		}
+	}
+	return true
+}
+
+func readGeneFile(filePath string, genes GeneSet) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if comment := strings.Index(line, "#"); comment != -1 {
+			line = line[:comment]
+		}
+		genes.addAll(line)
+	}
+	return scanner.Err()
+}
+
+func splitGenes(symbols string) []string {
+	return strings.FieldsFunc(symbols, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+}
+
+func (genes GeneSet) addAll(symbols string) {
+	for _, field := range splitGenes(symbols) {
+		genes[strings.ToUpper(field)] = struct{}{}
+	}
+}
+
+func (genes GeneSet) Contains(gene string) bool {
+	_, ok := genes[strings.ToUpper(gene)]
+	return ok
+}
+
+func (genes GeneSet) ContainsAny(symbols []string) bool {
+	for _, symbol := range symbols {
+		if genes.Contains(symbol) {
+			return true
+		}
+	}
+	return false
+}
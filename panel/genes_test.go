@@ -0,0 +1,92 @@
+package panel
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func geneSet(symbols ...string) GeneSet {
+	genes := make(GeneSet)
+	for _, symbol := range symbols {
+		genes[symbol] = struct{}{}
+	}
+	return genes
+}
+
+func TestParseGenes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	listPath := filepath.Join(dir, "cardio.txt")
+	if err := ioutil.WriteFile(listPath, []byte("# cardio\nMYH7\nmybpc3, TNNT2 # sarcomere\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	noExtension := filepath.Join(dir, "BRCA1")
+	if err := ioutil.WriteFile(noExtension, []byte("TP53\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	tests := []struct {
+		values   []string
+		expected GeneSet
+	}{
+		{[]string{"BRCA1,brca2", " PALB2 "}, geneSet("BRCA1", "BRCA2", "PALB2")},
+		{[]string{listPath}, geneSet("MYH7", "MYBPC3", "TNNT2")},
+		{[]string{"cardio.txt", "TTN"}, geneSet("MYH7", "MYBPC3", "TNNT2", "TTN")},
+		// A symbol is never read as the file of the same name in the working directory
+		{[]string{"BRCA1"}, geneSet("BRCA1")},
+		{[]string{"./BRCA1"}, geneSet("TP53")},
+		{[]string{noExtension}, geneSet("TP53")},
+		{[]string{"HLA-DRB1", ""}, geneSet("HLA-DRB1")},
+	}
+	for _, test := range tests {
+		genes, err := ParseGenes(test.values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(genes, test.expected) {
+			t.Errorf("%v: expected %v, got %v", test.values, test.expected, genes)
+		}
+	}
+}
+
+func TestParseGenesRejectsMissingFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, value := range []string{"cardio_panel.txt", filepath.Join(dir, "missing.txt"), dir, "BRCA1;BRCA2"} {
+		if _, err := ParseGenes([]string{value}); err == nil {
+			t.Errorf("expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestParseGeneSymbols(t *testing.T) {
+	genes, err := ParseGeneSymbols([]string{"BRCA1,brca2", "LICENSE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(genes, geneSet("BRCA1", "BRCA2", "LICENSE")) {
+		t.Errorf("unexpected genes %v", genes)
+	}
+	for _, value := range []string{"genes.txt", "../genes", "/etc/passwd"} {
+		if _, err := ParseGeneSymbols([]string{value}); err == nil {
+			t.Errorf("expected %q to be rejected", value)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package pedigree
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	SexMale    = "male"
+	SexFemale  = "female"
+	SexUnknown = "unknown"
+)
+
+// A person from a PED file, parent IDs are empty when the parent isn't in the file
+type Individual struct {
+	FamilyID  string
+	ID        string
+	FatherID  string
+	MotherID  string
+	Sex       string
+	Phenotype string
+}
+
+type Pedigree struct {
+	Individuals []*Individual
+	// IDs are only unique within a family, so people are found by family and ID
+	byID map[individualKey]*Individual
+}
+
+type individualKey struct {
+	familyID string
+	id       string
+}
+
+// A child along with whichever parents are in the pedigree, Father or Mother can be nil
+type Trio struct {
+	FamilyID string
+	Child    *Individual
+	Father   *Individual
+	Mother   *Individual
+}
+
+// Reads a standard 6 column PED file: family, individual, father, mother, sex (1 male, 2 female)
+// and phenotype. Columns can be separated by tabs or spaces, and # starts a comment
+func ReadPed(pedPath string) (*Pedigree, error) {
+	file, err := os.Open(pedPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	pedigree := &Pedigree{
+		Individuals: make([]*Individual, 0),
+		byID:        make(map[individualKey]*Individual),
+	}
+	lineNumber := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		parts := strings.Fields(line)
+		if len(parts) < 5 {
+			return nil, fmt.Errorf("ped line %d needs at least family, individual, father, mother and sex", lineNumber)
+		}
+		individual := &Individual{
+			FamilyID: parts[0],
+			ID:       parts[1],
+			FatherID: parentID(parts[2]),
+			MotherID: parentID(parts[3]),
+			Sex:      parseSex(parts[4]),
+		}
+		if len(parts) > 5 {
+			individual.Phenotype = parts[5]
+		}
+		key := individualKey{individual.FamilyID, individual.ID}
+		if _, ok := pedigree.byID[key]; ok {
+			return nil, fmt.Errorf("ped line %d: individual %s is listed more than once in family %s", lineNumber, individual.ID, individual.FamilyID)
+		}
+		pedigree.Individuals = append(pedigree.Individuals, individual)
+		pedigree.byID[key] = individual
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return pedigree, nil
+}
+
+func parentID(id string) string {
+	if id == "0" || id == "." || id == "-9" {
+		return ""
+	}
+	return id
+}
+
+func parseSex(sex string) string {
+	switch strings.ToLower(sex) {
+	case "1", "m", "male":
+		return SexMale
+	case "2", "f", "female":
+		return SexFemale
+	}
+	return SexUnknown
+}
+
+func (pedigree *Pedigree) Individual(familyID string, id string) (*Individual, bool) {
+	individual, ok := pedigree.byID[individualKey{familyID, id}]
+	return individual, ok
+}
+
+// Individuals grouped by family ID
+func (pedigree *Pedigree) Families() map[string][]*Individual {
+	families := make(map[string][]*Individual)
+	for _, individual := range pedigree.Individuals {
+		families[individual.FamilyID] = append(families[individual.FamilyID], individual)
+	}
+	return families
+}
+
+// Returns every child that has a parent listed, limited to people accepted by include, which is
+// typically whether they have a sample in the VCF. Sorted by family then child
+func (pedigree *Pedigree) Trios(include func(id string) bool) []*Trio {
+	trios := make([]*Trio, 0)
+	for _, child := range pedigree.Individuals {
+		if !include(child.ID) {
+			continue
+		}
+		trio := &Trio{FamilyID: child.FamilyID, Child: child}
+		if father, ok := pedigree.Individual(child.FamilyID, child.FatherID); ok && include(father.ID) {
+			trio.Father = father
+		}
+		if mother, ok := pedigree.Individual(child.FamilyID, child.MotherID); ok && include(mother.ID) {
+			trio.Mother = mother
+		}
+		if trio.Father != nil || trio.Mother != nil {
+			trios = append(trios, trio)
+		}
+	}
+	sort.SliceStable(trios, func(i, j int) bool {
+		if trios[i].FamilyID != trios[j].FamilyID {
+			return trios[i].FamilyID < trios[j].FamilyID
+		}
+		return trios[i].Child.ID < trios[j].Child.ID
+	})
+	return trios
+}
@@ -0,0 +1,147 @@
+package pedigree
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePed(t *testing.T, text string) string {
+	dir, err := ioutil.TempDir("", "ped")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	pedPath := filepath.Join(dir, "family.ped")
+	if err := ioutil.WriteFile(pedPath, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return pedPath
+}
+
+func TestReadPed(t *testing.T) {
+	ped, err := ReadPed(writePed(t, `# family individual father mother sex phenotype
+FAM1	kid	dad	mom	1	2
+FAM1	dad	0	0	1	1
+FAM1 mom . -9 F 1
+
+FAM1	sister	dad	mom	2
+FAM2	1	2	3	other	0
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ped.Individuals) != 5 {
+		t.Fatalf("expected 5 individuals, got %d", len(ped.Individuals))
+	}
+	tests := []struct {
+		familyID  string
+		id        string
+		father    string
+		mother    string
+		sex       string
+		phenotype string
+	}{
+		{"FAM1", "kid", "dad", "mom", SexMale, "2"},
+		{"FAM1", "dad", "", "", SexMale, "1"},
+		{"FAM1", "mom", "", "", SexFemale, "1"},
+		{"FAM1", "sister", "dad", "mom", SexFemale, ""},
+		{"FAM2", "1", "2", "3", SexUnknown, "0"},
+	}
+	for _, test := range tests {
+		individual, ok := ped.Individual(test.familyID, test.id)
+		if !ok {
+			t.Errorf("%s %s is missing", test.familyID, test.id)
+			continue
+		}
+		if individual.FatherID != test.father || individual.MotherID != test.mother ||
+			individual.Sex != test.sex || individual.Phenotype != test.phenotype {
+			t.Errorf("%s %s: unexpected %+v", test.familyID, test.id, individual)
+		}
+	}
+	if _, ok := ped.Individual("FAM2", "kid"); ok {
+		t.Errorf("expected kid only in FAM1")
+	}
+	if families := ped.Families(); len(families) != 2 || len(families["FAM1"]) != 4 {
+		t.Errorf("unexpected families %v", families)
+	}
+}
+
+func TestReadPedErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"too few columns", "FAM1\tkid\tdad\tmom\n"},
+		{"listed twice", "FAM1\tkid\t0\t0\t1\nFAM1\tkid\t0\t0\t2\n"},
+	}
+	for _, test := range tests {
+		if _, err := ReadPed(writePed(t, test.text)); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+	if _, err := ReadPed(filepath.Join(os.TempDir(), "missing.ped")); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+}
+
+// The same IDs in different families are different people, with parents from their own family
+func TestReadPedRepeatsIDsAcrossFamilies(t *testing.T) {
+	ped, err := ReadPed(writePed(t, `FAM1	1	2	3	1
+FAM1	2	0	0	1
+FAM1	3	0	0	2
+FAM2	1	2	0	2
+FAM2	2	0	0	1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inFam1, _ := ped.Individual("FAM1", "1")
+	inFam2, _ := ped.Individual("FAM2", "1")
+	if inFam1 == nil || inFam2 == nil || inFam1 == inFam2 || inFam2.Sex != SexFemale {
+		t.Fatalf("expected two different people with ID 1, got %+v and %+v", inFam1, inFam2)
+	}
+
+	trios := ped.Trios(func(id string) bool { return true })
+	if len(trios) != 2 {
+		t.Fatalf("expected 2 trios, got %d", len(trios))
+	}
+	if trios[0].FamilyID != "FAM1" || trios[0].Father.FamilyID != "FAM1" || trios[0].Mother == nil {
+		t.Errorf("unexpected FAM1 trio %+v", trios[0])
+	}
+	if trios[1].FamilyID != "FAM2" || trios[1].Father.FamilyID != "FAM2" || trios[1].Mother != nil {
+		t.Errorf("unexpected FAM2 trio %+v", trios[1])
+	}
+}
+
+func TestTrios(t *testing.T) {
+	ped, err := ReadPed(writePed(t, `FAM2	kid2	dad2	mom2	2
+FAM2	dad2	0	0	1
+FAM2	mom2	0	0	2
+FAM1	kid	dad	mom	1
+FAM1	dad	0	0	1
+FAM1	mom	0	0	2
+FAM3	alone	0	0	1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inVcf := func(id string) bool { return !strings.HasPrefix(id, "mom") }
+	trios := ped.Trios(inVcf)
+	if len(trios) != 2 {
+		t.Fatalf("expected 2 trios, got %d", len(trios))
+	}
+	if trios[0].Child.ID != "kid" || trios[1].Child.ID != "kid2" {
+		t.Errorf("expected the trios sorted by family, got %s then %s", trios[0].Child.ID, trios[1].Child.ID)
+	}
+	for _, trio := range trios {
+		if trio.Father == nil || trio.Mother != nil {
+			t.Errorf("%s: expected only the father, who's in the VCF", trio.Child.ID)
+		}
+	}
+	if trios := ped.Trios(func(id string) bool { return strings.HasPrefix(id, "kid") }); len(trios) != 0 {
+		t.Errorf("expected no trios without any parents in the VCF, got %d", len(trios))
+	}
+}
@@ -0,0 +1,104 @@
+package progress
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+)
+
+// Told how a long run is going, so an application embedding the matcher can show its own progress.
+// Calls can come from several goroutines at once
+type Observer interface {
+	// Bytes of the input read so far, as stored on disk, out of size, which is -1 when it isn't
+	// known, like for standard input
+	BytesRead(input string, read int64, size int64)
+	// Records parsed from the input so far, VCF lines or submission summary rows
+	RecordsParsed(input string, records int)
+	// Sample variants matched to ClinVar so far
+	MatchesFound(matches int)
+}
+
+// Records between reports to the observer and checks for cancellation
+const ReportEvery = 10000
+
+// Follows the reading of one input, reporting to the observer every ReportEvery records and
+// stopping the read once the context is cancelled. A nil Tracker does nothing, and a nil observer
+// only checks the context
+type Tracker struct {
+	// Updated by whichever goroutine reads the input, so it's first to keep it aligned for atomics
+	read     int64
+	size     int64
+	ctx      context.Context
+	observer Observer
+	input    string
+	records  int
+	reported int
+}
+
+func NewTracker(ctx context.Context, observer Observer, input string) *Tracker {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &Tracker{ctx: ctx, observer: observer, input: input, size: -1}
+}
+
+// Counts the bytes read through the reader, out of size, or -1 when it isn't known
+func (tracker *Tracker) Reader(reader io.Reader, size int64) io.Reader {
+	if tracker == nil {
+		return reader
+	}
+	tracker.size = size
+	return &countingReader{reader: reader, read: &tracker.read}
+}
+
+// Counts records parsed, from one goroutine at a time. Returns the context's error once it's cancelled
+func (tracker *Tracker) Records(count int) error {
+	if tracker == nil {
+		return nil
+	}
+	tracker.records += count
+	if tracker.records-tracker.reported < ReportEvery {
+		return nil
+	}
+	tracker.report()
+	return tracker.ctx.Err()
+}
+
+// Reports the final counts, or the context's error if it was cancelled
+func (tracker *Tracker) Done() error {
+	if tracker == nil {
+		return nil
+	}
+	tracker.report()
+	return tracker.ctx.Err()
+}
+
+// The context's error once it's cancelled
+func (tracker *Tracker) Err() error {
+	if tracker == nil {
+		return nil
+	}
+	return tracker.ctx.Err()
+}
+
+func (tracker *Tracker) report() {
+	tracker.reported = tracker.records
+	if tracker.observer == nil {
+		return
+	}
+	if read := atomic.LoadInt64(&tracker.read); read > 0 {
+		tracker.observer.BytesRead(tracker.input, read, tracker.size)
+	}
+	tracker.observer.RecordsParsed(tracker.input, tracker.records)
+}
+
+type countingReader struct {
+	reader io.Reader
+	read   *int64
+}
+
+func (counter *countingReader) Read(p []byte) (int, error) {
+	n, err := counter.reader.Read(p)
+	atomic.AddInt64(counter.read, int64(n))
+	return n, err
+}
@@ -0,0 +1,236 @@
+package rawdata
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+const (
+	FormatVcf      = "vcf"
+	Format23andMe  = "23andme"
+	FormatAncestry = "ancestrydna"
+)
+
+// Number of lines to look through for a recognizable header
+const detectLines = 50
+
+// How much of the start of a file is peeked at to find those lines
+const DetectBytes = 64 * 1024
+
+// Looks up the reference variants at a position, which is how the ALT allele and zygosity are
+// worked out since raw genotype files only have the two observed alleles
+type ReferenceSource interface {
+	VariantsAt(chrom string, pos int) []*vcf.VcfLine
+}
+
+// Works out if a file is a VCF or a consumer raw genotype file from its first lines
+func DetectFormat(filePath string) (string, error) {
+	reader, err := vcf.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	format, err := DetectReaderFormat(bufio.NewReaderSize(reader, DetectBytes))
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", filePath, err)
+	}
+	return format, nil
+}
+
+// Works out the format from the start of an already decompressed stream without consuming it, so
+// the same reader can be handed on to the VCF or raw data parser. The reader's buffer should hold
+// DetectBytes
+func DetectReaderFormat(reader *bufio.Reader) (string, error) {
+	start, err := reader.Peek(DetectBytes)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return "", err
+	}
+	if bytes.HasPrefix(start, []byte("BCF\x02")) {
+		return FormatVcf, nil
+	}
+
+	lines := strings.Split(string(start), "\n")
+	for lineNumber := 0; lineNumber < detectLines && lineNumber < len(lines); lineNumber++ {
+		line := strings.TrimSpace(lines[lineNumber])
+		lower := strings.ToLower(line)
+		switch {
+		case strings.HasPrefix(line, "##fileformat=VCF"), strings.HasPrefix(line, "#CHROM"):
+			return FormatVcf, nil
+		case lineNumber == 0 && vcf.LooksLikeRecord(strings.TrimRight(lines[0], "\r")):
+			// A VCF without its header
+			return FormatVcf, nil
+		case strings.Contains(lower, "23andme"):
+			return Format23andMe, nil
+		case strings.Contains(lower, "ancestrydna"):
+			return FormatAncestry, nil
+		case strings.HasPrefix(lower, "rsid\tchromosome\tposition\tallele1\tallele2"):
+			return FormatAncestry, nil
+		case strings.HasPrefix(lower, "# rsid\tchromosome\tposition\tgenotype"):
+			return Format23andMe, nil
+		}
+	}
+	return "", fmt.Errorf("unable to tell the format, expected a VCF, BCF, 23andMe or AncestryDNA file")
+}
+
+// Reads a 23andMe or AncestryDNA raw data file and turns each genotype that carries a known
+// reference ALT allele into a VcfLine. Positions without a reference variant are skipped, since
+// there's no way to tell which allele is the reference
+func ReadRawGenotypes(filePath string, format string, reference ReferenceSource) ([]*vcf.VcfLine, error) {
+	reader, err := vcf.Open(filePath)
+	if err != nil {
+		return make([]*vcf.VcfLine, 0), err
+	}
+	defer reader.Close()
+	lines, err := ReadRawGenotypesFrom(reader, format, reference)
+	if err != nil {
+		return lines, fmt.Errorf("%s: %v", filePath, err)
+	}
+	return lines, nil
+}
+
+func ReadRawGenotypesFrom(reader io.Reader, format string, reference ReferenceSource) ([]*vcf.VcfLine, error) {
+	lines := make([]*vcf.VcfLine, 0)
+	lineNumber := 0
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || strings.HasPrefix(strings.ToLower(line), "rsid") {
+			continue
+		}
+		call, err := parseRawLine(line, format)
+		if err != nil {
+			return lines, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+		if call == nil {
+			continue
+		}
+		lines = append(lines, call.toVcfLines(reference)...)
+	}
+	if err := scanner.Err(); err != nil {
+		return lines, err
+	}
+	return lines, nil
+}
+
+type rawCall struct {
+	rsid    string
+	chrom   string
+	pos     int
+	alleles []string
+}
+
+func parseRawLine(line string, format string) (*rawCall, error) {
+	parts := strings.Split(line, "\t")
+	call := &rawCall{}
+	switch format {
+	case Format23andMe:
+		if len(parts) < 4 {
+			return nil, fmt.Errorf("expected rsid, chromosome, position and genotype")
+		}
+		for _, allele := range strings.ToUpper(parts[3]) {
+			call.alleles = append(call.alleles, string(allele))
+		}
+	case FormatAncestry:
+		if len(parts) < 5 {
+			return nil, fmt.Errorf("expected rsid, chromosome, position, allele1 and allele2")
+		}
+		call.alleles = []string{strings.ToUpper(parts[3]), strings.ToUpper(parts[4])}
+	default:
+		return nil, fmt.Errorf("unsupported raw data format %s", format)
+	}
+
+	var err error
+	call.rsid = parts[0]
+	call.chrom = normalizeRawChrom(parts[1])
+	call.pos, err = strconv.Atoi(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid position %s", parts[2])
+	}
+
+	// Skip no calls, like -- or 00, and the indel calls D and I which don't have real alleles
+	for _, allele := range call.alleles {
+		if !isBase(allele) {
+			return nil, nil
+		}
+	}
+	if len(call.alleles) == 0 {
+		return nil, nil
+	}
+	return call, nil
+}
+
+// AncestryDNA numbers the sex and mitochondrial chromosomes, 25 is the pseudoautosomal part of X
+func normalizeRawChrom(chrom string) string {
+	switch chrom {
+	case "23", "25", "XY":
+		return "X"
+	case "24":
+		return "Y"
+	case "26":
+		return "MT"
+	}
+	return vcf.NormalizeChrom(chrom)
+}
+
+func isBase(allele string) bool {
+	return allele == "A" || allele == "C" || allele == "G" || allele == "T"
+}
+
+// Builds a line for each reference SNV at the position whose ALT allele the genotype carries
+func (call *rawCall) toVcfLines(reference ReferenceSource) []*vcf.VcfLine {
+	lines := make([]*vcf.VcfLine, 0)
+	for _, variant := range reference.VariantsAt(call.chrom, call.pos) {
+		if len(variant.Ref) != 1 || len(variant.Alt) != 1 {
+			continue
+		}
+		gt, ok := call.genotype(variant.Alt)
+		if !ok {
+			continue
+		}
+		sampleData := map[string]string{"GT": gt}
+		lines = append(lines, &vcf.VcfLine{
+			Chrom:      variant.Chrom,
+			Pos:        call.pos,
+			ID:         call.rsid,
+			Ref:        variant.Ref,
+			Alt:        variant.Alt,
+			Qual:       ".",
+			Filter:     "PASS",
+			Format:     "GT",
+			Sample:     gt,
+			SampleData: sampleData,
+			Samples:    []map[string]string{sampleData},
+		})
+	}
+	return lines
+}
+
+// Converts the observed alleles into a VCF GT for alt. Single allele calls, like X in males, become
+// haploid. An allele that isn't alt is treated as ref, since the line only describes this alt
+func (call *rawCall) genotype(alt string) (string, bool) {
+	indexes := make([]string, len(call.alleles))
+	carries := false
+	for i, allele := range call.alleles {
+		if allele == alt {
+			indexes[i] = "1"
+			carries = true
+		} else {
+			indexes[i] = "0"
+		}
+	}
+	if !carries {
+		return "", false
+	}
+	// Keep the reference allele first like variant callers do
+	if len(indexes) == 2 && indexes[0] == "1" && indexes[1] == "0" {
+		indexes[0], indexes[1] = "0", "1"
+	}
+	return strings.Join(indexes, "/"), true
+}
@@ -0,0 +1,173 @@
+package rawdata
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+// Reference variants keyed by chrom:pos
+type fakeReference map[string][]*vcf.VcfLine
+
+func (reference fakeReference) VariantsAt(chrom string, pos int) []*vcf.VcfLine {
+	return reference[fmt.Sprintf("%s:%d", chrom, pos)]
+}
+
+var reference = fakeReference{
+	"1:1000":     {{Chrom: "1", Pos: 1000, Ref: "A", Alt: "G"}},
+	"6:26093141": {{Chrom: "6", Pos: 26093141, Ref: "G", Alt: "A"}, {Chrom: "6", Pos: 26093141, Ref: "G", Alt: "C"}},
+	"2:5000":     {{Chrom: "2", Pos: 5000, Ref: "AT", Alt: "A"}},
+	"X:2000":     {{Chrom: "X", Pos: 2000, Ref: "C", Alt: "T"}},
+	"MT:300":     {{Chrom: "MT", Pos: 300, Ref: "T", Alt: "C"}},
+}
+
+const raw23andMe = `# This data file generated by 23andMe at: Mon Jan 01 00:00:00 2020
+# rsid	chromosome	position	genotype
+rs1	1	1000	AG
+rs2	1	1000	GG
+rs3	1	1000	AA
+rs4	6	26093141	AC
+rs5	2	5000	AT
+rs6	X	2000	T
+rs7	1	1000	--
+rs8	1	1000	DI
+rs9	7	100	AG
+rs10	MT	300	C
+`
+
+const rawAncestry = `#AncestryDNA raw data download
+rsid	chromosome	position	allele1	allele2
+rs1	1	1000	G	A
+rs2	23	2000	T	T
+rs3	25	2000	C	T
+rs4	26	300	C	C
+rs5	1	1000	0	0
+`
+
+type expectedLine struct {
+	id  string
+	alt string
+	gt  string
+}
+
+func summarize(lines []*vcf.VcfLine) []expectedLine {
+	found := make([]expectedLine, 0, len(lines))
+	for _, line := range lines {
+		found = append(found, expectedLine{line.ID, line.Alt, line.GetSampleData("GT")})
+	}
+	return found
+}
+
+func TestReadRawGenotypes23andMe(t *testing.T) {
+	lines, err := ReadRawGenotypesFrom(strings.NewReader(raw23andMe), Format23andMe, reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []expectedLine{
+		{"rs1", "G", "0/1"},
+		{"rs2", "G", "1/1"},
+		{"rs4", "A", "0/1"},
+		{"rs4", "C", "0/1"},
+		{"rs6", "T", "1"},
+		{"rs10", "C", "1"},
+	}
+	if actual := summarize(lines); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+	line := lines[0]
+	if line.Chrom != "1" || line.Pos != 1000 || line.Ref != "A" || line.Filter != "PASS" || len(line.Samples) != 1 {
+		t.Errorf("unexpected line %+v", line)
+	}
+}
+
+func TestReadRawGenotypesAncestry(t *testing.T) {
+	lines, err := ReadRawGenotypesFrom(strings.NewReader(rawAncestry), FormatAncestry, reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []expectedLine{
+		{"rs1", "G", "0/1"},
+		{"rs2", "T", "1/1"},
+		{"rs3", "T", "0/1"},
+		{"rs4", "C", "1/1"},
+	}
+	if actual := summarize(lines); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestReadRawGenotypesErrors(t *testing.T) {
+	tests := []struct {
+		text   string
+		format string
+	}{
+		{"rs1\t1\t1000\n", Format23andMe},
+		{"rs1\t1\t1000\tA\n", FormatAncestry},
+		{"rs1\t1\tlots\tAG\n", Format23andMe},
+		{"rs1\t1\t1000\tAG\n", "myheritage"},
+	}
+	for _, test := range tests {
+		if _, err := ReadRawGenotypesFrom(strings.NewReader(test.text), test.format, reference); err == nil {
+			t.Errorf("%s %q: expected an error", test.format, test.text)
+		}
+	}
+}
+
+func TestDetectReaderFormat(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{raw23andMe, Format23andMe},
+		{"# rsid\tchromosome\tposition\tgenotype\nrs1\t1\t1000\tAG\n", Format23andMe},
+		{rawAncestry, FormatAncestry},
+		{"rsid\tchromosome\tposition\tallele1\tallele2\n", FormatAncestry},
+		{"##fileformat=VCFv4.2\n#CHROM\tPOS\n", FormatVcf},
+		{"#CHROM\tPOS\tID\n", FormatVcf},
+		{"BCF\x02\x02rest", FormatVcf},
+		{"1\t1000\t.\tA\tG\t50\tPASS\t.\tGT\t0/1\n", FormatVcf},
+	}
+	for _, test := range tests {
+		format, err := DetectReaderFormat(bufio.NewReaderSize(strings.NewReader(test.text), DetectBytes))
+		if err != nil || format != test.expected {
+			t.Errorf("%q: expected %s, got %s, %v", test.text[:10], test.expected, format, err)
+		}
+	}
+	if _, err := DetectReaderFormat(bufio.NewReaderSize(strings.NewReader("name,dob\n"), DetectBytes)); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+}
+
+func TestReadRawGenotypesFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rawdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write([]byte(raw23andMe))
+	writer.Close()
+	rawPath := filepath.Join(dir, "genome.txt.gz")
+	if err := ioutil.WriteFile(rawPath, compressed.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	format, err := DetectFormat(rawPath)
+	if err != nil || format != Format23andMe {
+		t.Fatalf("expected %s, got %s, %v", Format23andMe, format, err)
+	}
+	lines, err := ReadRawGenotypes(rawPath, format, reference)
+	if err != nil || len(lines) != 6 {
+		t.Errorf("expected 6 lines, got %d, %v", len(lines), err)
+	}
+}
@@ -0,0 +1,279 @@
+package release
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+// Kinds of change between two releases
+const (
+	ChangeNew            = "new"
+	ChangeRemoved        = "removed"
+	ChangeClassification = "classification"
+	ChangeStars          = "stars"
+	ChangeSubmissions    = "submissions"
+)
+
+var ChangeKinds = []string{ChangeNew, ChangeRemoved, ChangeClassification, ChangeStars, ChangeSubmissions}
+
+// A variation as it was in one of the releases, the VCF and submission parts are left empty when
+// they weren't compared
+type Side struct {
+	// Abbreviated aggregate pathogenicity from CLNSIG, like LP
+	Classification       string `json:"classification,omitempty"`
+	ClinicalSignificance string `json:"clinical_significance,omitempty"`
+	ReviewStatus         string `json:"review_status,omitempty"`
+	Stars                *int   `json:"stars,omitempty"`
+	Submissions          *int   `json:"submissions,omitempty"`
+}
+
+// A submission that was added, removed or reclassified, keyed by its SCV without the version
+type SubmissionChange struct {
+	SCV             string `json:"scv"`
+	Submitter       string `json:"submitter"`
+	OldSignificance string `json:"old_significance,omitempty"`
+	NewSignificance string `json:"new_significance,omitempty"`
+}
+
+// A variation that changed between the releases
+type Change struct {
+	VariationID string   `json:"variation_id"`
+	Chromosome  string   `json:"chromosome,omitempty"`
+	Position    int      `json:"position,omitempty"`
+	Ref         string   `json:"ref,omitempty"`
+	Alt         string   `json:"alt,omitempty"`
+	Genes       []string `json:"genes"`
+	// The most important change, like VUS->LP, new variant or stars 1->2
+	Category string `json:"category"`
+	// Every kind of change, from the Change constants
+	Changes                 []string           `json:"changes"`
+	Old                     *Side              `json:"old"`
+	New                     *Side              `json:"new"`
+	SubmissionsAdded        []SubmissionChange `json:"submissions_added,omitempty"`
+	SubmissionsRemoved      []SubmissionChange `json:"submissions_removed,omitempty"`
+	SubmissionsReclassified []SubmissionChange `json:"submissions_reclassified,omitempty"`
+}
+
+// Finds the variations that are new, removed or changed in the kinds asked for. Classifications,
+// stars and positions need both releases to have a VCF, and submissions need both to have a submission
+// summary. When both have a VCF, variations are matched on the VCF's IDs
+func Compare(older *Release, newer *Release, kinds []string) ([]*Change, error) {
+	if err := ValidateChanges(kinds); err != nil {
+		return nil, err
+	}
+	withVariants := older.Variants != nil && newer.Variants != nil
+	withSubmissions := older.Submissions != nil && newer.Submissions != nil
+	if !withVariants && !withSubmissions {
+		return nil, fmt.Errorf("both releases need a VCF, or both need a submission summary, to compare them")
+	}
+
+	oldIDs, newIDs := variationIDs(older, withVariants), variationIDs(newer, withVariants)
+	ids := make([]string, 0, len(newIDs))
+	for id := range newIDs {
+		ids = append(ids, id)
+	}
+	for id := range oldIDs {
+		if !newIDs[id] {
+			ids = append(ids, id)
+		}
+	}
+
+	changes := make([]*Change, 0)
+	for _, id := range ids {
+		change := &Change{VariationID: id, Genes: make([]string, 0), Changes: make([]string, 0)}
+		if oldIDs[id] {
+			change.Old = side(older, id, withVariants, withSubmissions)
+		}
+		if newIDs[id] {
+			change.New = side(newer, id, withVariants, withSubmissions)
+		}
+		switch {
+		case change.Old == nil:
+			change.Changes = append(change.Changes, ChangeNew)
+		case change.New == nil:
+			change.Changes = append(change.Changes, ChangeRemoved)
+		default:
+			if withVariants && change.Old.Classification != change.New.Classification {
+				change.Changes = append(change.Changes, ChangeClassification)
+			}
+			if withVariants && *change.Old.Stars != *change.New.Stars {
+				change.Changes = append(change.Changes, ChangeStars)
+			}
+			if withSubmissions {
+				compareSubmissions(change, older.Submissions[id], newer.Submissions[id])
+				if len(change.SubmissionsAdded)+len(change.SubmissionsRemoved)+len(change.SubmissionsReclassified) > 0 {
+					change.Changes = append(change.Changes, ChangeSubmissions)
+				}
+			}
+		}
+		if !hasAnyKind(change.Changes, kinds) {
+			continue
+		}
+		change.Category = category(change, kinds)
+		if withVariants {
+			setVariant(change, older, newer)
+		}
+		changes = append(changes, change)
+	}
+	sortChanges(changes)
+	return changes, nil
+}
+
+func ValidateChanges(kinds []string) error {
+	for _, kind := range kinds {
+		if !contains(ChangeKinds, kind) {
+			return fmt.Errorf("unknown change %s, use any of %s", kind, strings.Join(ChangeKinds, ", "))
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyKind(changes []string, kinds []string) bool {
+	for _, change := range changes {
+		if contains(kinds, change) {
+			return true
+		}
+	}
+	return false
+}
+
+func variationIDs(release *Release, withVariants bool) map[string]bool {
+	ids := make(map[string]bool)
+	if withVariants {
+		for id := range release.Variants {
+			ids[id] = true
+		}
+		return ids
+	}
+	for id := range release.Submissions {
+		ids[id] = true
+	}
+	return ids
+}
+
+func side(release *Release, id string, withVariants bool, withSubmissions bool) *Side {
+	s := &Side{}
+	if withVariants {
+		variant := release.Variants[id]
+		s.Classification = clinvar.VariantPathogenicity(variant).Abbreviation()
+		s.ClinicalSignificance = strings.Replace(variant.GetInfo(clinvar.SignficanceKey), "_", " ", -1)
+		s.ReviewStatus = strings.Replace(variant.GetInfo(clinvar.ReviewStatusKey), "_", " ", -1)
+		stars := clinvar.ReviewStars(variant.GetInfo(clinvar.ReviewStatusKey))
+		s.Stars = &stars
+	}
+	if withSubmissions {
+		submissions := len(release.Submissions[id])
+		s.Submissions = &submissions
+	}
+	return s
+}
+
+// Position and genes from the newer release, or the older one for a removed variant
+func setVariant(change *Change, older *Release, newer *Release) {
+	variant, ok := newer.Variants[change.VariationID]
+	if !ok {
+		variant = older.Variants[change.VariationID]
+	}
+	change.Chromosome = variant.Chrom
+	change.Position = variant.Pos
+	change.Ref = variant.Ref
+	change.Alt = variant.Alt
+	change.Genes = clinvar.GeneInfoSymbols(variant.GetInfo(clinvar.GeneInfoKey))
+}
+
+// SCVs are compared without their version, so an updated submission with the same significance
+// isn't counted as a change
+func compareSubmissions(change *Change, older []*clinvar.ClinvarSubmission, newer []*clinvar.ClinvarSubmission) {
+	oldByScv := submissionsByScv(older)
+	newByScv := submissionsByScv(newer)
+	for _, submission := range newer {
+		scv := scvAccession(submission.SCV)
+		previous, ok := oldByScv[scv]
+		if !ok {
+			change.SubmissionsAdded = append(change.SubmissionsAdded, SubmissionChange{
+				SCV: submission.SCV, Submitter: submission.Submitter, NewSignificance: submission.ClinicalSignificance,
+			})
+		} else if !strings.EqualFold(previous.ClinicalSignificance, submission.ClinicalSignificance) {
+			change.SubmissionsReclassified = append(change.SubmissionsReclassified, SubmissionChange{
+				SCV: submission.SCV, Submitter: submission.Submitter,
+				OldSignificance: previous.ClinicalSignificance, NewSignificance: submission.ClinicalSignificance,
+			})
+		}
+	}
+	for _, submission := range older {
+		if _, ok := newByScv[scvAccession(submission.SCV)]; !ok {
+			change.SubmissionsRemoved = append(change.SubmissionsRemoved, SubmissionChange{
+				SCV: submission.SCV, Submitter: submission.Submitter, OldSignificance: submission.ClinicalSignificance,
+			})
+		}
+	}
+}
+
+func submissionsByScv(submissions []*clinvar.ClinvarSubmission) map[string]*clinvar.ClinvarSubmission {
+	byScv := make(map[string]*clinvar.ClinvarSubmission, len(submissions))
+	for _, submission := range submissions {
+		byScv[scvAccession(submission.SCV)] = submission
+	}
+	return byScv
+}
+
+// SCV000077546.3 to SCV000077546
+func scvAccession(scv string) string {
+	if index := strings.Index(scv, "."); index >= 0 {
+		return scv[:index]
+	}
+	return scv
+}
+
+// Picks the most important of the changes asked for, in the order new, removed, classification,
+// stars, then submissions
+func category(change *Change, kinds []string) string {
+	for _, kind := range ChangeKinds {
+		if !contains(change.Changes, kind) || !contains(kinds, kind) {
+			continue
+		}
+		switch kind {
+		case ChangeNew:
+			return "new variant"
+		case ChangeRemoved:
+			return "removed variant"
+		case ChangeClassification:
+			return fmt.Sprintf("%s->%s", change.Old.Classification, change.New.Classification)
+		case ChangeStars:
+			return fmt.Sprintf("stars %d->%d", *change.Old.Stars, *change.New.Stars)
+		case ChangeSubmissions:
+			return "submissions changed"
+		}
+	}
+	return ""
+}
+
+// Sorts by position when there is one, then by variation ID
+func sortChanges(changes []*Change) {
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.Chromosome != b.Chromosome {
+			return vcf.ChromLess(a.Chromosome, b.Chromosome)
+		}
+		if a.Position != b.Position {
+			return a.Position < b.Position
+		}
+		if len(a.VariationID) != len(b.VariationID) {
+			return len(a.VariationID) < len(b.VariationID)
+		}
+		return a.VariationID < b.VariationID
+	})
+}
@@ -0,0 +1,225 @@
+package release
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const olderVcf = `##fileformat=VCFv4.1
+##fileDate=2020-07-06
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO
+1	1000	100	A	G	.	.	CLNSIG=Uncertain_significance;CLNREVSTAT=criteria_provided,_single_submitter;GENEINFO=GENEA:1
+1	2000	101	C	T	.	.	CLNSIG=Pathogenic;CLNREVSTAT=criteria_provided,_single_submitter;GENEINFO=GENEA:1
+2	3000	102	G	A	.	.	CLNSIG=Benign;CLNREVSTAT=criteria_provided,_single_submitter;GENEINFO=GENEB:2
+2	4000	103	T	C	.	.	CLNSIG=Benign;CLNREVSTAT=criteria_provided,_single_submitter;GENEINFO=GENEB:2
+`
+
+const newerVcf = `##fileformat=VCFv4.1
+##fileDate=2020-08-03
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO
+1	1000	100	A	G	.	.	CLNSIG=Likely_pathogenic;CLNREVSTAT=criteria_provided,_single_submitter;GENEINFO=GENEA:1
+1	2000	101	C	T	.	.	CLNSIG=Pathogenic;CLNREVSTAT=reviewed_by_expert_panel;GENEINFO=GENEA:1
+2	4000	103	T	C	.	.	CLNSIG=Benign;CLNREVSTAT=criteria_provided,_single_submitter;GENEINFO=GENEB:2
+3	5000	104	A	C	.	.	CLNSIG=Pathogenic;CLNREVSTAT=criteria_provided,_single_submitter;GENEINFO=GENEC:3
+`
+
+const summaryHeader = "#VariationID\tClinicalSignificance\tDateLastEvaluated\tDescription\tSubmittedPhenotypeInfo\t" +
+	"ReportedPhenotypeInfo\tReviewStatus\tCollectionMethod\tOriginCounts\tSubmitter\tSCV\tSubmittedGeneSymbol\t" +
+	"ExplanationOfInterpretation\n"
+
+const olderSubmissions = summaryHeader + `100	Uncertain significance	-	-	-	-	-	-	-	Lab 1	SCV000000001.1	GENEA	-
+101	Pathogenic	-	-	-	-	-	-	-	Lab 1	SCV000000002.1	GENEA	-
+102	Benign	-	-	-	-	-	-	-	Lab 2	SCV000000007.1	GENEB	-
+103	Benign	-	-	-	-	-	-	-	Lab 2	SCV000000003.1	GENEB	-
+103	Benign	-	-	-	-	-	-	-	Lab 3	SCV000000004.1	GENEB	-
+`
+
+const newerSubmissions = summaryHeader + `100	Likely pathogenic	-	-	-	-	-	-	-	Lab 1	SCV000000001.2	GENEA	-
+101	Pathogenic	-	-	-	-	-	-	-	Lab 1	SCV000000002.2	GENEA	-
+103	Benign	-	-	-	-	-	-	-	Lab 2	SCV000000003.1	GENEB	-
+103	Benign	-	-	-	-	-	-	-	Lab 4	SCV000000005.1	GENEB	-
+104	Pathogenic	-	-	-	-	-	-	-	Lab 1	SCV000000006.1	GENEC	-
+`
+
+// Writes the files given, leaving blank text out, and loads them as a release
+func loadRelease(t *testing.T, vcfText string, submissionText string) *Release {
+	dir, err := ioutil.TempDir("", "release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	vcfPath, submissionPath := "", ""
+	if vcfText != "" {
+		vcfPath = filepath.Join(dir, "clinvar.vcf")
+		if err := ioutil.WriteFile(vcfPath, []byte(vcfText), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if submissionText != "" {
+		submissionPath = filepath.Join(dir, "submission_summary.txt")
+		if err := ioutil.WriteFile(submissionPath, []byte(submissionText), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	release, err := Load(vcfPath, submissionPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return release
+}
+
+type changeSummary struct {
+	id       string
+	category string
+	changes  []string
+}
+
+func summarizeChanges(changes []*Change) []changeSummary {
+	found := make([]changeSummary, 0, len(changes))
+	for _, change := range changes {
+		found = append(found, changeSummary{change.VariationID, change.Category, change.Changes})
+	}
+	return found
+}
+
+func TestCompare(t *testing.T) {
+	older := loadRelease(t, olderVcf, olderSubmissions)
+	newer := loadRelease(t, newerVcf, newerSubmissions)
+	if older.Date != "2020-07-06" || newer.Date != "2020-08-03" {
+		t.Errorf("expected the release dates, got %s and %s", older.Date, newer.Date)
+	}
+	tests := []struct {
+		name     string
+		kinds    []string
+		expected []changeSummary
+	}{
+		{"everything", ChangeKinds, []changeSummary{
+			{"100", "VUS->LP", []string{ChangeClassification, ChangeSubmissions}},
+			{"101", "stars 1->3", []string{ChangeStars}},
+			{"102", "removed variant", []string{ChangeRemoved}},
+			{"103", "submissions changed", []string{ChangeSubmissions}},
+			{"104", "new variant", []string{ChangeNew}},
+		}},
+		{"classifications", []string{ChangeClassification}, []changeSummary{
+			{"100", "VUS->LP", []string{ChangeClassification, ChangeSubmissions}},
+		}},
+		// The category is the most important change asked for
+		{"submissions", []string{ChangeSubmissions}, []changeSummary{
+			{"100", "submissions changed", []string{ChangeClassification, ChangeSubmissions}},
+			{"103", "submissions changed", []string{ChangeSubmissions}},
+		}},
+		{"new and removed", []string{ChangeNew, ChangeRemoved}, []changeSummary{
+			{"102", "removed variant", []string{ChangeRemoved}},
+			{"104", "new variant", []string{ChangeNew}},
+		}},
+	}
+	for _, test := range tests {
+		changes, err := Compare(older, newer, test.kinds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := summarizeChanges(changes); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestCompareDetails(t *testing.T) {
+	changes, err := Compare(loadRelease(t, olderVcf, olderSubmissions), loadRelease(t, newerVcf, newerSubmissions), ChangeKinds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reclassified := changes[0]
+	if reclassified.Chromosome != "1" || reclassified.Position != 1000 || reclassified.Ref != "A" || reclassified.Alt != "G" ||
+		!reflect.DeepEqual(reclassified.Genes, []string{"GENEA"}) {
+		t.Errorf("unexpected variant %+v", reclassified)
+	}
+	if reclassified.Old.ClinicalSignificance != "Uncertain significance" || *reclassified.Old.Submissions != 1 {
+		t.Errorf("unexpected old side %+v", reclassified.Old)
+	}
+	expected := []SubmissionChange{{"SCV000000001.2", "Lab 1", "Uncertain significance", "Likely pathogenic"}}
+	if !reflect.DeepEqual(reclassified.SubmissionsReclassified, expected) || len(reclassified.SubmissionsAdded) != 0 {
+		t.Errorf("expected a reclassified submission, got %+v", reclassified)
+	}
+
+	// A new version of an SCV with the same significance isn't a change
+	if changes[1].SubmissionsReclassified != nil {
+		t.Errorf("expected the updated SCV to be left out, got %+v", changes[1].SubmissionsReclassified)
+	}
+	updated := changes[3]
+	if len(updated.SubmissionsAdded) != 1 || updated.SubmissionsAdded[0].Submitter != "Lab 4" ||
+		len(updated.SubmissionsRemoved) != 1 || updated.SubmissionsRemoved[0].Submitter != "Lab 3" {
+		t.Errorf("expected Lab 4 added and Lab 3 removed, got %+v", updated)
+	}
+	if changes[2].New != nil || changes[2].Chromosome != "2" || changes[4].Old != nil || changes[4].Chromosome != "3" {
+		t.Errorf("expected removed and new variants to keep their positions")
+	}
+}
+
+func TestCompareSubmissionsOnly(t *testing.T) {
+	changes, err := Compare(loadRelease(t, "", olderSubmissions), loadRelease(t, newerVcf, newerSubmissions), ChangeKinds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []changeSummary{
+		{"100", "submissions changed", []string{ChangeSubmissions}},
+		{"102", "removed variant", []string{ChangeRemoved}},
+		{"103", "submissions changed", []string{ChangeSubmissions}},
+		{"104", "new variant", []string{ChangeNew}},
+	}
+	if actual := summarizeChanges(changes); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+	if changes[0].Chromosome != "" || changes[0].New.Classification != "" || changes[0].New.Stars != nil {
+		t.Errorf("expected nothing from the VCF, got %+v", changes[0])
+	}
+}
+
+func TestCompareErrors(t *testing.T) {
+	vcfOnly := loadRelease(t, olderVcf, "")
+	submissionsOnly := loadRelease(t, "", newerSubmissions)
+	if _, err := Compare(vcfOnly, submissionsOnly, ChangeKinds); err == nil {
+		t.Errorf("expected an error without anything to compare")
+	}
+	if _, err := Compare(vcfOnly, vcfOnly, []string{"position"}); err == nil {
+		t.Errorf("expected an error for an unknown change")
+	}
+	if _, err := Load("", ""); err == nil {
+		t.Errorf("expected an error loading nothing")
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	changes, err := Compare(loadRelease(t, olderVcf, olderSubmissions), loadRelease(t, newerVcf, newerSubmissions), ChangeKinds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := WriteCSV(&out, changes); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 6 || !reflect.DeepEqual(records[0], csvHeaders) {
+		t.Fatalf("expected the headers and 5 changes, got %v", records)
+	}
+	expected := []string{
+		"100", "1", "1000", "A", "G", "GENEA", "VUS->LP", "classification,submissions",
+		"VUS", "LP", "Uncertain significance", "Likely pathogenic",
+		"criteria provided, single submitter", "criteria provided, single submitter", "1", "1", "1", "1",
+		"", "", "SCV000000001.2 (Lab 1): Uncertain significance->Likely pathogenic",
+	}
+	if !reflect.DeepEqual(records[1], expected) {
+		t.Errorf("expected %v, got %v", expected, records[1])
+	}
+	if removed := records[3]; removed[7] != ChangeRemoved || removed[8] != "B" || removed[9] != "" {
+		t.Errorf("expected the new columns blank for a removed variant, got %v", removed)
+	}
+}
@@ -0,0 +1,263 @@
+package release
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/matcher"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+// Listed in place of the changed columns when the variant isn't in the newer release
+const RemovedFromClinvar = "Removed from ClinVar"
+
+// Report columns that say which variant a row is, copied to the reanalysis as they are
+var identityColumns = []string{"chromosome", "begin", "end", "ref", "alt", "rsid", "zygosity", "clinvar_id", "genes"}
+
+// Report columns compared with the newer release: the classification, submission counts and diseases
+var reanalyzedColumns = []string{
+	"max_pathogenicity",
+	"assessment_count",
+	"benign_count",
+	"likely_benign_count",
+	"vus_count",
+	"likely_pathogenic_count",
+	"pathogenic_count",
+	"other_count",
+	"diseases",
+}
+
+// An earlier clinvar-matcher report, with each row keyed by column header
+type Report struct {
+	Headers []string
+	Rows    []map[string]string
+}
+
+// A variant from the earlier report whose ClinVar values changed
+type ReanalyzedVariant struct {
+	// The identity columns, like Chromosome and Clinvar ID, from the earlier report
+	Variant map[string]string `json:"variant"`
+	// Headers of the columns that changed, or RemovedFromClinvar
+	Changed []string          `json:"changed"`
+	Old     map[string]string `json:"old"`
+	// Nil when the variant was removed from ClinVar
+	New map[string]string `json:"new"`
+}
+
+type Reanalysis struct {
+	// Headers of the identity and compared columns found in the earlier report
+	IdentityHeaders []string
+	ComparedHeaders []string
+	Variants        []*ReanalyzedVariant
+}
+
+// Reads a report written as CSV, or as JSON by the server, from a file or - for standard input.
+// The format is worked out from the first character
+func ReadReport(path string) (*Report, error) {
+	file, err := vcf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	for {
+		b, err := reader.Peek(1)
+		if err != nil {
+			return nil, fmt.Errorf("%s: empty report", path)
+		}
+		if b[0] != ' ' && b[0] != '\t' && b[0] != '\r' && b[0] != '\n' {
+			break
+		}
+		reader.ReadByte()
+	}
+	if b, _ := reader.Peek(1); b[0] == '[' {
+		return readJSONReport(reader)
+	}
+	return readCSVReport(reader)
+}
+
+func readCSVReport(r io.Reader) (*Report, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("empty report")
+	}
+	report := &Report{Headers: records[0], Rows: make([]map[string]string, 0, len(records)-1)}
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(report.Headers))
+		for i, value := range record {
+			if i < len(report.Headers) {
+				row[report.Headers[i]] = value
+			}
+		}
+		report.Rows = append(report.Rows, row)
+	}
+	return report, nil
+}
+
+// The server's JSON reports are an array of objects keyed by the CSV headers
+func readJSONReport(r io.Reader) (*Report, error) {
+	report := &Report{Headers: make([]string, 0)}
+	if err := json.NewDecoder(r).Decode(&report.Rows); err != nil {
+		return nil, fmt.Errorf("reading JSON report: %v", err)
+	}
+	seen := make(map[string]bool)
+	for _, row := range report.Rows {
+		for header := range row {
+			if !seen[header] {
+				seen[header] = true
+				report.Headers = append(report.Headers, header)
+			}
+		}
+	}
+	return report, nil
+}
+
+// Looks up each of the report's variants in the newer release and keeps the ones whose
+// classification, submission counts or diseases changed, or that were removed from ClinVar
+func Reanalyze(client *clinvar.ClinvarClient, report *Report) (*Reanalysis, error) {
+	identity, err := reportColumns(report, identityColumns)
+	if err != nil {
+		return nil, err
+	}
+	compared, err := reportColumns(report, reanalyzedColumns)
+	if err != nil {
+		return nil, err
+	}
+	if len(compared) == 0 {
+		return nil, fmt.Errorf("the report has none of the columns that can be reanalyzed, like Max Pathogenicity or Diseases, is it an assessment report?")
+	}
+	if !hasHeader(report, "Clinvar ID") && !(hasHeader(report, "Chromosome") && hasHeader(report, "Begin") && hasHeader(report, "Ref") && hasHeader(report, "Alt")) {
+		return nil, fmt.Errorf("the report needs a Clinvar ID column, or Chromosome, Begin, Ref and Alt columns, to find its variants")
+	}
+
+	reanalysis := &Reanalysis{
+		IdentityHeaders: matcher.ColumnHeaders(identity),
+		ComparedHeaders: matcher.ColumnHeaders(compared),
+		Variants:        make([]*ReanalyzedVariant, 0),
+	}
+	for _, row := range report.Rows {
+		variant := &ReanalyzedVariant{
+			Variant: pick(row, reanalysis.IdentityHeaders),
+			Changed: make([]string, 0),
+			Old:     pick(row, reanalysis.ComparedHeaders),
+		}
+		sample := &vcf.VcfLine{Chrom: row["Chromosome"], Ref: row["Ref"], Alt: row["Alt"]}
+		sample.Pos, _ = strconv.Atoi(row["Begin"])
+		record, ok := findRecord(client, row, sample)
+		if !ok {
+			variant.Changed = append(variant.Changed, RemovedFromClinvar)
+			reanalysis.Variants = append(reanalysis.Variants, variant)
+			continue
+		}
+		variant.New = make(map[string]string, len(compared))
+		for _, column := range compared {
+			value := column.Extract(sample, record)
+			variant.New[column.Header] = value
+			if value != row[column.Header] {
+				variant.Changed = append(variant.Changed, column.Header)
+			}
+		}
+		if len(variant.Changed) > 0 {
+			reanalysis.Variants = append(reanalysis.Variants, variant)
+		}
+	}
+	return reanalysis, nil
+}
+
+// The columns whose headers are in the report, in the order given since JSON reports don't have one
+func reportColumns(report *Report, names []string) ([]matcher.Column, error) {
+	columns, err := matcher.ResolveColumns(names)
+	if err != nil {
+		return nil, err
+	}
+	found := make([]matcher.Column, 0, len(columns))
+	for _, column := range columns {
+		if hasHeader(report, column.Header) {
+			found = append(found, column)
+		}
+	}
+	return found, nil
+}
+
+func hasHeader(report *Report, header string) bool {
+	for _, h := range report.Headers {
+		if h == header {
+			return true
+		}
+	}
+	return false
+}
+
+func pick(row map[string]string, headers []string) map[string]string {
+	values := make(map[string]string, len(headers))
+	for _, header := range headers {
+		values[header] = row[header]
+	}
+	return values
+}
+
+// Finds the variant on its ClinVar variation ID, preferring the one with the same alleles, or on
+// its position and alleles when the report doesn't have the ID
+func findRecord(client *clinvar.ClinvarClient, row map[string]string, sample *vcf.VcfLine) (*clinvar.ClinvarRecord, bool) {
+	if id, ok := row["Clinvar ID"]; ok {
+		variants := client.VariantsByID[id]
+		if len(variants) == 0 {
+			return nil, false
+		}
+		variant := variants[0]
+		for _, v := range variants {
+			if strings.EqualFold(v.Ref, sample.Ref) && strings.EqualFold(v.Alt, sample.Alt) {
+				variant = v
+				break
+			}
+		}
+		return client.LookupVariant(variant)
+	}
+	key := &vcf.VcfLine{Chrom: vcf.NormalizeChrom(sample.Chrom), Pos: sample.Pos, Ref: sample.Ref, Alt: sample.Alt}
+	return client.Lookup(key)
+}
+
+// Writes a row per changed variant, with the identity columns followed by the old and new value of
+// each compared column side by side
+func (reanalysis *Reanalysis) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	headers := append([]string{}, reanalysis.IdentityHeaders...)
+	headers = append(headers, "Changed")
+	for _, header := range reanalysis.ComparedHeaders {
+		headers = append(headers, "Old "+header, "New "+header)
+	}
+	writer.Write(headers)
+	for _, variant := range reanalysis.Variants {
+		record := make([]string, 0, len(headers))
+		for _, header := range reanalysis.IdentityHeaders {
+			record = append(record, variant.Variant[header])
+		}
+		record = append(record, strings.Join(variant.Changed, ","))
+		for _, header := range reanalysis.ComparedHeaders {
+			record = append(record, variant.Old[header], variant.New[header])
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func (reanalysis *Reanalysis) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(reanalysis.Variants)
+}
@@ -0,0 +1,163 @@
+package release
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/matcher"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+const sampleVcf = `##fileformat=VCFv4.2
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	SAMPLE
+1	1000	.	A	G	50	PASS	.	GT	0/1
+1	2000	.	C	T	50	PASS	.	GT	0/1
+2	3000	.	G	A	50	PASS	.	GT	1/1
+`
+
+func loadClient(t *testing.T, vcfText string, submissionText string) *clinvar.ClinvarClient {
+	dir, err := ioutil.TempDir("", "release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	vcfPath := filepath.Join(dir, "clinvar.vcf")
+	submissionPath := filepath.Join(dir, "submission_summary.txt")
+	if err := ioutil.WriteFile(vcfPath, []byte(vcfText), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(submissionPath, []byte(submissionText), 0644); err != nil {
+		t.Fatal(err)
+	}
+	client, err := clinvar.NewClinvar(vcfPath, submissionPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// Writes the report text to a file and reads it back
+func readReportText(t *testing.T, text string) *Report {
+	dir, err := ioutil.TempDir("", "release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	reportPath := filepath.Join(dir, "report")
+	if err := ioutil.WriteFile(reportPath, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	report, err := ReadReport(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+// A report of every sample variant against the older release
+func olderReport(t *testing.T) string {
+	header, variants, err := vcf.ReadVcfFrom(strings.NewReader(sampleVcf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := matcher.WriteReport(&out, matcher.ReportConfig{IncludeAllVariants: true}, loadClient(t, olderVcf, olderSubmissions), header, variants); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestReanalyze(t *testing.T) {
+	report := readReportText(t, olderReport(t))
+	if len(report.Rows) != 3 {
+		t.Fatalf("expected the report to have 3 variants, got %v", report.Rows)
+	}
+	reanalysis, err := Reanalyze(loadClient(t, newerVcf, newerSubmissions), report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reanalysis.IdentityHeaders, []string{"Chromosome", "Begin", "End", "Ref", "Alt", "Rsid", "Zygosity", "Clinvar ID", "Genes"}) {
+		t.Errorf("unexpected identity headers %v", reanalysis.IdentityHeaders)
+	}
+	if len(reanalysis.Variants) != 2 {
+		t.Fatalf("expected the reclassified and removed variants, got %+v", reanalysis.Variants)
+	}
+	reclassified := reanalysis.Variants[0]
+	if !reflect.DeepEqual(reclassified.Changed, []string{"Max Pathogenicity", "# VUS", "# Likely Path"}) {
+		t.Errorf("unexpected changes %v", reclassified.Changed)
+	}
+	if reclassified.Variant["Clinvar ID"] != "100" || reclassified.Old["Max Pathogenicity"] != "VUS" ||
+		reclassified.New["Max Pathogenicity"] != "Likely Pathogenic" {
+		t.Errorf("unexpected variant %+v", reclassified)
+	}
+	removed := reanalysis.Variants[1]
+	if removed.Variant["Clinvar ID"] != "102" || !reflect.DeepEqual(removed.Changed, []string{RemovedFromClinvar}) || removed.New != nil {
+		t.Errorf("expected 102 to be removed, got %+v", removed)
+	}
+
+	var out bytes.Buffer
+	if err := reanalysis.WriteCSV(&out); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[0][9] != "Changed" || records[0][10] != "Old Max Pathogenicity" || records[0][11] != "New Max Pathogenicity" {
+		t.Errorf("unexpected CSV %v", records)
+	}
+	if removed := records[2]; removed[9] != RemovedFromClinvar || removed[10] != "Benign" || removed[11] != "" {
+		t.Errorf("expected the new columns blank for a removed variant, got %v", removed)
+	}
+}
+
+func TestReanalyzeByPosition(t *testing.T) {
+	report := readReportText(t, `[
+  {"Chromosome": "chr1", "Begin": "1000", "Ref": "A", "Alt": "G", "Max Pathogenicity": "VUS"},
+  {"Chromosome": "1", "Begin": "2000", "Ref": "C", "Alt": "T", "Max Pathogenicity": "Pathogenic"},
+  {"Chromosome": "1", "Begin": "2000", "Ref": "C", "Alt": "G", "Max Pathogenicity": "Pathogenic"}
+]`)
+	reanalysis, err := Reanalyze(loadClient(t, newerVcf, newerSubmissions), report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reanalysis.Variants) != 2 {
+		t.Fatalf("expected the reclassified variant and the other alt, got %+v", reanalysis.Variants)
+	}
+	if reanalysis.Variants[0].New["Max Pathogenicity"] != "Likely Pathogenic" || reanalysis.Variants[1].Changed[0] != RemovedFromClinvar {
+		t.Errorf("unexpected variants %+v", reanalysis.Variants)
+	}
+	if !reflect.DeepEqual(reanalysis.ComparedHeaders, []string{"Max Pathogenicity"}) {
+		t.Errorf("expected only the report's columns to be compared, got %v", reanalysis.ComparedHeaders)
+	}
+}
+
+func TestReanalyzeErrors(t *testing.T) {
+	client := loadClient(t, newerVcf, newerSubmissions)
+	tests := []string{
+		"Chromosome,Begin,Ref,Alt,Clinvar ID\n1,1000,A,G,100\n",
+		"Chromosome,Begin,Max Pathogenicity\n1,1000,Pathogenic\n",
+	}
+	for _, text := range tests {
+		if _, err := Reanalyze(client, readReportText(t, text)); err == nil {
+			t.Errorf("%q: expected an error", text)
+		}
+	}
+	dir, err := ioutil.TempDir("", "release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	empty := filepath.Join(dir, "empty.csv")
+	ioutil.WriteFile(empty, []byte("\n  \n"), 0644)
+	if _, err := ReadReport(empty); err == nil {
+		t.Errorf("expected an error for an empty report")
+	}
+}
@@ -0,0 +1,51 @@
+package release
+
+import (
+	"fmt"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+	log "github.com/sirupsen/logrus"
+)
+
+// One ClinVar release, from its VCF, its submission summary or both
+type Release struct {
+	// ClinVar's fileDate, blank when the VCF doesn't say or there's no VCF
+	Date string
+	// Variants for each variation ID, nil without a VCF
+	Variants map[string]*vcf.VcfLine
+	// Submissions for each variation ID, nil without a submission summary
+	Submissions map[string][]*clinvar.ClinvarSubmission
+}
+
+// Loads a release, either path can be blank to leave that part out
+func Load(vcfPath string, submissionPath string) (*Release, error) {
+	if vcfPath == "" && submissionPath == "" {
+		return nil, fmt.Errorf("a ClinVar release needs a VCF, a submission summary or both")
+	}
+	release := &Release{}
+	if vcfPath != "" {
+		log.Infof("Loading clinvar assessments from %s", vcfPath)
+		header, lines, err := vcf.ReadVcfWithHeader(vcfPath)
+		if err != nil {
+			return nil, err
+		}
+		release.Date = clinvar.ReleaseDate(header)
+		release.Variants = make(map[string]*vcf.VcfLine, len(lines))
+		for _, line := range lines {
+			if _, ok := release.Variants[line.ID]; !ok {
+				release.Variants[line.ID] = line
+			}
+		}
+		log.Infof("Clinvar Assessment Count: %d\n", len(lines))
+	}
+	if submissionPath != "" {
+		submissions, err := clinvar.ParseSubmissionSummary(submissionPath)
+		if err != nil {
+			return nil, err
+		}
+		release.Submissions = clinvar.GroupSubmissions(submissions)
+		log.Infof("Clinvar Submission Count: %d\n", len(submissions))
+	}
+	return release, nil
+}
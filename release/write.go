@@ -0,0 +1,97 @@
+package release
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+var Formats = []string{FormatCSV, FormatJSON}
+
+var csvHeaders = []string{
+	"Variation ID", "Chromosome", "Position", "Ref", "Alt", "Genes", "Category", "Changes",
+	"Old Classification", "New Classification", "Old Clinical Significance", "New Clinical Significance",
+	"Old Review Status", "New Review Status", "Old Stars", "New Stars", "Old Submissions", "New Submissions",
+	"Submissions Added", "Submissions Removed", "Submissions Reclassified",
+}
+
+// Writes a row per change, leaving the old columns blank for new variants and the new ones for
+// removed variants
+func WriteCSV(w io.Writer, changes []*Change) error {
+	writer := csv.NewWriter(w)
+	writer.Write(csvHeaders)
+	for _, change := range changes {
+		position := ""
+		if change.Position > 0 {
+			position = strconv.Itoa(change.Position)
+		}
+		record := []string{
+			change.VariationID, change.Chromosome, position, change.Ref, change.Alt,
+			strings.Join(change.Genes, ","), change.Category, strings.Join(change.Changes, ","),
+		}
+		record = append(record, sideValues(change.Old, change.New)...)
+		record = append(record,
+			formatSubmissions(change.SubmissionsAdded),
+			formatSubmissions(change.SubmissionsRemoved),
+			formatSubmissions(change.SubmissionsReclassified),
+		)
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Old and new values side by side, in the order of the headers
+func sideValues(older *Side, newer *Side) []string {
+	values := make([]string, 10)
+	for i, s := range []*Side{older, newer} {
+		if s == nil {
+			continue
+		}
+		values[i] = s.Classification
+		values[2+i] = s.ClinicalSignificance
+		values[4+i] = s.ReviewStatus
+		values[6+i] = formatCount(s.Stars)
+		values[8+i] = formatCount(s.Submissions)
+	}
+	return values
+}
+
+func formatCount(count *int) string {
+	if count == nil {
+		return ""
+	}
+	return strconv.Itoa(*count)
+}
+
+// Like SCV000077546.3 (Invitae): Uncertain significance->Pathogenic, separated by semicolons
+func formatSubmissions(submissions []SubmissionChange) string {
+	formatted := make([]string, 0, len(submissions))
+	for _, submission := range submissions {
+		significance := submission.NewSignificance
+		if submission.OldSignificance != "" && submission.NewSignificance != "" {
+			significance = submission.OldSignificance + "->" + submission.NewSignificance
+		} else if submission.OldSignificance != "" {
+			significance = submission.OldSignificance
+		}
+		formatted = append(formatted, fmt.Sprintf("%s (%s): %s", submission.SCV, submission.Submitter, significance))
+	}
+	return strings.Join(formatted, "; ")
+}
+
+func WriteJSON(w io.Writer, changes []*Change) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(changes)
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kazmiekr/clinvar-matcher/lookup"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+type VariantsResponse struct {
+	ClinvarRelease string `json:"clinvar_release"`
+	// Variants found, which can be more than were returned when Truncated
+	Count     int                     `json:"count"`
+	Truncated bool                    `json:"truncated"`
+	Variants  []*lookup.VariantResult `json:"variants"`
+}
+
+type HealthResponse struct {
+	Status             string       `json:"status"`
+	ClinvarRelease     string       `json:"clinvar_release"`
+	Reload             ReloadStatus `json:"reload"`
+	Variants           int          `json:"variants"`
+	Submissions        int          `json:"submissions"`
+	UptimeSeconds      float64      `json:"uptime_seconds"`
+	ReportsInProgress  int          `json:"reports_in_progress"`
+	MaxReportsAtOnce   int          `json:"max_reports_at_once"`
+	MaxUploadBytes     int64        `json:"max_upload_bytes"`
+	MaxResultsReturned int          `json:"max_results_returned"`
+}
+
+func (server *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	client := requestClient(r)
+	writeJSON(w, http.StatusOK, HealthResponse{
+		Status:             "ok",
+		ClinvarRelease:     client.ReleaseDate,
+		Reload:             server.reloadStatus(),
+		Variants:           client.VariantCount(),
+		Submissions:        client.SubmittedVariantCount(),
+		UptimeSeconds:      time.Since(server.started).Seconds(),
+		ReportsInProgress:  len(server.reports),
+		MaxReportsAtOnce:   server.Config.MaxConcurrentReports,
+		MaxUploadBytes:     server.Config.MaxUploadBytes,
+		MaxResultsReturned: server.Config.MaxResults,
+	})
+}
+
+// GET /variants?chrom=17&pos=41245466&ref=G&alt=A, ref and alt are optional
+func (server *Server) handleVariants(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	query := r.URL.Query()
+	chrom := query.Get("chrom")
+	pos, err := strconv.Atoi(query.Get("pos"))
+	if chrom == "" || err != nil || pos < 1 {
+		writeError(w, http.StatusBadRequest, "chrom and a 1-based pos are required")
+		return
+	}
+	ref, alt := query.Get("ref"), query.Get("alt")
+	variants := make([]*vcf.VcfLine, 0)
+	for _, variant := range requestClient(r).VariantsAt(chrom, pos) {
+		if ref != "" && !strings.EqualFold(variant.Ref, ref) {
+			continue
+		}
+		if alt != "" && !strings.EqualFold(variant.Alt, alt) {
+			continue
+		}
+		variants = append(variants, variant)
+	}
+	server.writeVariants(w, r, variants)
+}
+
+// GET /rsids/rs80357906
+func (server *Server) handleRsid(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	rsid := strings.ToLower(strings.TrimPrefix(r.URL.Path, "/rsids/"))
+	if !strings.HasPrefix(rsid, "rs") {
+		rsid = "rs" + rsid
+	}
+	server.writeVariants(w, r, requestClient(r).VariantsByRsid[rsid])
+}
+
+// GET /variations/17661, by ClinVar variation ID
+func (server *Server) handleVariation(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/variations/")
+	server.writeVariants(w, r, requestClient(r).VariantsByID[id])
+}
+
+// GET /genes/BRCA1, by GENEINFO gene symbol
+func (server *Server) handleGene(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	gene := strings.TrimPrefix(r.URL.Path, "/genes/")
+	server.writeVariants(w, r, requestClient(r).VariantsInGene(gene))
+}
+
+// Writes the variants, up to the limit query parameter or MaxResults. Adding submissions=true
+// includes each variant's submissions. A lookup that finds nothing is a 404
+func (server *Server) writeVariants(w http.ResponseWriter, r *http.Request, variants []*vcf.VcfLine) {
+	query := r.URL.Query()
+	limit := server.Config.MaxResults
+	if value := query.Get("limit"); value != "" {
+		requested, err := strconv.Atoi(value)
+		if err != nil || requested < 1 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit has to be a positive number, got %s", value))
+			return
+		}
+		if requested < limit {
+			limit = requested
+		}
+	}
+	withSubmissions, _ := strconv.ParseBool(query.Get("submissions"))
+
+	if len(variants) == 0 {
+		writeError(w, http.StatusNotFound, "no ClinVar variants found")
+		return
+	}
+	client := requestClient(r)
+	response := VariantsResponse{
+		ClinvarRelease: client.ReleaseDate,
+		Count:          len(variants),
+		Variants:       make([]*lookup.VariantResult, 0, len(variants)),
+	}
+	if len(variants) > limit {
+		variants = variants[:limit]
+		response.Truncated = true
+	}
+	for _, variant := range variants {
+		response.Variants = append(response.Variants, lookup.NewVariantResult(client, variant, withSubmissions))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestLookups(t *testing.T) {
+	server := New(Config{}, loadTestClinvar(t, "2020-07-06"))
+	tests := []struct {
+		target    string
+		status    int
+		count     int
+		returned  int
+		truncated bool
+	}{
+		{"/variants?chrom=13&pos=32900000", http.StatusOK, 1, 1, false},
+		{"/variants?chrom=chr13&pos=32900000&ref=g&alt=a", http.StatusOK, 1, 1, false},
+		{"/variants?chrom=13&pos=32900000&alt=T", http.StatusNotFound, 0, 0, false},
+		{"/variants?chrom=13", http.StatusBadRequest, 0, 0, false},
+		{"/variants?chrom=13&pos=0", http.StatusBadRequest, 0, 0, false},
+		{"/rsids/rs80357906", http.StatusOK, 1, 1, false},
+		{"/rsids/80357906", http.StatusOK, 1, 1, false},
+		{"/rsids/rs1", http.StatusNotFound, 0, 0, false},
+		{"/variations/104", http.StatusOK, 1, 1, false},
+		{"/variations/999", http.StatusNotFound, 0, 0, false},
+		{"/genes/brca2", http.StatusOK, 2, 2, false},
+		{"/genes/BRCA2?limit=1", http.StatusOK, 2, 1, true},
+		{"/genes/BRCA2?limit=0", http.StatusBadRequest, 0, 0, false},
+		{"/genes/BRCA2?limit=x", http.StatusBadRequest, 0, 0, false},
+	}
+	for _, test := range tests {
+		recorder := serve(server, http.MethodGet, test.target, nil)
+		if recorder.Code != test.status {
+			t.Errorf("%s: expected %d, got %d: %s", test.target, test.status, recorder.Code, recorder.Body.String())
+			continue
+		}
+		if test.status != http.StatusOK {
+			var response errorResponse
+			decodeResponse(t, recorder, &response)
+			if response.Error == "" {
+				t.Errorf("%s: expected an error message", test.target)
+			}
+			continue
+		}
+		var response VariantsResponse
+		decodeResponse(t, recorder, &response)
+		if response.ClinvarRelease != "2020-07-06" || response.Count != test.count ||
+			len(response.Variants) != test.returned || response.Truncated != test.truncated {
+			t.Errorf("%s: unexpected response %+v", test.target, response)
+		}
+	}
+}
+
+func TestLookupSubmissions(t *testing.T) {
+	server := New(Config{}, loadTestClinvar(t, "2020-07-06"))
+	var response VariantsResponse
+	decodeResponse(t, serve(server, http.MethodGet, "/variations/103", nil), &response)
+	if len(response.Variants) != 1 || len(response.Variants[0].Submissions) != 0 {
+		t.Fatalf("expected no submissions without asking, got %+v", response.Variants)
+	}
+	decodeResponse(t, serve(server, http.MethodGet, "/variations/103?submissions=true", nil), &response)
+	variant := response.Variants[0]
+	if len(variant.Submissions) != 1 || variant.Submissions[0].Submitter != "Lab 1" {
+		t.Errorf("expected Lab 1's submission, got %+v", variant.Submissions)
+	}
+	if variant.Chromosome != "13" || variant.Position != 32900000 || variant.Stars != 3 {
+		t.Errorf("unexpected variant %+v", variant)
+	}
+}
+
+func TestLookupsOnlyAllowGet(t *testing.T) {
+	server := New(Config{}, loadTestClinvar(t, "2020-07-06"))
+	for _, target := range []string{"/variants?chrom=13&pos=32900000", "/rsids/rs555", "/variations/104", "/genes/BRCA2"} {
+		recorder := serve(server, http.MethodPost, target, nil)
+		if recorder.Code != http.StatusMethodNotAllowed || recorder.Header().Get("Allow") != http.MethodGet {
+			t.Errorf("%s: expected 405 allowing GET, got %d", target, recorder.Code)
+		}
+		if recorder := serve(server, http.MethodHead, target, nil); recorder.Code != http.StatusOK {
+			t.Errorf("%s: expected HEAD to work, got %d", target, recorder.Code)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	"github.com/kazmiekr/clinvar-matcher/matcher"
+	"github.com/kazmiekr/clinvar-matcher/panel"
+	"github.com/kazmiekr/clinvar-matcher/rawdata"
+	"github.com/kazmiekr/clinvar-matcher/vcf"
+)
+
+const (
+	ReportFormatCSV  = "csv"
+	ReportFormatJSON = "json"
+)
+
+// Stops reading past the upload limit and remembers it did, since the error that comes back
+// through the VCF parser has lost its type
+type uploadReader struct {
+	reader    io.Reader
+	remaining int64
+	tooLarge  bool
+}
+
+func (upload *uploadReader) Read(p []byte) (int, error) {
+	if upload.remaining <= 0 {
+		// Exactly at the limit is fine, as long as that's the end
+		var extra [1]byte
+		if n, err := upload.reader.Read(extra[:]); n == 0 && err != nil {
+			return 0, err
+		}
+		upload.tooLarge = true
+		return 0, fmt.Errorf("upload is larger than the limit")
+	}
+	if int64(len(p)) > upload.remaining {
+		p = p[:upload.remaining]
+	}
+	n, err := upload.reader.Read(p)
+	upload.remaining -= int64(n)
+	return n, err
+}
+
+// POST /report with a VCF, BCF or raw genotype file as the body, or as the first file of a multipart
+// form, optionally compressed. Query parameters pick the report the way the command line flags do:
+// format (csv or json), mode, columns, filter, genes, include_all, rsid_fallback, allele_transforms,
+// sv_overlap, sf_version, sex, min_dp and min_gq
+func (server *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	select {
+	case server.reports <- struct{}{}:
+		defer func() { <-server.reports }()
+	default:
+		w.Header().Set("Retry-After", "30")
+		writeError(w, http.StatusServiceUnavailable, "too many reports in progress, try again shortly")
+		return
+	}
+
+	config, format, err := reportConfig(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if r.ContentLength > server.Config.MaxUploadBytes {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("uploads are limited to %d bytes", server.Config.MaxUploadBytes))
+		return
+	}
+	upload := &uploadReader{reader: r.Body, remaining: server.Config.MaxUploadBytes}
+	decompressed, err := uploadBody(r, upload)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// A small gzip can inflate to far more than the upload limit, so the decompressed size is capped too
+	inflated := &uploadReader{reader: decompressed, remaining: server.Config.MaxUploadBytes * MaxDecompressedRatio}
+	body := struct {
+		io.Reader
+		io.Closer
+	}{inflated, decompressed}
+
+	client := requestClient(r)
+	header, variants, err := readUpload(client, body)
+	if upload.tooLarge {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("uploads are limited to %d bytes", server.Config.MaxUploadBytes))
+		return
+	}
+	if inflated.tooLarge {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("uploads are limited to %d bytes once decompressed",
+			server.Config.MaxUploadBytes*MaxDecompressedRatio))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Stops matching once the client goes away or the request runs past its timeout, freeing the slot
+	ctx, cancel := context.WithTimeout(r.Context(), server.Config.RequestTimeout)
+	defer cancel()
+	var report bytes.Buffer
+	if err := matcher.WriteReportContext(ctx, &report, config, client, header, variants); err != nil {
+		if ctx.Err() != nil {
+			writeError(w, http.StatusServiceUnavailable, fmt.Sprintf("report stopped: %v", ctx.Err()))
+			return
+		}
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if format == ReportFormatCSV {
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+		w.Write(report.Bytes())
+		return
+	}
+	rows, err := csvToObjects(&report)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, rows)
+}
+
+// The upload is either the whole body or the first file in a multipart form
+func uploadBody(r *http.Request, upload io.Reader) (io.ReadCloser, error) {
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{upload, r.Body}
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return vcf.Decompress(r.Body)
+	}
+	if params["boundary"] == "" {
+		return nil, fmt.Errorf("multipart upload is missing its boundary")
+	}
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, fmt.Errorf("no file in the multipart upload")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FileName() != "" {
+			return vcf.Decompress(part)
+		}
+	}
+}
+
+func readUpload(client *clinvar.ClinvarClient, body io.ReadCloser) (*vcf.Header, []*vcf.VcfLine, error) {
+	defer body.Close()
+	source := bufio.NewReaderSize(body, rawdata.DetectBytes)
+	format, err := rawdata.DetectReaderFormat(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	if format == rawdata.FormatVcf {
+		return vcf.ReadVcfFrom(source)
+	}
+	variants, err := rawdata.ReadRawGenotypesFrom(source, format, client)
+	return vcf.NewHeader(), variants, err
+}
+
+func reportConfig(query url.Values) (matcher.ReportConfig, string, error) {
+	config := matcher.ReportConfig{
+		OutputFile:               "the response",
+		Mode:                     query.Get("mode"),
+		Filter:                   query.Get("filter"),
+		SecondaryFindingsVersion: panel.LatestSecondaryFindingsVersion,
+		Sex:                      matcher.SexAuto,
+		SVOverlap:                clinvar.DefaultSVOverlap,
+		MinDepth:                 matcher.DefaultMinDepth,
+		MinGenotypeQuality:       matcher.DefaultMinGenotypeQuality,
+	}
+	format := strings.ToLower(query.Get("format"))
+	switch format {
+	case "":
+		format = ReportFormatCSV
+	case ReportFormatCSV, ReportFormatJSON:
+	default:
+		return config, format, fmt.Errorf("unknown format %s, use csv or json", format)
+	}
+	if value := query.Get("sf_version"); value != "" {
+		config.SecondaryFindingsVersion = value
+	}
+	if value := query.Get("sex"); value != "" {
+		config.Sex = value
+	}
+	if config.Mode == matcher.ModeTrio {
+		return config, format, fmt.Errorf("trio reports need a PED file, which the server doesn't take")
+	}
+	if config.Mode == matcher.ModeCohort {
+		return config, format, fmt.Errorf("cohort reports are written as two files, run them from the command line")
+	}
+	if value := query.Get("columns"); value != "" {
+		config.Columns = strings.Split(value, ",")
+	}
+	// Gene symbols only, so a genes parameter can't name a file on the server
+	config.GeneSymbolsOnly = true
+	if value := query.Get("genes"); value != "" {
+		for _, gene := range strings.Split(value, ",") {
+			if !panel.IsGeneSymbol(strings.TrimSpace(gene)) {
+				return config, format, fmt.Errorf("%q isn't a gene symbol", gene)
+			}
+			config.Genes = append(config.Genes, strings.TrimSpace(gene))
+		}
+	}
+
+	var err error
+	bools := map[string]*bool{
+		"include_all":       &config.IncludeAllVariants,
+		"rsid_fallback":     &config.RsidFallback,
+		"allele_transforms": &config.AlleleTransforms,
+	}
+	for name, target := range bools {
+		if value := query.Get(name); value != "" {
+			if *target, err = strconv.ParseBool(value); err != nil {
+				return config, format, fmt.Errorf("%s has to be true or false, got %s", name, value)
+			}
+		}
+	}
+	ints := map[string]*int{
+		"min_dp": &config.MinDepth,
+		"min_gq": &config.MinGenotypeQuality,
+	}
+	for name, target := range ints {
+		if value := query.Get(name); value != "" {
+			if *target, err = strconv.Atoi(value); err != nil {
+				return config, format, fmt.Errorf("%s has to be a number, got %s", name, value)
+			}
+		}
+	}
+	if value := query.Get("sv_overlap"); value != "" {
+		if config.SVOverlap, err = strconv.ParseFloat(value, 64); err != nil {
+			return config, format, fmt.Errorf("sv_overlap has to be a number, got %s", value)
+		}
+	}
+	return config, format, nil
+}
+
+// Turns the CSV report into one JSON object per row, keyed by the column headers
+func csvToObjects(report io.Reader) ([]map[string]string, error) {
+	reader := csv.NewReader(report)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]map[string]string, 0)
+	if len(records) == 0 {
+		return rows, nil
+	}
+	headers := records[0]
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(headers))
+		for i, value := range record {
+			if i < len(headers) {
+				row[headers[i]] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
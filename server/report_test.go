@@ -0,0 +1,210 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const testSampleVcf = `##fileformat=VCFv4.2
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	SAMPLE
+1	1000	.	A	G	50	PASS	.	GT	0/1
+13	32900000	.	G	A	50	PASS	.	GT	1/1
+13	32900100	.	A	T	50	LowQual	.	GT	0/1
+7	500	.	C	T	50	PASS	.	GT	0/1
+`
+
+func gzipped(t *testing.T, text string) []byte {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write([]byte(text)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return compressed.Bytes()
+}
+
+func reportRows(t *testing.T, recorder *httptest.ResponseRecorder) [][]string {
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	rows, err := csv.NewReader(recorder.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestReport(t *testing.T) {
+	server := New(Config{}, loadTestClinvar(t, "2020-07-06"))
+	tests := []struct {
+		name  string
+		query string
+		body  []byte
+		rows  int
+	}{
+		{"plain VCF", "", []byte(testSampleVcf), 2},
+		{"gzipped VCF", "", gzipped(t, testSampleVcf), 2},
+		{"every variant", "?include_all=true", []byte(testSampleVcf), 3},
+		{"gene panel", "?genes=GENEA", []byte(testSampleVcf), 1},
+		{"filter", "?filter=" + url.QueryEscape("stars >= 3"), []byte(testSampleVcf), 1},
+	}
+	for _, test := range tests {
+		recorder := serve(server, http.MethodPost, "/report"+test.query, bytes.NewReader(test.body))
+		if recorder.Header().Get("Content-Type") != "text/csv" {
+			t.Errorf("%s: expected CSV, got %s", test.name, recorder.Header().Get("Content-Type"))
+		}
+		if rows := reportRows(t, recorder); len(rows)-1 != test.rows {
+			t.Errorf("%s: expected %d rows, got %v", test.name, test.rows, rows)
+		}
+	}
+}
+
+func TestReportJSON(t *testing.T) {
+	server := New(Config{}, loadTestClinvar(t, "2020-07-06"))
+	recorder := serve(server, http.MethodPost, "/report?format=json&columns=chromosome,begin,clinvar_id", strings.NewReader(testSampleVcf))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var rows []map[string]string
+	decodeResponse(t, recorder, &rows)
+	if len(rows) != 2 || rows[1]["Chromosome"] != "13" || rows[1]["Begin"] != "32900000" {
+		t.Errorf("unexpected rows %v", rows)
+	}
+}
+
+func TestReportMultipart(t *testing.T) {
+	server := New(Config{}, loadTestClinvar(t, "2020-07-06"))
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("note", "not the file")
+	part, err := form.CreateFormFile("vcf", "sample.vcf.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(gzipped(t, testSampleVcf))
+	form.Close()
+
+	request := httptest.NewRequest(http.MethodPost, "/report", &body)
+	request.Header.Set("Content-Type", form.FormDataContentType())
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, request)
+	if rows := reportRows(t, recorder); len(rows) != 3 {
+		t.Errorf("expected 2 rows, got %v", rows)
+	}
+
+	request = httptest.NewRequest(http.MethodPost, "/report", strings.NewReader(testSampleVcf))
+	request.Header.Set("Content-Type", "multipart/form-data")
+	recorder = httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without a boundary, got %d", recorder.Code)
+	}
+}
+
+func TestReportBadRequests(t *testing.T) {
+	server := New(Config{}, loadTestClinvar(t, "2020-07-06"))
+	tests := []struct {
+		name  string
+		query string
+		body  string
+	}{
+		{"gene list file", "?genes=cardio_panel.txt", testSampleVcf},
+		{"gene path", "?genes=BRCA1,../genes", testSampleVcf},
+		{"trio mode", "?mode=trio", testSampleVcf},
+		{"cohort mode", "?mode=cohort", testSampleVcf},
+		{"unknown mode", "?mode=everything", testSampleVcf},
+		{"unknown format", "?format=xml", testSampleVcf},
+		{"bad bool", "?include_all=maybe", testSampleVcf},
+		{"bad number", "?min_dp=deep", testSampleVcf},
+		{"bad filter", "?filter=" + url.QueryEscape("stars >="), testSampleVcf},
+		{"not a VCF", "", "hello\n"},
+	}
+	for _, test := range tests {
+		recorder := serve(server, http.MethodPost, "/report"+test.query, strings.NewReader(test.body))
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", test.name, recorder.Code, recorder.Body.String())
+		}
+	}
+	if recorder := serve(server, http.MethodGet, "/report", nil); recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for a GET, got %d", recorder.Code)
+	}
+}
+
+// Hides the length, like a chunked upload
+type unknownLength struct {
+	io.Reader
+}
+
+func TestReportUploadLimits(t *testing.T) {
+	server := New(Config{MaxUploadBytes: 512}, loadTestClinvar(t, "2020-07-06"))
+	large := testSampleVcf + strings.Repeat("7\t500\t.\tC\tT\t50\tPASS\t.\tGT\t0/1\n", 20)
+
+	recorder := serve(server, http.MethodPost, "/report", strings.NewReader(large))
+	if recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 from the content length, got %d", recorder.Code)
+	}
+	recorder = serve(server, http.MethodPost, "/report", unknownLength{strings.NewReader(large)})
+	if recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 reading the body, got %d", recorder.Code)
+	}
+
+	// Compresses to well under the limit, but inflates to far more than 20 times it
+	bomb := gzipped(t, testSampleVcf+strings.Repeat("7\t500\t.\tC\tT\t50\tPASS\t.\tGT\t0/1\n", 2000))
+	if int64(len(bomb)) > server.Config.MaxUploadBytes {
+		t.Fatalf("the compressed upload is %d bytes, over the limit", len(bomb))
+	}
+	recorder = serve(server, http.MethodPost, "/report", bytes.NewReader(bomb))
+	if recorder.Code != http.StatusRequestEntityTooLarge || !strings.Contains(recorder.Body.String(), "decompressed") {
+		t.Errorf("expected 413 once decompressed, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	// Exactly at the limit is fine
+	server = New(Config{MaxUploadBytes: int64(len(testSampleVcf))}, loadTestClinvar(t, "2020-07-06"))
+	recorder = serve(server, http.MethodPost, "/report", unknownLength{strings.NewReader(testSampleVcf)})
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected an upload at the limit to work, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestReportTooManyAtOnce(t *testing.T) {
+	server := New(Config{MaxConcurrentReports: 1}, loadTestClinvar(t, "2020-07-06"))
+	server.reports <- struct{}{}
+	recorder := serve(server, http.MethodPost, "/report", strings.NewReader(testSampleVcf))
+	if recorder.Code != http.StatusServiceUnavailable || recorder.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 503 with Retry-After, got %d", recorder.Code)
+	}
+	<-server.reports
+	recorder = serve(server, http.MethodPost, "/report", strings.NewReader(testSampleVcf))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected the report to run once the slot is free, got %d", recorder.Code)
+	}
+	if len(server.reports) != 0 {
+		t.Errorf("expected the report to give its slot back")
+	}
+}
+
+func TestReportStopsWhenCancelled(t *testing.T) {
+	server := New(Config{}, loadTestClinvar(t, "2020-07-06"))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	request := httptest.NewRequest(http.MethodPost, "/report", strings.NewReader(testSampleVcf)).WithContext(ctx)
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 once the request is cancelled, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if len(server.reports) != 0 {
+		t.Errorf("expected the report to give its slot back")
+	}
+}
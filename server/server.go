@@ -0,0 +1,316 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultAddr                 = ":8080"
+	DefaultMaxUploadBytes       = 100 << 20
+	DefaultMaxConcurrentReports = 4
+	DefaultMaxResults           = 1000
+	DefaultRequestTimeout       = 5 * time.Minute
+	// Most an upload is allowed to grow to once it's decompressed, as a multiple of MaxUploadBytes
+	MaxDecompressedRatio = 20
+	// How long in flight requests get to finish once the server is asked to stop
+	shutdownTimeout = 30 * time.Second
+	maxHeaderBytes  = 1 << 20
+	// Response header with the ClinVar release that answered the request
+	ReleaseHeader = "X-Clinvar-Release"
+)
+
+var ErrReloadInProgress = errors.New("a ClinVar reload is already in progress")
+
+// Loads a ClinVar release, which for a reload means downloading the latest if the paths are URLs
+type Loader func() (*clinvar.ClinvarClient, error)
+
+type clientKey struct{}
+
+type Config struct {
+	Addr string
+	// Largest VCF upload accepted by /report, compressed size
+	MaxUploadBytes int64
+	// Reports running at once, more get a 503 rather than queueing up uploads in memory
+	MaxConcurrentReports int
+	// Most variants a lookup returns, like all the variants in a large gene
+	MaxResults int
+	// Time allowed to read a request and to write its response
+	RequestTimeout time.Duration
+	// Loads a new release for reloads, nil turns reloading off
+	Reload Loader
+	// Reloads on this schedule as well as on SIGHUP and the admin endpoint, 0 turns it off
+	ReloadEvery time.Duration
+	// Bearer token for the admin endpoints, which are turned off without one
+	AdminToken string
+}
+
+type ReloadStatus struct {
+	InProgress bool       `json:"in_progress"`
+	LastReload *time.Time `json:"last_reload,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+}
+
+// Serves ClinVar lookups and reports over HTTP from a ClinvarClient that stays loaded, and can be
+// reloaded with a new release without stopping
+type Server struct {
+	Config Config
+	// The current *clinvar.ClinvarClient, swapped in whole by a reload so a request in progress
+	// keeps the release it started with
+	client    atomic.Value
+	reloading int32
+	status    ReloadStatus
+	statusMu  sync.Mutex
+	reports   chan struct{}
+	started   time.Time
+}
+
+// Fills in defaults for any limits left at zero
+func New(config Config, client *clinvar.ClinvarClient) *Server {
+	if config.Addr == "" {
+		config.Addr = DefaultAddr
+	}
+	if config.MaxUploadBytes <= 0 {
+		config.MaxUploadBytes = DefaultMaxUploadBytes
+	}
+	if config.MaxConcurrentReports <= 0 {
+		config.MaxConcurrentReports = DefaultMaxConcurrentReports
+	}
+	if config.MaxResults <= 0 {
+		config.MaxResults = DefaultMaxResults
+	}
+	if config.RequestTimeout <= 0 {
+		config.RequestTimeout = DefaultRequestTimeout
+	}
+	server := &Server{
+		Config:  config,
+		reports: make(chan struct{}, config.MaxConcurrentReports),
+		started: time.Now(),
+	}
+	server.client.Store(client)
+	return server
+}
+
+// The ClinVar release currently being served
+func (server *Server) Client() *clinvar.ClinvarClient {
+	return server.client.Load().(*clinvar.ClinvarClient)
+}
+
+// Loads a new release and swaps it in once it's ready, while the old one keeps serving. Only one
+// reload runs at a time, a second gets ErrReloadInProgress
+func (server *Server) Reload() error {
+	if server.Config.Reload == nil {
+		return errors.New("reloading isn't set up")
+	}
+	if !atomic.CompareAndSwapInt32(&server.reloading, 0, 1) {
+		return ErrReloadInProgress
+	}
+	defer atomic.StoreInt32(&server.reloading, 0)
+	server.setStatus(func(status *ReloadStatus) { status.InProgress = true })
+
+	previous := server.Client().ReleaseDate
+	log.Infof("Reloading ClinVar, currently serving release %s", previous)
+	client, err := server.Config.Reload()
+	if err != nil {
+		log.Infof("ClinVar reload failed, still serving release %s: %v", previous, err)
+		server.setStatus(func(status *ReloadStatus) {
+			status.InProgress = false
+			status.LastError = err.Error()
+		})
+		return err
+	}
+	server.client.Store(client)
+	log.Infof("Now serving ClinVar release %s, was %s", client.ReleaseDate, previous)
+	server.setStatus(func(status *ReloadStatus) {
+		status.InProgress = false
+		now := time.Now()
+		status.LastReload = &now
+		status.LastError = ""
+	})
+	return nil
+}
+
+// Starts a reload in the background, false when one is already running
+func (server *Server) startReload() bool {
+	if atomic.LoadInt32(&server.reloading) == 1 {
+		return false
+	}
+	go server.Reload()
+	return true
+}
+
+func (server *Server) setStatus(update func(status *ReloadStatus)) {
+	server.statusMu.Lock()
+	defer server.statusMu.Unlock()
+	update(&server.status)
+}
+
+func (server *Server) reloadStatus() ReloadStatus {
+	server.statusMu.Lock()
+	defer server.statusMu.Unlock()
+	return server.status
+}
+
+func (server *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", server.handleHealth)
+	mux.HandleFunc("/variants", server.handleVariants)
+	mux.HandleFunc("/rsids/", server.handleRsid)
+	mux.HandleFunc("/variations/", server.handleVariation)
+	mux.HandleFunc("/genes/", server.handleGene)
+	mux.HandleFunc("/report", server.handleReport)
+	mux.HandleFunc("/admin/reload", server.handleReload)
+	return logRequests(server.withClient(mux))
+}
+
+// Pins the request to the current release, and says which one it is in the response header
+func (server *Server) withClient(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := server.Client()
+		w.Header().Set(ReleaseHeader, client.ReleaseDate)
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientKey{}, client)))
+	})
+}
+
+func requestClient(r *http.Request) *clinvar.ClinvarClient {
+	return r.Context().Value(clientKey{}).(*clinvar.ClinvarClient)
+}
+
+// Serves until an interrupt or terminate signal arrives, then stops taking new connections and waits
+// for the requests in flight to finish. SIGHUP reloads ClinVar
+func (server *Server) Run(signals <-chan os.Signal) error {
+	httpServer := &http.Server{
+		Addr:              server.Config.Addr,
+		Handler:           server.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       server.Config.RequestTimeout,
+		WriteTimeout:      server.Config.RequestTimeout,
+		IdleTimeout:       2 * time.Minute,
+		MaxHeaderBytes:    maxHeaderBytes,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Infof("Serving ClinVar lookups on %s", server.Config.Addr)
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	var reloadTick <-chan time.Time
+	if server.Config.Reload != nil && server.Config.ReloadEvery > 0 {
+		ticker := time.NewTicker(server.Config.ReloadEvery)
+		defer ticker.Stop()
+		reloadTick = ticker.C
+	}
+
+	for running := true; running; {
+		select {
+		case err := <-serveErr:
+			return err
+		case <-reloadTick:
+			server.startReload()
+		case sig := <-signals:
+			if sig == syscall.SIGHUP && server.Config.Reload != nil {
+				server.startReload()
+				continue
+			}
+			log.Infof("Received %s, shutting down", sig)
+			running = false
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		return err
+	}
+	log.Infof("Server stopped")
+	return nil
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	recorder.status = status
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+func logRequests(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(recorder, r)
+		log.Infof("%s %s %d %v", r.Method, r.URL.RequestURI(), recorder.status, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type ReloadResponse struct {
+	Status         string `json:"status"`
+	ClinvarRelease string `json:"clinvar_release"`
+}
+
+// POST /admin/reload with an Authorization: Bearer <token> header starts a reload in the background.
+// GET /health shows when it's done
+func (server *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	if server.Config.AdminToken == "" {
+		writeError(w, http.StatusForbidden, "admin endpoints are turned off, start the server with an admin token")
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(server.Config.AdminToken)) != 1 {
+		writeError(w, http.StatusUnauthorized, "invalid admin token")
+		return
+	}
+	if server.Config.Reload == nil {
+		writeError(w, http.StatusNotImplemented, "reloading isn't set up")
+		return
+	}
+	release := requestClient(r).ReleaseDate
+	if !server.startReload() {
+		writeJSON(w, http.StatusConflict, ReloadResponse{Status: ErrReloadInProgress.Error(), ClinvarRelease: release})
+		return
+	}
+	writeJSON(w, http.StatusAccepted, ReloadResponse{Status: "reloading", ClinvarRelease: release})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Infof("Unable to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
+
+// Checks the method, writing a 405 when it's wrong
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method || (method == http.MethodGet && r.Method == http.MethodHead) {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, "use "+method)
+	return false
+}
@@ -0,0 +1,200 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kazmiekr/clinvar-matcher/clinvar"
+)
+
+const testClinvarVcf = `##fileformat=VCFv4.1
+##fileDate=%s
+##source=ClinVar
+##reference=GRCh37
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO
+1	1000	100	A	G	.	.	ALLELEID=1;CLNSIG=Pathogenic;CLNREVSTAT=criteria_provided,_single_submitter;CLNVC=single_nucleotide_variant;GENEINFO=GENEA:1;RS=111
+13	32900000	103	G	A	.	.	ALLELEID=4;CLNSIG=Pathogenic;CLNREVSTAT=reviewed_by_expert_panel;CLNVC=single_nucleotide_variant;GENEINFO=BRCA2:675;RS=80357906
+13	32900100	104	A	T	.	.	ALLELEID=5;CLNSIG=Likely_pathogenic;CLNREVSTAT=criteria_provided,_single_submitter;CLNVC=single_nucleotide_variant;GENEINFO=BRCA2:675;RS=555
+`
+
+const testSubmissionSummary = `#VariationID	ClinicalSignificance	DateLastEvaluated	Description	SubmittedPhenotypeInfo	ReportedPhenotypeInfo	ReviewStatus	CollectionMethod	OriginCounts	Submitter	SCV	SubmittedGeneSymbol	ExplanationOfInterpretation
+100	Pathogenic	Jan 01, 2019	-	Disease A	C0000:Disease A	criteria provided, single submitter	clinical testing	germline:1	Lab 0	SCV000000000.1	GENEA	-
+103	Pathogenic	Jan 01, 2019	-	Breast cancer	C0001:Breast cancer	reviewed by expert panel	clinical testing	germline:1	Lab 1	SCV000000001.1	BRCA2	-
+104	Likely pathogenic	Jan 01, 2019	-	Breast cancer	C0001:Breast cancer	criteria provided, single submitter	clinical testing	germline:1	Lab 2	SCV000000002.1	BRCA2	-
+`
+
+// Loads the small ClinVar release above, with the fileDate given
+func loadTestClinvar(t *testing.T, releaseDate string) *clinvar.ClinvarClient {
+	dir, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	vcfPath := filepath.Join(dir, "clinvar.vcf")
+	submissionPath := filepath.Join(dir, "submission_summary.txt")
+	vcfText := fmt.Sprintf(testClinvarVcf, releaseDate)
+	if err := ioutil.WriteFile(vcfPath, []byte(vcfText), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(submissionPath, []byte(testSubmissionSummary), 0644); err != nil {
+		t.Fatal(err)
+	}
+	client, err := clinvar.NewClinvar(vcfPath, submissionPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func serve(server *Server, method string, target string, body io.Reader) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(method, target, body))
+	return recorder
+}
+
+func decodeResponse(t *testing.T, recorder *httptest.ResponseRecorder, value interface{}) {
+	if err := json.Unmarshal(recorder.Body.Bytes(), value); err != nil {
+		t.Fatalf("unable to decode %q: %v", recorder.Body.String(), err)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	server := New(Config{MaxConcurrentReports: 2}, loadTestClinvar(t, "2020-07-06"))
+	recorder := serve(server, http.MethodGet, "/health", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", recorder.Code)
+	}
+	var health HealthResponse
+	decodeResponse(t, recorder, &health)
+	if health.Status != "ok" || health.ClinvarRelease != "2020-07-06" || health.Variants != 3 || health.Submissions != 3 {
+		t.Errorf("unexpected health %+v", health)
+	}
+	if health.MaxReportsAtOnce != 2 || health.MaxUploadBytes != DefaultMaxUploadBytes || health.MaxResultsReturned != DefaultMaxResults {
+		t.Errorf("expected the limits with defaults filled in, got %+v", health)
+	}
+	if release := recorder.Header().Get(ReleaseHeader); release != "2020-07-06" {
+		t.Errorf("expected the release header, got %q", release)
+	}
+
+	if recorder := serve(server, http.MethodPost, "/health", nil); recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for a POST, got %d", recorder.Code)
+	}
+}
+
+func reloadRequest(server *Server, token string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/admin/reload", nil)
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestAdminReloadAuth(t *testing.T) {
+	client := loadTestClinvar(t, "2020-07-06")
+	loader := func() (*clinvar.ClinvarClient, error) { return client, nil }
+
+	server := New(Config{Reload: loader}, client)
+	if recorder := reloadRequest(server, "secret"); recorder.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without an admin token set, got %d", recorder.Code)
+	}
+
+	server = New(Config{Reload: loader, AdminToken: "secret"}, client)
+	if recorder := reloadRequest(server, ""); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a token, got %d", recorder.Code)
+	}
+	if recorder := reloadRequest(server, "wrong"); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for the wrong token, got %d", recorder.Code)
+	}
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin/reload", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for a GET, got %d", recorder.Code)
+	}
+
+	server = New(Config{AdminToken: "secret"}, client)
+	if recorder := reloadRequest(server, "secret"); recorder.Code != http.StatusNotImplemented {
+		t.Errorf("expected 501 without a loader, got %d", recorder.Code)
+	}
+}
+
+func TestAdminReload(t *testing.T) {
+	current := loadTestClinvar(t, "2020-07-06")
+	next := loadTestClinvar(t, "2020-07-13")
+	release := make(chan struct{})
+	server := New(Config{AdminToken: "secret", Reload: func() (*clinvar.ClinvarClient, error) {
+		<-release
+		return next, nil
+	}}, current)
+
+	recorder := reloadRequest(server, "secret")
+	if recorder.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var response ReloadResponse
+	decodeResponse(t, recorder, &response)
+	if response.Status != "reloading" || response.ClinvarRelease != "2020-07-06" {
+		t.Errorf("expected the release being replaced, got %+v", response)
+	}
+
+	// The first reload is waiting on its loader, so a second one is turned away
+	for atomic.LoadInt32(&server.reloading) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	recorder = reloadRequest(server, "secret")
+	if recorder.Code != http.StatusConflict {
+		t.Errorf("expected 409 while reloading, got %d", recorder.Code)
+	}
+	if err := server.Reload(); err != ErrReloadInProgress {
+		t.Errorf("expected %v, got %v", ErrReloadInProgress, err)
+	}
+	var health HealthResponse
+	decodeResponse(t, serve(server, http.MethodGet, "/health", nil), &health)
+	if !health.Reload.InProgress || health.ClinvarRelease != "2020-07-06" {
+		t.Errorf("expected the old release to keep serving during the reload, got %+v", health)
+	}
+
+	close(release)
+	for atomic.LoadInt32(&server.reloading) == 1 {
+		time.Sleep(time.Millisecond)
+	}
+	recorder = serve(server, http.MethodGet, "/health", nil)
+	decodeResponse(t, recorder, &health)
+	if health.ClinvarRelease != "2020-07-13" || health.Reload.InProgress || health.Reload.LastReload == nil {
+		t.Errorf("expected the new release, got %+v", health)
+	}
+	if release := recorder.Header().Get(ReleaseHeader); release != "2020-07-13" {
+		t.Errorf("expected the new release in the header, got %q", release)
+	}
+}
+
+func TestReloadFailureKeepsServing(t *testing.T) {
+	current := loadTestClinvar(t, "2020-07-06")
+	server := New(Config{Reload: func() (*clinvar.ClinvarClient, error) {
+		return nil, errors.New("download failed")
+	}}, current)
+	if err := server.Reload(); err == nil {
+		t.Fatal("expected the reload to fail")
+	}
+	if server.Client() != current {
+		t.Errorf("expected the old release to keep serving")
+	}
+	status := server.reloadStatus()
+	if status.InProgress || status.LastError != "download failed" || status.LastReload != nil {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if err := New(Config{}, current).Reload(); err == nil {
+		t.Errorf("expected an error without a loader")
+	}
+}
@@ -0,0 +1,429 @@
+package vcf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kazmiekr/clinvar-matcher/progress"
+)
+
+// BCF2 typed value types, the low 4 bits of a type descriptor
+const (
+	bcfTypeMissing = 0
+	bcfTypeInt8    = 1
+	bcfTypeInt16   = 2
+	bcfTypeInt32   = 3
+	bcfTypeFloat   = 5
+	bcfTypeChar    = 7
+)
+
+const (
+	bcfFloatMissing    = 0x7F800001
+	bcfFloatEndOfValue = 0x7F800002
+)
+
+var bcfMagic = []byte("BCF\x02")
+
+// Reads the records of a BCF2 file one at a time as VcfLines, using the header's dictionaries to
+// turn the indexes back into names
+type BcfReader struct {
+	Header *Header
+	reader *bufio.Reader
+}
+
+func ReadBcf(bcfPath string) (*Header, []*VcfLine, error) {
+	file, err := os.Open(bcfPath)
+	if err != nil {
+		return NewHeader(), make([]*VcfLine, 0), err
+	}
+	defer file.Close()
+	return readBcfLines(file, nil)
+}
+
+func readBcfLines(input io.Reader, tracker *progress.Tracker) (*Header, []*VcfLine, error) {
+	lines := make([]*VcfLine, 0)
+	reader, err := NewBcfReader(input)
+	if err != nil {
+		return NewHeader(), lines, err
+	}
+	for {
+		line, err := reader.Read()
+		if err == io.EOF {
+			return reader.Header, lines, tracker.Done()
+		}
+		if err != nil {
+			return reader.Header, lines, err
+		}
+		lines = append(lines, line)
+		if err := tracker.Records(1); err != nil {
+			return reader.Header, lines, err
+		}
+	}
+}
+
+// Reads the BCF header from a BGZF compressed or uncompressed BCF stream
+func NewBcfReader(input io.Reader) (*BcfReader, error) {
+	buffered := bufio.NewReader(input)
+	if start, err := buffered.Peek(2); err == nil && start[0] == 0x1f && start[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		buffered = bufio.NewReader(gz)
+	}
+
+	magic := make([]byte, 5)
+	if _, err := io.ReadFull(buffered, magic); err != nil {
+		return nil, fmt.Errorf("reading BCF magic: %v", err)
+	}
+	if !bytes.Equal(magic[:4], bcfMagic) {
+		return nil, fmt.Errorf("not a BCF2 file")
+	}
+	var textLength uint32
+	if err := binary.Read(buffered, binary.LittleEndian, &textLength); err != nil {
+		return nil, err
+	}
+	text := make([]byte, textLength)
+	if _, err := io.ReadFull(buffered, text); err != nil {
+		return nil, fmt.Errorf("reading BCF header: %v", err)
+	}
+
+	header := NewHeader()
+	for _, line := range strings.Split(strings.TrimRight(string(text), "\x00"), "\n") {
+		if strings.HasPrefix(line, "#") {
+			parseHeaderLine(header, line)
+		}
+	}
+	if len(header.Strings) == 0 {
+		header.Strings = append(header.Strings, "PASS")
+	}
+	return &BcfReader{Header: header, reader: buffered}, nil
+}
+
+// Returns the next record, or io.EOF when there are no more
+func (bcf *BcfReader) Read() (*VcfLine, error) {
+	var lengths [2]uint32
+	if err := binary.Read(bcf.reader, binary.LittleEndian, &lengths); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("truncated BCF record")
+		}
+		return nil, err
+	}
+	shared := make([]byte, lengths[0])
+	if _, err := io.ReadFull(bcf.reader, shared); err != nil {
+		return nil, fmt.Errorf("truncated BCF record: %v", err)
+	}
+	individual := make([]byte, lengths[1])
+	if _, err := io.ReadFull(bcf.reader, individual); err != nil {
+		return nil, fmt.Errorf("truncated BCF record: %v", err)
+	}
+	return bcf.decodeRecord(shared, individual)
+}
+
+// Walks over a record's bytes, collecting the first error like binaryReader
+type bcfDecoder struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (decoder *bcfDecoder) take(size int) []byte {
+	if decoder.err != nil {
+		return nil
+	}
+	if decoder.pos+size > len(decoder.data) {
+		decoder.err = fmt.Errorf("BCF record is shorter than its fields")
+		return nil
+	}
+	value := decoder.data[decoder.pos : decoder.pos+size]
+	decoder.pos += size
+	return value
+}
+
+func (decoder *bcfDecoder) uint32() uint32 {
+	value := decoder.take(4)
+	if value == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(value)
+}
+
+func (decoder *bcfDecoder) int32() int32 {
+	return int32(decoder.uint32())
+}
+
+// A type descriptor holds the type and the count, with counts of 15 or more following as a typed int
+func (decoder *bcfDecoder) descriptor() (int, int) {
+	value := decoder.take(1)
+	if value == nil {
+		return bcfTypeMissing, 0
+	}
+	valueType := int(value[0] & 0x0f)
+	count := int(value[0] >> 4)
+	if count == 15 {
+		counts := decoder.typedInts()
+		if len(counts) != 1 || counts[0] == nil {
+			decoder.err = fmt.Errorf("invalid BCF vector length")
+			return valueType, 0
+		}
+		count = *counts[0]
+	}
+	return valueType, count
+}
+
+func bcfTypeSize(valueType int) int {
+	switch valueType {
+	case bcfTypeInt8, bcfTypeChar:
+		return 1
+	case bcfTypeInt16:
+		return 2
+	case bcfTypeInt32, bcfTypeFloat:
+		return 4
+	}
+	return 0
+}
+
+// Reads count integers of the type, nil for missing values. The vector stops at the end of vector
+// marker, which pads out shorter values like haploid genotypes
+func (decoder *bcfDecoder) ints(valueType int, count int) []*int {
+	values := make([]*int, 0, count)
+	ended := false
+	for i := 0; i < count; i++ {
+		raw := decoder.take(bcfTypeSize(valueType))
+		if raw == nil {
+			return values
+		}
+		var value, missing, endOfVector int
+		switch valueType {
+		case bcfTypeInt8:
+			value, missing, endOfVector = int(int8(raw[0])), math.MinInt8, math.MinInt8+1
+		case bcfTypeInt16:
+			value, missing, endOfVector = int(int16(binary.LittleEndian.Uint16(raw))), math.MinInt16, math.MinInt16+1
+		default:
+			value, missing, endOfVector = int(int32(binary.LittleEndian.Uint32(raw))), math.MinInt32, math.MinInt32+1
+		}
+		switch {
+		case ended || value == endOfVector:
+			ended = true
+		case value == missing:
+			values = append(values, nil)
+		default:
+			number := value
+			values = append(values, &number)
+		}
+	}
+	return values
+}
+
+func (decoder *bcfDecoder) typedInts() []*int {
+	valueType, count := decoder.descriptor()
+	if valueType == bcfTypeMissing {
+		return nil
+	}
+	return decoder.ints(valueType, count)
+}
+
+// Turns count values of the type into the comma separated text a VCF would have
+func (decoder *bcfDecoder) valueString(valueType int, count int) string {
+	switch valueType {
+	case bcfTypeMissing:
+		return ""
+	case bcfTypeChar:
+		return strings.TrimRight(string(decoder.take(count)), "\x00")
+	case bcfTypeFloat:
+		parts := make([]string, 0, count)
+		ended := false
+		for i := 0; i < count; i++ {
+			raw := decoder.take(4)
+			if raw == nil {
+				break
+			}
+			bits := binary.LittleEndian.Uint32(raw)
+			switch {
+			case ended || bits == bcfFloatEndOfValue:
+				ended = true
+			case bits == bcfFloatMissing:
+				parts = append(parts, ".")
+			default:
+				parts = append(parts, strconv.FormatFloat(float64(math.Float32frombits(bits)), 'g', -1, 32))
+			}
+		}
+		return strings.Join(parts, ",")
+	case bcfTypeInt8, bcfTypeInt16, bcfTypeInt32:
+		values := decoder.ints(valueType, count)
+		parts := make([]string, len(values))
+		for i, value := range values {
+			if value == nil {
+				parts[i] = "."
+			} else {
+				parts[i] = strconv.Itoa(*value)
+			}
+		}
+		return strings.Join(parts, ",")
+	}
+	decoder.err = fmt.Errorf("unknown BCF value type %d", valueType)
+	return ""
+}
+
+func (decoder *bcfDecoder) typedString() string {
+	valueType, count := decoder.descriptor()
+	return decoder.valueString(valueType, count)
+}
+
+// GT alleles are stored as (allele + 1) << 1 with the low bit set when phased with the allele before
+func genotypeString(values []*int) string {
+	var builder strings.Builder
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		if i > 0 {
+			if *value&1 == 1 {
+				builder.WriteString("|")
+			} else {
+				builder.WriteString("/")
+			}
+		}
+		allele := (*value >> 1) - 1
+		if allele < 0 {
+			builder.WriteString(".")
+		} else {
+			builder.WriteString(strconv.Itoa(allele))
+		}
+	}
+	if builder.Len() == 0 {
+		return "."
+	}
+	return builder.String()
+}
+
+func (bcf *BcfReader) dictionaryString(index *int) (string, error) {
+	if index == nil || *index < 0 || *index >= len(bcf.Header.Strings) {
+		return "", fmt.Errorf("BCF key isn't in the header dictionary")
+	}
+	return bcf.Header.Strings[*index], nil
+}
+
+func (bcf *BcfReader) decodeRecord(shared []byte, individual []byte) (*VcfLine, error) {
+	decoder := &bcfDecoder{data: shared}
+	chromIndex := int(decoder.int32())
+	pos := int(decoder.int32()) + 1
+	decoder.int32()
+	qualBits := decoder.uint32()
+	alleleInfo := decoder.uint32()
+	formatSample := decoder.uint32()
+	if decoder.err != nil {
+		return nil, decoder.err
+	}
+	if chromIndex < 0 || chromIndex >= len(bcf.Header.Contigs) {
+		return nil, fmt.Errorf("BCF contig %d isn't in the header", chromIndex)
+	}
+
+	line := &VcfLine{
+		Chrom:      bcf.Header.Contigs[chromIndex],
+		Pos:        pos,
+		Qual:       ".",
+		info:       make(map[string]string),
+		SampleData: make(map[string]string),
+		Samples:    make([]map[string]string, 0),
+	}
+	if qualBits != bcfFloatMissing {
+		line.Qual = strconv.FormatFloat(float64(math.Float32frombits(qualBits)), 'g', -1, 32)
+	}
+	line.ID = decoder.typedString()
+	if line.ID == "" {
+		line.ID = "."
+	}
+
+	alleles := make([]string, 0)
+	for i := 0; i < int(alleleInfo>>16); i++ {
+		alleles = append(alleles, decoder.typedString())
+	}
+	if len(alleles) > 0 {
+		line.Ref = alleles[0]
+	}
+	line.Alt = "."
+	if len(alleles) > 1 {
+		line.Alt = stripNonRef(strings.Join(alleles[1:], ","))
+	}
+
+	filters := make([]string, 0)
+	for _, index := range decoder.typedInts() {
+		filter, err := bcf.dictionaryString(index)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	line.Filter = "."
+	if len(filters) > 0 {
+		line.Filter = strings.Join(filters, ";")
+	}
+
+	for i := 0; i < int(alleleInfo&0xffff); i++ {
+		keys := decoder.typedInts()
+		if len(keys) != 1 {
+			return nil, fmt.Errorf("invalid BCF INFO key")
+		}
+		key, err := bcf.dictionaryString(keys[0])
+		if err != nil {
+			return nil, err
+		}
+		value := decoder.typedString()
+		if definition, ok := bcf.Header.Info[key]; ok && definition.Type == TypeFlag {
+			value = ""
+		}
+		line.info[key] = value
+	}
+	if decoder.err != nil {
+		return nil, decoder.err
+	}
+
+	sampleCount := int(formatSample & 0xffffff)
+	formatCount := int(formatSample >> 24)
+	for i := 0; i < sampleCount; i++ {
+		line.Samples = append(line.Samples, make(map[string]string))
+	}
+	decoder = &bcfDecoder{data: individual}
+	formats := make([]string, 0, formatCount)
+	for i := 0; i < formatCount; i++ {
+		keys := decoder.typedInts()
+		if len(keys) != 1 {
+			return nil, fmt.Errorf("invalid BCF FORMAT key")
+		}
+		key, err := bcf.dictionaryString(keys[0])
+		if err != nil {
+			return nil, err
+		}
+		formats = append(formats, key)
+		valueType, count := decoder.descriptor()
+		for sample := 0; sample < sampleCount; sample++ {
+			if key == "GT" && valueType != bcfTypeFloat && valueType != bcfTypeChar {
+				line.Samples[sample][key] = genotypeString(decoder.ints(valueType, count))
+			} else {
+				line.Samples[sample][key] = decoder.valueString(valueType, count)
+			}
+		}
+	}
+	if decoder.err != nil {
+		return nil, decoder.err
+	}
+	line.Format = strings.Join(formats, ":")
+	if sampleCount > 0 {
+		line.SampleData = line.Samples[0]
+		values := make([]string, len(formats))
+		for i, format := range formats {
+			values[i] = line.SampleData[format]
+		}
+		line.Sample = strings.Join(values, ":")
+	}
+	return line, nil
+}
@@ -0,0 +1,268 @@
+package vcf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Writes BCF2 typed values for building records by hand
+type bcfEncoder struct {
+	bytes.Buffer
+}
+
+func (encoder *bcfEncoder) descriptor(valueType int, count int) {
+	if count < 15 {
+		encoder.WriteByte(byte(count<<4 | valueType))
+		return
+	}
+	encoder.WriteByte(byte(15<<4 | valueType))
+	encoder.WriteByte(1<<4 | bcfTypeInt8)
+	encoder.WriteByte(byte(count))
+}
+
+func (encoder *bcfEncoder) raw(values ...interface{}) {
+	for _, value := range values {
+		binary.Write(encoder, binary.LittleEndian, value)
+	}
+}
+
+func (encoder *bcfEncoder) str(value string) {
+	encoder.descriptor(bcfTypeChar, len(value))
+	encoder.WriteString(value)
+}
+
+func (encoder *bcfEncoder) int8s(values ...int8) {
+	encoder.descriptor(bcfTypeInt8, len(values))
+	encoder.raw(values)
+}
+
+func TestBcfDecoderValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		encode   func(encoder *bcfEncoder)
+		expected string
+	}{
+		{"missing type", func(e *bcfEncoder) { e.descriptor(bcfTypeMissing, 0) }, ""},
+		{"int8", func(e *bcfEncoder) { e.int8s(1, -2, 127) }, "1,-2,127"},
+		{"int8 missing", func(e *bcfEncoder) { e.int8s(5, math.MinInt8) }, "5,."},
+		{"int8 end of vector", func(e *bcfEncoder) { e.int8s(5, math.MinInt8+1, math.MinInt8+1) }, "5"},
+		{"int16", func(e *bcfEncoder) {
+			e.descriptor(bcfTypeInt16, 3)
+			e.raw(int16(300), int16(math.MinInt16), int16(math.MinInt16+1))
+		}, "300,."},
+		{"int32", func(e *bcfEncoder) {
+			e.descriptor(bcfTypeInt32, 2)
+			e.raw(int32(100000), int32(math.MinInt32))
+		}, "100000,."},
+		{"float", func(e *bcfEncoder) {
+			e.descriptor(bcfTypeFloat, 4)
+			e.raw(float32(0.5), uint32(bcfFloatMissing), float32(12.25), uint32(bcfFloatEndOfValue))
+		}, "0.5,.,12.25"},
+		{"char", func(e *bcfEncoder) { e.str("rs123") }, "rs123"},
+		{"char padded", func(e *bcfEncoder) {
+			e.descriptor(bcfTypeChar, 4)
+			e.WriteString("AB\x00\x00")
+		}, "AB"},
+		{"long char", func(e *bcfEncoder) { e.str(strings.Repeat("ACGT", 10)) }, strings.Repeat("ACGT", 10)},
+		{"long ints", func(e *bcfEncoder) {
+			e.int8s(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16)
+		}, "1,2,3,4,5,6,7,8,9,10,11,12,13,14,15,16"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encoder := &bcfEncoder{}
+			test.encode(encoder)
+			decoder := &bcfDecoder{data: encoder.Bytes()}
+			if actual := decoder.typedString(); actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+			if decoder.err != nil {
+				t.Errorf("unexpected error %v", decoder.err)
+			}
+			if decoder.pos != len(decoder.data) {
+				t.Errorf("read %d of %d bytes", decoder.pos, len(decoder.data))
+			}
+		})
+	}
+}
+
+func TestBcfDecoderErrors(t *testing.T) {
+	truncated := &bcfDecoder{data: []byte{3<<4 | bcfTypeInt32, 1, 0}}
+	truncated.typedString()
+	if truncated.err == nil {
+		t.Errorf("expected an error for a truncated value")
+	}
+	unknown := &bcfDecoder{data: []byte{1<<4 | 4, 0}}
+	unknown.typedString()
+	if unknown.err == nil {
+		t.Errorf("expected an error for an unknown type")
+	}
+	badLength := &bcfDecoder{data: []byte{15<<4 | bcfTypeChar, 2<<4 | bcfTypeInt8, 1, 2}}
+	badLength.typedString()
+	if badLength.err == nil {
+		t.Errorf("expected an error for a vector length that isn't one int")
+	}
+}
+
+func TestGenotypeString(t *testing.T) {
+	value := func(values ...int) []*int {
+		pointers := make([]*int, len(values))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		return pointers
+	}
+	tests := []struct {
+		values   []*int
+		expected string
+	}{
+		{value(2, 4), "0/1"},
+		{value(2, 5), "0|1"},
+		{value(4, 4), "1/1"},
+		{value(0, 0), "./."},
+		{value(4), "1"},
+		{value(6, 3), "2|0"},
+		{[]*int{}, "."},
+	}
+	for _, test := range tests {
+		if actual := genotypeString(test.values); actual != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, actual)
+		}
+	}
+}
+
+const bcfTestHeader = "##fileformat=VCFv4.2\n" +
+	"##FILTER=<ID=PASS,Description=\"All filters passed\">\n" +
+	"##FILTER=<ID=q10,Description=\"Quality below 10\">\n" +
+	"##INFO=<ID=DP,Number=1,Type=Integer,Description=\"Depth\">\n" +
+	"##INFO=<ID=DB,Number=0,Type=Flag,Description=\"dbSNP\">\n" +
+	"##FORMAT=<ID=GT,Number=1,Type=String,Description=\"Genotype\">\n" +
+	"##FORMAT=<ID=AD,Number=R,Type=Integer,Description=\"Allele depths\">\n" +
+	"##contig=<ID=1>\n" +
+	"##contig=<ID=X>\n" +
+	"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT\tmother\tson\n"
+
+func bcfTestFile(records ...[2][]byte) []byte {
+	file := &bcfEncoder{}
+	file.WriteString("BCF\x02\x02")
+	text := bcfTestHeader + "\x00"
+	file.raw(uint32(len(text)))
+	file.WriteString(text)
+	for _, record := range records {
+		file.raw(uint32(len(record[0])), uint32(len(record[1])))
+		file.Write(record[0])
+		file.Write(record[1])
+	}
+	return file.Bytes()
+}
+
+func TestBcfReader(t *testing.T) {
+	// Dictionary: PASS 0, q10 1, DP 2, DB 3, GT 4, AD 5
+	shared := &bcfEncoder{}
+	shared.raw(int32(1), int32(99), int32(1), float32(30))
+	shared.raw(uint32(2<<16|2), uint32(2<<24|2))
+	shared.str("rs1")
+	shared.str("C")
+	shared.str("T")
+	shared.int8s(1)
+	shared.int8s(2)
+	shared.int8s(14)
+	shared.int8s(3)
+	shared.descriptor(bcfTypeMissing, 0)
+	individual := &bcfEncoder{}
+	individual.int8s(4)
+	// The son is haploid on X, so his second allele is the end of vector marker
+	individual.descriptor(bcfTypeInt8, 2)
+	individual.raw(int8(2), int8(5), int8(4), int8(math.MinInt8+1))
+	individual.int8s(5)
+	individual.descriptor(bcfTypeInt8, 2)
+	individual.raw(int8(2), int8(3), int8(7), int8(math.MinInt8))
+
+	minimal := &bcfEncoder{}
+	minimal.raw(int32(0), int32(9), int32(1), uint32(bcfFloatMissing), uint32(1<<16), uint32(0))
+	minimal.descriptor(bcfTypeChar, 0)
+	minimal.str("A")
+	minimal.descriptor(bcfTypeMissing, 0)
+
+	reader, err := NewBcfReader(bytes.NewReader(bcfTestFile(
+		[2][]byte{shared.Bytes(), individual.Bytes()},
+		[2][]byte{minimal.Bytes(), nil},
+	)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reader.Header.SampleNames, []string{"mother", "son"}) {
+		t.Errorf("unexpected samples %v", reader.Header.SampleNames)
+	}
+
+	line, err := reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &VcfLine{
+		Chrom:      "X",
+		Pos:        100,
+		ID:         "rs1",
+		Ref:        "C",
+		Alt:        "T",
+		Qual:       "30",
+		Filter:     "q10",
+		info:       map[string]string{"DP": "14", "DB": ""},
+		Format:     "GT:AD",
+		Sample:     "0|1:2,3",
+		SampleData: map[string]string{"GT": "0|1", "AD": "2,3"},
+		Samples: []map[string]string{
+			{"GT": "0|1", "AD": "2,3"},
+			{"GT": "1", "AD": "7,."},
+		},
+	}
+	if !reflect.DeepEqual(line, expected) {
+		t.Errorf("expected %+v, got %+v", expected, line)
+	}
+	if !line.HasInfoFlag("DB") || line.GetInfo("DP") != "14" {
+		t.Errorf("INFO lookups don't work on BCF lines")
+	}
+
+	line, err = reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line.Chrom != "1" || line.Pos != 10 || line.ID != "." || line.Ref != "A" || line.Alt != "." || line.Qual != "." || line.Filter != "." {
+		t.Errorf("unexpected minimal record %+v", line)
+	}
+	if _, err := reader.Read(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestBcfReaderErrors(t *testing.T) {
+	if _, err := NewBcfReader(bytes.NewReader([]byte("##fileformat=VCFv4.2\n"))); err == nil {
+		t.Errorf("expected an error for a text VCF")
+	}
+
+	unknownContig := &bcfEncoder{}
+	unknownContig.raw(int32(5), int32(0), int32(1), uint32(bcfFloatMissing), uint32(0), uint32(0))
+	unknownContig.descriptor(bcfTypeMissing, 0)
+	unknownContig.descriptor(bcfTypeMissing, 0)
+	reader, err := NewBcfReader(bytes.NewReader(bcfTestFile([2][]byte{unknownContig.Bytes(), nil})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Read(); err == nil || err == io.EOF {
+		t.Errorf("expected an error for an unknown contig, got %v", err)
+	}
+
+	file := bcfTestFile([2][]byte{unknownContig.Bytes(), nil})
+	reader, err = NewBcfReader(bytes.NewReader(file[:len(file)-3]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Read(); err == nil || err == io.EOF {
+		t.Errorf("expected an error for a truncated record, got %v", err)
+	}
+}
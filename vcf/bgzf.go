@@ -0,0 +1,60 @@
+package vcf
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// BGZF files are a series of gzip blocks, each with a BC extra field holding the block size.
+// That's what lets an index point straight at a block
+var bgzfMagic = []byte{0x1f, 0x8b, 0x08, 0x04}
+
+// Checks the first block's header for the BGZF extra field
+func IsBgzf(filePath string) (bool, error) {
+	format, err := SniffFile(filePath)
+	return format == FileBgzf, err
+}
+
+// A virtual offset is the compressed offset of a block in the upper 48 bits and the offset within
+// the uncompressed block in the lower 16
+func splitVirtualOffset(offset uint64) (int64, int64) {
+	return int64(offset >> 16), int64(offset & 0xffff)
+}
+
+type bgzfReader struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (reader *bgzfReader) Close() error {
+	reader.Reader.Close()
+	return reader.file.Close()
+}
+
+// Opens a BGZF file and positions the uncompressed stream at the virtual offset. gzip reads the
+// following blocks as further members of the same stream
+func openBgzfAt(filePath string, offset uint64) (io.ReadCloser, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	blockOffset, withinBlock := splitVirtualOffset(offset)
+	if _, err := file.Seek(blockOffset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	gz, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := io.CopyN(ioutil.Discard, gz, withinBlock); err != nil {
+		gz.Close()
+		file.Close()
+		return nil, err
+	}
+	return &bgzfReader{Reader: gz, file: file}, nil
+}
@@ -0,0 +1,112 @@
+package vcf
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	ZygosityNoCall       = "No Call"
+	ZygosityReference    = "Reference"
+	ZygosityHeterozygous = "Heterozygous"
+	ZygosityHomozygous   = "Homozygous"
+	ZygosityHemizygous   = "Hemizygous"
+)
+
+// Parsed GT field, missing alleles are -1
+type Genotype struct {
+	Alleles []int
+	Phased  bool
+}
+
+func ParseGenotype(gt string) Genotype {
+	genotype := Genotype{Alleles: make([]int, 0, 2)}
+	if gt == "" {
+		return genotype
+	}
+	genotype.Phased = strings.Contains(gt, "|")
+	for _, allele := range strings.FieldsFunc(gt, func(r rune) bool { return r == '/' || r == '|' }) {
+		index, err := strconv.Atoi(allele)
+		if err != nil {
+			index = -1
+		}
+		genotype.Alleles = append(genotype.Alleles, index)
+	}
+	return genotype
+}
+
+func (line VcfLine) GetGenotype() Genotype {
+	return ParseGenotype(line.GetSampleData("GT"))
+}
+
+func (genotype Genotype) IsNoCall() bool {
+	for _, allele := range genotype.Alleles {
+		if allele >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Number of called alleles that aren't the reference
+func (genotype Genotype) AltCount() int {
+	count := 0
+	for _, allele := range genotype.Alleles {
+		if allele > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+func (genotype Genotype) HasAlt() bool {
+	return genotype.AltCount() > 0
+}
+
+func (genotype Genotype) IsHaploid() bool {
+	return len(genotype.Alleles) == 1
+}
+
+func (genotype Genotype) IsHomozygousAlt() bool {
+	return len(genotype.Alleles) > 1 && genotype.AltCount() == len(genotype.Alleles) && genotype.allSame()
+}
+
+func (genotype Genotype) IsHeterozygous() bool {
+	return len(genotype.Alleles) > 1 && genotype.HasAlt() && !genotype.allSame()
+}
+
+func (genotype Genotype) allSame() bool {
+	for _, allele := range genotype.Alleles[1:] {
+		if allele != genotype.Alleles[0] {
+			return false
+		}
+	}
+	return true
+}
+
+func (genotype Genotype) Zygosity() string {
+	switch {
+	case genotype.IsNoCall():
+		return ZygosityNoCall
+	case !genotype.HasAlt():
+		return ZygosityReference
+	case genotype.IsHaploid():
+		return ZygosityHemizygous
+	case genotype.IsHomozygousAlt():
+		return ZygosityHomozygous
+	default:
+		return ZygosityHeterozygous
+	}
+}
+
+// Positions in the GT of the non-reference alleles, for phased genotypes these are the haplotypes
+// carrying the variant
+func (genotype Genotype) AltHaplotypes() []int {
+	haplotypes := make([]int, 0)
+	for i, allele := range genotype.Alleles {
+		if allele > 0 {
+			haplotypes = append(haplotypes, i)
+		}
+	}
+	return haplotypes
+}
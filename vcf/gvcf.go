@@ -0,0 +1,60 @@
+package vcf
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	// GATK's placeholder for any allele other than the reference, bcftools and DeepVariant use <*>
+	NonRefAllele    = "<NON_REF>"
+	AnyAllele       = "<*>"
+	MinDepthKey     = "MIN_DP"
+	DepthKey        = "DP"
+	GenotypeQualKey = "GQ"
+)
+
+func isNonRefAllele(allele string) bool {
+	return allele == NonRefAllele || allele == AnyAllele
+}
+
+// gVCF variant records list <NON_REF> after the real alleles, which would stop them ever matching.
+// It's always last, so dropping it leaves the GT indexes of the other alleles alone
+func stripNonRef(alt string) string {
+	alleles := strings.Split(alt, ",")
+	if len(alleles) < 2 || !isNonRefAllele(alleles[len(alleles)-1]) {
+		return alt
+	}
+	return strings.Join(alleles[:len(alleles)-1], ",")
+}
+
+// A gVCF reference block, where the only ALT is <NON_REF> and END says how far it goes
+func (line *VcfLine) IsReferenceBlock() bool {
+	return isNonRefAllele(line.Alt)
+}
+
+// Last position a record covers, the END of a reference block or the end of the REF otherwise
+func (line *VcfLine) BlockEnd() int {
+	if end, err := strconv.Atoi(line.GetInfo(EndKey)); err == nil && end >= line.Pos {
+		return end
+	}
+	if len(line.Ref) == 0 {
+		return line.Pos
+	}
+	return line.Pos + len(line.Ref) - 1
+}
+
+// Read depth of the sample, the smallest depth across a reference block when the block has MIN_DP
+func (line *VcfLine) Depth() (int, bool) {
+	depth := line.GetSampleData(MinDepthKey)
+	if depth == "" {
+		depth = line.GetSampleData(DepthKey)
+	}
+	value, err := strconv.Atoi(depth)
+	return value, err == nil
+}
+
+func (line *VcfLine) GenotypeQuality() (int, bool) {
+	value, err := strconv.Atoi(line.GetSampleData(GenotypeQualKey))
+	return value, err == nil
+}
@@ -0,0 +1,161 @@
+package vcf
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	TypeInteger   = "Integer"
+	TypeFloat     = "Float"
+	TypeFlag      = "Flag"
+	TypeCharacter = "Character"
+	TypeString    = "String"
+)
+
+// An INFO or FORMAT definition from the header, like ##INFO=<ID=DP,Number=1,Type=Integer,...>
+type FieldDefinition struct {
+	ID          string
+	Number      string
+	Type        string
+	Description string
+}
+
+// Splits a structured meta line like ##INFO=<ID=DP,Number=1,Description="a, b"> into its kind and
+// its fields, keeping commas inside quotes
+func parseStructuredMeta(line string) (string, map[string]string, bool) {
+	line = strings.TrimPrefix(line, "##")
+	equals := strings.Index(line, "=<")
+	if equals < 0 || !strings.HasSuffix(line, ">") {
+		return "", nil, false
+	}
+	kind := line[:equals]
+	body := line[equals+2 : len(line)-1]
+
+	fields := make(map[string]string)
+	inQuotes := false
+	start := 0
+	addField := func(part string) {
+		keyValue := strings.SplitN(part, "=", 2)
+		if len(keyValue) == 2 {
+			fields[keyValue[0]] = strings.Trim(keyValue[1], "\"")
+		}
+	}
+	for i, r := range body {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == ',' && !inQuotes:
+			addField(body[start:i])
+			start = i + 1
+		}
+	}
+	addField(body[start:])
+	return kind, fields, true
+}
+
+// Value of a plain meta line like ##fileDate=2020-07-06, blank when there isn't one
+func (header *Header) Meta(key string) string {
+	prefix := "##" + key + "="
+	for _, line := range header.MetaLines {
+		if strings.HasPrefix(line, prefix) {
+			return strings.TrimPrefix(line, prefix)
+		}
+	}
+	return ""
+}
+
+// Keeps the INFO and FORMAT definitions, and the string and contig dictionaries BCF refers to by
+// index. Without IDX fields, PASS is string 0 and the rest are numbered in the order they're defined
+func (header *Header) addMetaLine(line string) {
+	kind, fields, ok := parseStructuredMeta(line)
+	if !ok {
+		return
+	}
+	id := fields["ID"]
+	switch kind {
+	case "INFO", "FORMAT":
+		definition := &FieldDefinition{ID: id, Number: fields["Number"], Type: fields["Type"], Description: fields["Description"]}
+		if kind == "INFO" {
+			header.Info[id] = definition
+		} else {
+			header.Format[id] = definition
+		}
+		header.addString(id, fields["IDX"])
+	case "FILTER":
+		header.addString(id, fields["IDX"])
+	case "contig":
+		if idx, err := strconv.Atoi(fields["IDX"]); err == nil {
+			header.Contigs = setDictionary(header.Contigs, idx, id)
+		} else {
+			header.Contigs = append(header.Contigs, id)
+		}
+	}
+}
+
+func (header *Header) addString(id string, idx string) {
+	if position, err := strconv.Atoi(idx); err == nil {
+		header.Strings = setDictionary(header.Strings, position, id)
+		return
+	}
+	if len(header.Strings) == 0 && id != "PASS" {
+		header.Strings = append(header.Strings, "PASS")
+	}
+	for _, existing := range header.Strings {
+		if existing == id {
+			return
+		}
+	}
+	header.Strings = append(header.Strings, id)
+}
+
+func setDictionary(dictionary []string, index int, value string) []string {
+	for len(dictionary) <= index {
+		dictionary = append(dictionary, "")
+	}
+	dictionary[index] = value
+	return dictionary
+}
+
+// Parses a comma separated INFO value into integers, skipping missing values
+func (vcfLine VcfLine) GetInfoInts(key string) []int {
+	return parseInts(vcfLine.GetInfo(key))
+}
+
+func (vcfLine VcfLine) GetInfoFloats(key string) []float64 {
+	return parseFloats(vcfLine.GetInfo(key))
+}
+
+// Flags have no value, so they're only checked for being present
+func (vcfLine VcfLine) HasInfoFlag(key string) bool {
+	_, ok := vcfLine.lookupInfo(key)
+	return ok
+}
+
+func (vcfLine VcfLine) GetSampleInts(key string) []int {
+	return parseInts(vcfLine.GetSampleData(key))
+}
+
+func (vcfLine VcfLine) GetSampleFloats(key string) []float64 {
+	return parseFloats(vcfLine.GetSampleData(key))
+}
+
+func parseInts(value string) []int {
+	ints := make([]int, 0)
+	for _, part := range strings.Split(value, ",") {
+		if number, err := strconv.Atoi(part); err == nil {
+			ints = append(ints, number)
+		}
+	}
+	return ints
+}
+
+func parseFloats(value string) []float64 {
+	floats := make([]float64, 0)
+	for _, part := range strings.Split(value, ",") {
+		if number, err := strconv.ParseFloat(part, 64); err == nil {
+			floats = append(floats, number)
+		}
+	}
+	return floats
+}
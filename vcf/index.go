@@ -0,0 +1,394 @@
+package vcf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	TabixIndexSuffix = ".tbi"
+	CsiIndexSuffix   = ".csi"
+	// Tabix indexes always use 16kb bins over 5 levels, CSI says what it uses
+	tabixMinShift = 14
+	tabixDepth    = 5
+)
+
+// A 1-based closed range on a chromosome, like VCF positions
+type Region struct {
+	Chrom string
+	Start int
+	End   int
+}
+
+type indexChunk struct {
+	begin uint64
+	end   uint64
+}
+
+type referenceIndex struct {
+	bins map[uint32][]indexChunk
+	// CSI keeps the first offset of each bin instead of the tabix linear index
+	binOffsets map[uint32]uint64
+	linear     []uint64
+}
+
+// A tabix or CSI index of a BGZF compressed VCF
+type Index struct {
+	Names    []string
+	ids      map[string]int
+	minShift int
+	depth    int
+	csi      bool
+	refs     []*referenceIndex
+}
+
+// Looks for a .tbi, then a .csi, next to the VCF
+func FindIndex(vcfPath string) (string, bool) {
+	for _, suffix := range []string{TabixIndexSuffix, CsiIndexSuffix} {
+		if _, err := os.Stat(vcfPath + suffix); err == nil {
+			return vcfPath + suffix, true
+		}
+	}
+	return "", false
+}
+
+// Collects the first read error so the index parsing doesn't need a check after every field
+type binaryReader struct {
+	reader io.Reader
+	err    error
+}
+
+func (reader *binaryReader) read(value interface{}) {
+	if reader.err == nil {
+		reader.err = binary.Read(reader.reader, binary.LittleEndian, value)
+	}
+}
+
+func (reader *binaryReader) int32() int32 {
+	var value int32
+	reader.read(&value)
+	return value
+}
+
+func (reader *binaryReader) uint32() uint32 {
+	var value uint32
+	reader.read(&value)
+	return value
+}
+
+func (reader *binaryReader) uint64() uint64 {
+	var value uint64
+	reader.read(&value)
+	return value
+}
+
+func (reader *binaryReader) bytes(length int32) []byte {
+	if length < 0 {
+		reader.err = fmt.Errorf("invalid index length %d", length)
+		return nil
+	}
+	value := make([]byte, length)
+	if reader.err == nil {
+		_, reader.err = io.ReadFull(reader.reader, value)
+	}
+	return value
+}
+
+// Reads a .tbi or .csi index, which are both BGZF compressed
+func ReadIndex(indexPath string) (*Index, error) {
+	file, err := os.Open(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	in := &binaryReader{reader: bufio.NewReader(gz)}
+	magic := in.bytes(4)
+	index := &Index{ids: make(map[string]int)}
+	switch {
+	case bytes.Equal(magic, []byte("TBI\x01")):
+		index.minShift, index.depth = tabixMinShift, tabixDepth
+		refCount := in.int32()
+		index.readTabixHeader(in)
+		index.refs = make([]*referenceIndex, 0, refCount)
+		for i := int32(0); i < refCount && in.err == nil; i++ {
+			index.refs = append(index.refs, readReferenceIndex(in, false))
+		}
+	case bytes.Equal(magic, []byte("CSI\x01")):
+		index.csi = true
+		index.minShift = int(in.int32())
+		index.depth = int(in.int32())
+		aux := in.bytes(in.int32())
+		if len(aux) >= 28 {
+			index.readTabixHeader(&binaryReader{reader: bytes.NewReader(aux)})
+		}
+		refCount := in.int32()
+		index.refs = make([]*referenceIndex, 0, refCount)
+		for i := int32(0); i < refCount && in.err == nil; i++ {
+			index.refs = append(index.refs, readReferenceIndex(in, true))
+		}
+	default:
+		if in.err == nil {
+			return nil, fmt.Errorf("%s isn't a tabix or CSI index", indexPath)
+		}
+	}
+	if in.err != nil {
+		return nil, fmt.Errorf("reading index %s: %v", indexPath, in.err)
+	}
+	return index, nil
+}
+
+// The tabix header holds the column layout, which is always VCF here, and the sequence names
+func (index *Index) readTabixHeader(in *binaryReader) {
+	for i := 0; i < 6; i++ {
+		in.int32()
+	}
+	names := in.bytes(in.int32())
+	for _, name := range strings.Split(strings.TrimRight(string(names), "\x00"), "\x00") {
+		index.ids[NormalizeChrom(name)] = len(index.Names)
+		index.Names = append(index.Names, name)
+	}
+}
+
+func readReferenceIndex(in *binaryReader, csi bool) *referenceIndex {
+	ref := &referenceIndex{
+		bins:       make(map[uint32][]indexChunk),
+		binOffsets: make(map[uint32]uint64),
+	}
+	binCount := in.int32()
+	for i := int32(0); i < binCount && in.err == nil; i++ {
+		bin := in.uint32()
+		if csi {
+			ref.binOffsets[bin] = in.uint64()
+		}
+		chunkCount := in.int32()
+		chunks := make([]indexChunk, 0, chunkCount)
+		for j := int32(0); j < chunkCount && in.err == nil; j++ {
+			chunks = append(chunks, indexChunk{begin: in.uint64(), end: in.uint64()})
+		}
+		ref.bins[bin] = chunks
+	}
+	if !csi {
+		linearCount := in.int32()
+		for i := int32(0); i < linearCount && in.err == nil; i++ {
+			ref.linear = append(ref.linear, in.uint64())
+		}
+	}
+	return ref
+}
+
+// Number of bins before the given level of the binning scheme
+func binLevelOffset(level int) uint32 {
+	return uint32(((1 << uint(3*level)) - 1) / 7)
+}
+
+// The pseudo bin after the last real one holds counts of mapped and unmapped records, not chunks
+func (index *Index) metaBin() uint32 {
+	return binLevelOffset(index.depth+1) + 1
+}
+
+// Bins overlapping the 0-based half open range, from the hts spec's reg2bins
+func (index *Index) regionBins(begin int, end int) []uint32 {
+	bins := make([]uint32, 0)
+	end--
+	shift := uint(index.minShift + index.depth*3)
+	for level := 0; level <= index.depth; level++ {
+		offset := binLevelOffset(level)
+		for bin := offset + uint32(begin>>shift); bin <= offset+uint32(end>>shift); bin++ {
+			bins = append(bins, bin)
+		}
+		shift -= 3
+	}
+	return bins
+}
+
+// Virtual offset to start reading from for the region, false when nothing can overlap it
+func (index *Index) startOffset(chrom string, start int, end int) (uint64, bool) {
+	id, ok := index.ids[NormalizeChrom(chrom)]
+	if !ok || id >= len(index.refs) {
+		return 0, false
+	}
+	ref := index.refs[id]
+	begin := start - 1
+	if begin < 0 {
+		begin = 0
+	}
+
+	// Nothing that ends before this offset can overlap the region
+	minOffset := uint64(0)
+	if index.csi {
+		bin := binLevelOffset(index.depth) + uint32(begin>>uint(index.minShift))
+		for {
+			if offset, ok := ref.binOffsets[bin]; ok {
+				minOffset = offset
+				break
+			}
+			if bin == 0 {
+				break
+			}
+			bin = (bin - 1) >> 3
+		}
+	} else if len(ref.linear) > 0 {
+		window := begin >> tabixMinShift
+		if window >= len(ref.linear) {
+			window = len(ref.linear) - 1
+		}
+		minOffset = ref.linear[window]
+	}
+
+	found := false
+	best := uint64(0)
+	for _, bin := range index.regionBins(begin, end) {
+		if bin == index.metaBin() {
+			continue
+		}
+		for _, chunk := range ref.bins[bin] {
+			if chunk.end <= minOffset {
+				continue
+			}
+			offset := chunk.begin
+			if offset < minOffset {
+				offset = minOffset
+			}
+			if !found || offset < best {
+				best = offset
+				found = true
+			}
+		}
+	}
+	return best, found
+}
+
+// A BGZF compressed VCF with its index, for reading just the records in some regions
+type IndexedReader struct {
+	Path  string
+	Index *Index
+}
+
+func OpenIndexed(vcfPath string) (*IndexedReader, error) {
+	if strings.HasSuffix(vcfPath, ".bcf") {
+		return nil, fmt.Errorf("indexed reading only works for bgzipped VCFs, not BCF")
+	}
+	indexPath, ok := FindIndex(vcfPath)
+	if !ok {
+		return nil, fmt.Errorf("no %s or %s index found for %s", TabixIndexSuffix, CsiIndexSuffix, vcfPath)
+	}
+	bgzf, err := IsBgzf(vcfPath)
+	if err != nil {
+		return nil, err
+	}
+	if !bgzf {
+		return nil, fmt.Errorf("%s needs to be compressed with bgzip to use its index", vcfPath)
+	}
+	index, err := ReadIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	return &IndexedReader{Path: vcfPath, Index: index}, nil
+}
+
+// Reads the header lines at the start of the file
+func (reader *IndexedReader) ReadHeader() (*Header, error) {
+	header := NewHeader()
+	stream, err := openBgzfAt(reader.Path, 0)
+	if err != nil {
+		return header, err
+	}
+	defer stream.Close()
+	scanner := newLineScanner(stream)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		parseHeaderLine(header, line)
+	}
+	return header, scanner.Err()
+}
+
+// Returns the records overlapping the region. Records are sorted, so reading stops at the first one
+// past the end of the region
+func (reader *IndexedReader) Query(region Region) ([]*VcfLine, error) {
+	lines := make([]*VcfLine, 0)
+	offset, ok := reader.Index.startOffset(region.Chrom, region.Start, region.End)
+	if !ok {
+		return lines, nil
+	}
+	stream, err := openBgzfAt(reader.Path, offset)
+	if err != nil {
+		return lines, err
+	}
+	defer stream.Close()
+
+	chrom := NormalizeChrom(region.Chrom)
+	scanner := newLineScanner(stream)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		line, err := parseVcfLine(text)
+		if err != nil {
+			return lines, err
+		}
+		if line == nil {
+			continue
+		}
+		if NormalizeChrom(line.Chrom) != chrom || line.Pos > region.End {
+			break
+		}
+		if line.BlockEnd() >= region.Start {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// Queries each region after merging the ones that overlap, so no record comes back twice
+func (reader *IndexedReader) QueryRegions(regions []Region) ([]*VcfLine, error) {
+	lines := make([]*VcfLine, 0)
+	for _, region := range MergeRegions(regions, 0) {
+		regionLines, err := reader.Query(region)
+		if err != nil {
+			return lines, err
+		}
+		lines = append(lines, regionLines...)
+	}
+	return lines, nil
+}
+
+// Sorts the regions and joins the ones that overlap or are less than gap bases apart
+func MergeRegions(regions []Region, gap int) []Region {
+	sorted := make([]Region, len(regions))
+	copy(sorted, regions)
+	sort.Slice(sorted, func(i, j int) bool {
+		if NormalizeChrom(sorted[i].Chrom) != NormalizeChrom(sorted[j].Chrom) {
+			return ChromLess(sorted[i].Chrom, sorted[j].Chrom)
+		}
+		return sorted[i].Start < sorted[j].Start
+	})
+	merged := make([]Region, 0, len(sorted))
+	for _, region := range sorted {
+		last := len(merged) - 1
+		if last >= 0 && NormalizeChrom(merged[last].Chrom) == NormalizeChrom(region.Chrom) && region.Start <= merged[last].End+gap+1 {
+			if region.End > merged[last].End {
+				merged[last].End = region.End
+			}
+			continue
+		}
+		merged = append(merged, region)
+	}
+	return merged
+}
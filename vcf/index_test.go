@@ -0,0 +1,283 @@
+package vcf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRegionBins(t *testing.T) {
+	index := &Index{minShift: tabixMinShift, depth: tabixDepth}
+	tests := []struct {
+		begin    int
+		end      int
+		expected []uint32
+	}{
+		{0, 1, []uint32{0, 1, 9, 73, 585, 4681}},
+		{16383, 16385, []uint32{0, 1, 9, 73, 585, 4681, 4682}},
+		{1 << 26, 1<<26 + 1, []uint32{0, 2, 17, 137, 1097, 8777}},
+		{1<<17 - 1, 1<<17 + 1, []uint32{0, 1, 9, 73, 585, 586, 4681 + 7, 4681 + 8}},
+	}
+	for _, test := range tests {
+		if actual := index.regionBins(test.begin, test.end); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("[%d, %d): expected %v, got %v", test.begin, test.end, test.expected, actual)
+		}
+	}
+	if index.metaBin() != 37450 {
+		t.Errorf("expected the tabix meta bin to be 37450, got %d", index.metaBin())
+	}
+}
+
+func TestStartOffset(t *testing.T) {
+	ref := &referenceIndex{
+		bins: map[uint32][]indexChunk{
+			4681:  {{begin: 100, end: 200}},
+			4682:  {{begin: 50, end: 150}, {begin: 300, end: 400}},
+			585:   {{begin: 500, end: 600}},
+			37450: {{begin: 1, end: 2}},
+		},
+		// Records starting in the second window all begin at or after 250
+		linear: []uint64{100, 250, 500},
+	}
+	index := &Index{ids: map[string]int{"1": 0}, minShift: tabixMinShift, depth: tabixDepth, refs: []*referenceIndex{ref}}
+	tests := []struct {
+		chrom    string
+		start    int
+		end      int
+		expected uint64
+		ok       bool
+	}{
+		{"1", 1, 100, 100, true},
+		{"chr1", 1, 100, 100, true},
+		{"1", 16385, 16400, 300, true},
+		{"1", 1 << 20, 1<<20 + 10, 0, false},
+		{"2", 1, 100, 0, false},
+	}
+	for _, test := range tests {
+		offset, ok := index.startOffset(test.chrom, test.start, test.end)
+		if offset != test.expected || ok != test.ok {
+			t.Errorf("%s:%d-%d: expected %d %v, got %d %v", test.chrom, test.start, test.end, test.expected, test.ok, offset, ok)
+		}
+	}
+}
+
+func TestMergeRegions(t *testing.T) {
+	regions := []Region{{"2", 10, 20}, {"1", 50, 60}, {"chr1", 10, 20}, {"1", 15, 30}, {"1", 35, 40}, {"X", 1, 2}}
+	expected := []Region{{"chr1", 10, 30}, {"1", 35, 40}, {"1", 50, 60}, {"2", 10, 20}, {"X", 1, 2}}
+	if actual := MergeRegions(regions, 0); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+	expected = []Region{{"chr1", 10, 40}, {"1", 50, 60}, {"2", 10, 20}, {"X", 1, 2}}
+	if actual := MergeRegions(regions, 4); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("with a gap, expected %v, got %v", expected, actual)
+	}
+}
+
+// A BGZF block holding data, with the BC extra field giving the block size
+func bgzfBlock(t *testing.T, data string) []byte {
+	var buffer bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buffer, gzip.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Header.Extra = []byte{'B', 'C', 2, 0, 0, 0}
+	writer.Write([]byte(data))
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	block := buffer.Bytes()
+	binary.LittleEndian.PutUint16(block[16:], uint16(len(block)-1))
+	return block
+}
+
+func virtualOffset(block int, within int) uint64 {
+	return uint64(block)<<16 | uint64(within)
+}
+
+type testBin struct {
+	ref    int
+	bin    uint32
+	chunks []indexChunk
+}
+
+func writeInt32s(buffer *bytes.Buffer, values ...int32) {
+	for _, value := range values {
+		binary.Write(buffer, binary.LittleEndian, value)
+	}
+}
+
+func tabixHeader(names []string) []byte {
+	var buffer bytes.Buffer
+	joined := strings.Join(names, "\x00") + "\x00"
+	writeInt32s(&buffer, 2, 1, 2, 0, '#', 0, int32(len(joined)))
+	buffer.WriteString(joined)
+	return buffer.Bytes()
+}
+
+func writeGzip(t *testing.T, path string, data []byte) {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	writer.Write(data)
+	writer.Close()
+	if err := ioutil.WriteFile(path, buffer.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTabix(t *testing.T, path string, names []string, bins []testBin, linear [][]uint64) {
+	var buffer bytes.Buffer
+	buffer.WriteString("TBI\x01")
+	writeInt32s(&buffer, int32(len(names)))
+	buffer.Write(tabixHeader(names))
+	for ref := range names {
+		refBins := make([]testBin, 0)
+		for _, bin := range bins {
+			if bin.ref == ref {
+				refBins = append(refBins, bin)
+			}
+		}
+		writeInt32s(&buffer, int32(len(refBins)))
+		for _, bin := range refBins {
+			binary.Write(&buffer, binary.LittleEndian, bin.bin)
+			writeInt32s(&buffer, int32(len(bin.chunks)))
+			for _, chunk := range bin.chunks {
+				binary.Write(&buffer, binary.LittleEndian, chunk.begin)
+				binary.Write(&buffer, binary.LittleEndian, chunk.end)
+			}
+		}
+		writeInt32s(&buffer, int32(len(linear[ref])))
+		for _, offset := range linear[ref] {
+			binary.Write(&buffer, binary.LittleEndian, offset)
+		}
+	}
+	writeGzip(t, path, buffer.Bytes())
+}
+
+func writeCsi(t *testing.T, path string, names []string, bins []testBin) {
+	var buffer bytes.Buffer
+	buffer.WriteString("CSI\x01")
+	aux := tabixHeader(names)
+	writeInt32s(&buffer, tabixMinShift, tabixDepth, int32(len(aux)))
+	buffer.Write(aux)
+	writeInt32s(&buffer, int32(len(names)))
+	for ref := range names {
+		refBins := make([]testBin, 0)
+		for _, bin := range bins {
+			if bin.ref == ref {
+				refBins = append(refBins, bin)
+			}
+		}
+		writeInt32s(&buffer, int32(len(refBins)))
+		for _, bin := range refBins {
+			binary.Write(&buffer, binary.LittleEndian, bin.bin)
+			binary.Write(&buffer, binary.LittleEndian, bin.chunks[0].begin)
+			writeInt32s(&buffer, int32(len(bin.chunks)))
+			for _, chunk := range bin.chunks {
+				binary.Write(&buffer, binary.LittleEndian, chunk.begin)
+				binary.Write(&buffer, binary.LittleEndian, chunk.end)
+			}
+		}
+	}
+	writeGzip(t, path, buffer.Bytes())
+}
+
+func TestIndexedReaderQuery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	header := "##fileformat=VCFv4.2\n#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\n"
+	record := func(chrom string, pos string) string {
+		return chrom + "\t" + pos + "\t.\tA\tG\t.\t.\t.\n"
+	}
+	first := header + record("1", "100") + record("1", "200")
+	second := record("1", "20000") + record("1", "40000") + record("2", "50")
+	firstBlock := bgzfBlock(t, first)
+	secondBlock := bgzfBlock(t, second)
+	data := append(append(append([]byte{}, firstBlock...), secondBlock...), bgzfBlock(t, "")...)
+	vcfPath := filepath.Join(dir, "clinvar.vcf.gz")
+	if err := ioutil.WriteFile(vcfPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b1 := len(firstBlock)
+	at20000 := virtualOffset(b1, 0)
+	at40000 := virtualOffset(b1, len(record("1", "20000")))
+	at50 := virtualOffset(b1, len(record("1", "20000"))+len(record("1", "40000")))
+	end := virtualOffset(b1, len(second))
+	names := []string{"1", "2"}
+	bins := []testBin{
+		{0, 4681, []indexChunk{{virtualOffset(0, len(header)), at20000}}},
+		{0, 4682, []indexChunk{{at20000, at40000}}},
+		{0, 4683, []indexChunk{{at40000, at50}}},
+		{1, 4681, []indexChunk{{at50, end}}},
+	}
+	linear := [][]uint64{{virtualOffset(0, len(header)), at20000, at40000}, {at50}}
+
+	tests := []struct {
+		region   Region
+		expected []int
+	}{
+		{Region{"1", 150, 250}, []int{200}},
+		{Region{"chr1", 19000, 21000}, []int{20000}},
+		{Region{"1", 40000, 40000}, []int{40000}},
+		{Region{"1", 1, 1000000}, []int{100, 200, 20000, 40000}},
+		{Region{"1", 201, 19999}, []int{}},
+		{Region{"2", 1, 100}, []int{50}},
+		{Region{"3", 1, 100}, []int{}},
+	}
+	for _, kind := range []string{TabixIndexSuffix, CsiIndexSuffix} {
+		indexPath := vcfPath + kind
+		if kind == TabixIndexSuffix {
+			writeTabix(t, indexPath, names, bins, linear)
+		} else {
+			writeCsi(t, indexPath, names, bins)
+		}
+		reader, err := OpenIndexed(vcfPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(reader.Index.Names, names) {
+			t.Errorf("%s: expected names %v, got %v", kind, names, reader.Index.Names)
+		}
+		for _, test := range tests {
+			lines, err := reader.Query(test.region)
+			if err != nil {
+				t.Fatal(err)
+			}
+			positions := make([]int, 0)
+			for _, line := range lines {
+				positions = append(positions, line.Pos)
+			}
+			if !reflect.DeepEqual(positions, test.expected) {
+				t.Errorf("%s %v: expected %v, got %v", kind, test.region, test.expected, positions)
+			}
+		}
+		os.Remove(indexPath)
+	}
+}
+
+func TestReadIndexRejectsOtherFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "not.tbi")
+	writeGzip(t, path, []byte("BAI\x01"))
+	if _, err := ReadIndex(path); err == nil {
+		t.Errorf("expected an error for a BAI index")
+	}
+	writeGzip(t, path, []byte("TBI\x01\x02\x00"))
+	if _, err := ReadIndex(path); err == nil {
+		t.Errorf("expected an error for a truncated index")
+	}
+}
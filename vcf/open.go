@@ -0,0 +1,185 @@
+package vcf
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/kazmiekr/clinvar-matcher/progress"
+)
+
+// How a file is stored, worked out from its first bytes rather than its name
+const (
+	FilePlain = "plain"
+	FileGzip  = "gzip"
+	FileBgzf  = "bgzf"
+	FileZip   = "zip"
+	FileBcf   = "bcf"
+)
+
+// Path that reads from standard input, or writes to standard output for reports
+const StdioPath = "-"
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+)
+
+// Closes every closer, in order, returning the first error
+type multiCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (closer *multiCloser) Close() error {
+	var firstErr error
+	for _, c := range closer.closers {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Works out the format from the start of a file. BGZF is gzip with a BC extra field, and only an
+// uncompressed BCF is recognized here since a compressed one looks like any other BGZF file
+func SniffFormat(start []byte) string {
+	switch {
+	case bytes.HasPrefix(start, bgzfMagic) && len(start) >= 14 && start[12] == 'B' && start[13] == 'C':
+		return FileBgzf
+	case bytes.HasPrefix(start, gzipMagic):
+		return FileGzip
+	case bytes.HasPrefix(start, zipMagic):
+		return FileZip
+	case bytes.HasPrefix(start, bcfMagic):
+		return FileBcf
+	}
+	return FilePlain
+}
+
+// Sniffs the format of a file on disk
+func SniffFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	start := make([]byte, 18)
+	count, err := io.ReadFull(file, start)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return SniffFormat(start[:count]), nil
+}
+
+// Opens a file for reading, or standard input for -, decompressing gzip and BGZF and opening the
+// single file in a zip. The format comes from the file's first bytes, so the name doesn't matter
+func Open(filePath string) (io.ReadCloser, error) {
+	return OpenTracked(filePath, nil)
+}
+
+// Like Open, counting the bytes read from the file for the tracker before they're decompressed, so
+// they can be compared with its size. Zips count what's read from the file inside, which has no size
+func OpenTracked(filePath string, tracker *progress.Tracker) (io.ReadCloser, error) {
+	if filePath == StdioPath {
+		return Decompress(trackedFile{tracker.Reader(os.Stdin, -1), os.Stdin})
+	}
+	format, err := SniffFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if format == FileZip {
+		zipFile, err := zip.OpenReader(filePath)
+		if err != nil {
+			return nil, err
+		}
+		opened, err := openSingleZipFile(&zipFile.Reader, zipFile, filePath)
+		if err != nil {
+			return nil, err
+		}
+		return trackedFile{tracker.Reader(opened, -1), opened}, nil
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	size := int64(-1)
+	if info, err := file.Stat(); err == nil {
+		size = info.Size()
+	}
+	return Decompress(trackedFile{tracker.Reader(file, size), file})
+}
+
+// Reads through the tracker's counter, but closes the file
+type trackedFile struct {
+	io.Reader
+	io.Closer
+}
+
+// Decompresses a stream the same way as Open, for input that isn't a file like an upload. Zips need
+// random access, so they're read into memory first. Closing it closes the input
+func Decompress(input io.ReadCloser) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(input)
+	start, _ := buffered.Peek(18)
+
+	switch SniffFormat(start) {
+	case FileGzip, FileBgzf:
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			input.Close()
+			return nil, err
+		}
+		return &multiCloser{Reader: gz, closers: []io.Closer{gz, input}}, nil
+	case FileZip:
+		data, err := ioutil.ReadAll(buffered)
+		input.Close()
+		if err != nil {
+			return nil, err
+		}
+		zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		return openSingleZipFile(zipReader, nil, "the zip")
+	}
+	return &multiCloser{Reader: buffered, closers: []io.Closer{input}}, nil
+}
+
+func openSingleZipFile(zipReader *zip.Reader, closer io.Closer, name string) (io.ReadCloser, error) {
+	closers := make([]io.Closer, 0)
+	if closer != nil {
+		closers = append(closers, closer)
+	}
+	if len(zipReader.File) != 1 {
+		for _, c := range closers {
+			c.Close()
+		}
+		return nil, fmt.Errorf("expected a single file in %s, found %d", name, len(zipReader.File))
+	}
+	if !isZippedInput(zipReader.File[0].Name) {
+		for _, c := range closers {
+			c.Close()
+		}
+		return nil, fmt.Errorf("unable to locate vcf in %s, found %s", name, zipReader.File[0].Name)
+	}
+	file, err := zipReader.File[0].Open()
+	if err != nil {
+		for _, c := range closers {
+			c.Close()
+		}
+		return nil, err
+	}
+	return &multiCloser{Reader: file, closers: append([]io.Closer{file}, closers...)}, nil
+}
+
+// Zips hold a VCF, or the .txt of a raw genotype download
+func isZippedInput(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasSuffix(name, ".vcf") || strings.HasSuffix(name, ".txt")
+}
@@ -0,0 +1,53 @@
+package vcf
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func zipOf(t *testing.T, names ...string) []byte {
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for _, name := range names {
+		file, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Write([]byte("##fileformat=VCFv4.2\n"))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestDecompressZip(t *testing.T) {
+	tests := []struct {
+		names []string
+		ok    bool
+	}{
+		{[]string{"sample.vcf"}, true},
+		{[]string{"genome_John_Doe_v5_Full.txt"}, true},
+		{[]string{"AncestryDNA.TXT"}, true},
+		{[]string{"sample.bam"}, false},
+		{[]string{"sample.vcf.gz"}, false},
+		{[]string{"a.vcf", "b.vcf"}, false},
+	}
+	for _, test := range tests {
+		reader, err := Decompress(ioutil.NopCloser(bytes.NewReader(zipOf(t, test.names...))))
+		if (err == nil) != test.ok {
+			t.Errorf("%v: expected ok %v, got error %v", test.names, test.ok, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		data, err := ioutil.ReadAll(reader)
+		reader.Close()
+		if err != nil || string(data) != "##fileformat=VCFv4.2\n" {
+			t.Errorf("%v: read %q, %v", test.names, data, err)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package vcf
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"runtime"
+	"strings"
+
+	"github.com/kazmiekr/clinvar-matcher/progress"
+)
+
+// Data lines handed to a parsing goroutine at a time
+const parseBatchLines = 4096
+
+// Lines read from the file and parsed on one of the workers, done is closed once parsed is set
+type parseBatch struct {
+	lines  []string
+	parsed []*VcfLine
+	err    error
+	done   chan struct{}
+}
+
+// Like ReadVcfFrom, but one goroutine reads lines while the workers parse them, keeping the file's
+// order. 0 workers uses every CPU, and 1 parses as it reads like ReadVcfFrom. BCF is always read on
+// one goroutine
+func ReadVcfFromParallel(input io.Reader, workers int) (*Header, []*VcfLine, error) {
+	return ReadVcfFromTracked(input, workers, nil)
+}
+
+// Like ReadVcfFromParallel, counting the records for the tracker and stopping once its context is
+// cancelled. The bytes are counted by whoever opened the input, see OpenTracked
+func ReadVcfFromTracked(input io.Reader, workers int, tracker *progress.Tracker) (*Header, []*VcfLine, error) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers == 1 {
+		return readVcfFrom(input, tracker)
+	}
+	header := NewHeader()
+	lines := make([]*VcfLine, 0)
+	buffered := bufio.NewReader(input)
+	if start, err := buffered.Peek(len(bcfMagic)); err == nil && bytes.Equal(start, bcfMagic) {
+		return readBcfLines(buffered, tracker)
+	}
+	start, err := buffered.Peek(1)
+	if err != nil {
+		return header, lines, errNotVcf
+	}
+	headerless := start[0] != '#'
+
+	// The ordered queue's buffer keeps the reader from getting too far ahead of the slowest batch
+	work := make(chan *parseBatch, workers)
+	ordered := make(chan *parseBatch, workers*2)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for batch := range work {
+				batch.parsed, batch.err = parseVcfLines(batch.lines)
+				close(batch.done)
+			}
+		}()
+	}
+
+	// The header has to be read before anyone uses it, so only data lines go to the workers
+	scanner := newLineScanner(buffered)
+	var first string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "#") {
+			first = line
+			break
+		}
+		parseHeaderLine(header, line)
+	}
+	if headerless {
+		if err := checkHeaderless(first); err != nil {
+			return header, lines, err
+		}
+	}
+
+	var scanErr error
+	go func() {
+		defer close(work)
+		defer close(ordered)
+		batch := &parseBatch{lines: make([]string, 0, parseBatchLines), done: make(chan struct{})}
+		if first != "" {
+			batch.lines = append(batch.lines, first)
+		}
+		for scanner.Scan() {
+			batch.lines = append(batch.lines, scanner.Text())
+			if len(batch.lines) == parseBatchLines {
+				if tracker.Err() != nil {
+					break
+				}
+				ordered <- batch
+				work <- batch
+				batch = &parseBatch{lines: make([]string, 0, parseBatchLines), done: make(chan struct{})}
+			}
+		}
+		scanErr = scanner.Err()
+		ordered <- batch
+		work <- batch
+	}()
+
+	var parseErr error
+	for batch := range ordered {
+		<-batch.done
+		// Keep draining after an error so the reader and workers can finish
+		if parseErr != nil {
+			continue
+		}
+		if batch.err != nil {
+			parseErr = batch.err
+			continue
+		}
+		lines = append(lines, batch.parsed...)
+		parseErr = tracker.Records(len(batch.lines))
+	}
+	if parseErr != nil {
+		return header, lines, parseErr
+	}
+	if scanErr != nil {
+		return header, lines, scanErr
+	}
+	return header, lines, tracker.Done()
+}
+
+func parseVcfLines(text []string) ([]*VcfLine, error) {
+	lines := make([]*VcfLine, 0, len(text))
+	for _, line := range text {
+		vcfLine, err := parseVcfLine(line)
+		if err != nil {
+			return lines, err
+		}
+		if vcfLine != nil {
+			lines = append(lines, vcfLine)
+		}
+	}
+	return lines, nil
+}
@@ -0,0 +1,92 @@
+package vcf
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	SVTypeKey   = "SVTYPE"
+	SVLenKey    = "SVLEN"
+	EndKey      = "END"
+	CIPosKey    = "CIPOS"
+	SVTypeDel   = "DEL"
+	SVTypeDup   = "DUP"
+	SVTypeCNV   = "CNV"
+	SVTypeInv   = "INV"
+	SVTypeIns   = "INS"
+	SVTypeBnd   = "BND"
+	symbolicAlt = "<"
+)
+
+// A structural variant's type and the reference bases it covers, Start and End are both included.
+// CIPos is the confidence interval around Start, like -10,10
+type StructuralVariant struct {
+	Type   string
+	Start  int
+	End    int
+	Length int
+	CIPos  [2]int
+}
+
+func (sv *StructuralVariant) Span() int {
+	return sv.End - sv.Start + 1
+}
+
+// Checks for a symbolic ALT like <DEL> or an SVTYPE in the INFO, gVCF reference blocks aren't SVs
+func (line *VcfLine) IsStructuralVariant() bool {
+	if line.IsReferenceBlock() {
+		return false
+	}
+	return strings.HasPrefix(line.Alt, symbolicAlt) || line.GetInfo(SVTypeKey) != ""
+}
+
+// Reads the type and span of a structural variant from SVTYPE, END, SVLEN and CIPOS, falling back
+// on the symbolic ALT for the type. Like the VCF spec, POS is the base before the event, so the
+// span starts after it
+func (line *VcfLine) StructuralVariant() (*StructuralVariant, bool) {
+	if !line.IsStructuralVariant() {
+		return nil, false
+	}
+	sv := &StructuralVariant{
+		Type:  strings.ToUpper(line.GetInfo(SVTypeKey)),
+		Start: line.Pos + 1,
+	}
+	if sv.Type == "" {
+		sv.Type = symbolicType(line.Alt)
+	}
+	if svLen, err := strconv.Atoi(strings.Split(line.GetInfo(SVLenKey), ",")[0]); err == nil {
+		if svLen < 0 {
+			svLen = -svLen
+		}
+		sv.Length = svLen
+	}
+	if end, err := strconv.Atoi(line.GetInfo(EndKey)); err == nil {
+		sv.End = end
+	} else if sv.Length > 0 {
+		sv.End = line.Pos + sv.Length
+	} else {
+		sv.End = line.Pos + len(line.Ref) - 1
+	}
+	if sv.End < sv.Start {
+		// Insertions and breakends don't cover any reference bases
+		sv.End = sv.Start
+	}
+	if sv.Length == 0 {
+		sv.Length = sv.Span()
+	}
+	if ci := strings.Split(line.GetInfo(CIPosKey), ","); len(ci) == 2 {
+		low, lowErr := strconv.Atoi(ci[0])
+		high, highErr := strconv.Atoi(ci[1])
+		if lowErr == nil && highErr == nil {
+			sv.CIPos = [2]int{low, high}
+		}
+	}
+	return sv, true
+}
+
+// Turns <DEL>, <DUP:TANDEM> or <DEL:ME:ALU> into the main SV type
+func symbolicType(alt string) string {
+	alt = strings.Trim(alt, "<>")
+	return strings.ToUpper(strings.Split(alt, ":")[0])
+}
@@ -1,17 +1,69 @@
 package vcf
 
-import "strings"
+import (
+	"strconv"
+	"strings"
+)
 
-func parseInfo(info string) map[string]string {
-	infoMap := make(map[string]string)
-	infoParts := strings.Split(info, ";")
-	for _, infoPart := range infoParts {
-		parts := strings.Split(infoPart, "=")
-		if len(parts) == 2 {
-			infoMap[parts[0]] = parts[1]
+// Finds a key in an INFO column without splitting the whole column up. The last of a repeated key
+// wins, and flags and values with a second = are blank
+func infoValue(info string, key string) (string, bool) {
+	value, found := "", false
+	for len(info) > 0 {
+		part := info
+		if end := strings.IndexByte(info, ';'); end >= 0 {
+			part, info = info[:end], info[end+1:]
 		} else {
-			infoMap[parts[0]] = ""
+			info = ""
 		}
+		if !strings.HasPrefix(part, key) {
+			continue
+		}
+		switch rest := part[len(key):]; {
+		case rest == "":
+			value, found = "", true
+		case rest[0] == '=':
+			value, found = rest[1:], true
+			if strings.IndexByte(value, '=') >= 0 {
+				value = ""
+			}
+		}
+	}
+	return value, found
+}
+
+// Strips the chr prefix so chr1 and 1 refer to the same chromosome, and uses MT for the mitochondria
+func NormalizeChrom(chrom string) string {
+	if len(chrom) > 3 && strings.EqualFold(chrom[:3], "chr") {
+		chrom = chrom[3:]
+	}
+	if chrom == "M" {
+		return "MT"
+	}
+	return chrom
+}
+
+// Orders chromosomes the way references do, 1 to 22 numerically, then X, Y and MT, then anything else
+func ChromLess(a string, b string) bool {
+	rankA, rankB := chromRank(a), chromRank(b)
+	if rankA != rankB {
+		return rankA < rankB
+	}
+	return NormalizeChrom(a) < NormalizeChrom(b)
+}
+
+func chromRank(chrom string) int {
+	chrom = NormalizeChrom(chrom)
+	if number, err := strconv.Atoi(chrom); err == nil {
+		return number
+	}
+	switch strings.ToUpper(chrom) {
+	case "X":
+		return 100
+	case "Y":
+		return 101
+	case "MT":
+		return 102
 	}
-	return infoMap
+	return 1000
 }
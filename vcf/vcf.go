@@ -1,99 +1,212 @@
 package vcf
 
 import (
-	"archive/zip"
 	"bufio"
-	"compress/gzip"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"os"
 	"strconv"
 	"strings"
 
+	"github.com/kazmiekr/clinvar-matcher/progress"
 	log "github.com/sirupsen/logrus"
 )
 
 type VcfLine struct {
-	Chrom      string
-	Pos        int
-	ID         string
-	Ref        string
-	Alt        string
-	Qual       string
-	Filter     string
-	Info       map[string]string
+	Chrom  string
+	Pos    int
+	ID     string
+	Ref    string
+	Alt    string
+	Qual   string
+	Filter string
+	// INFO decoded from BCF. Text VCF lines leave it nil and keep the INFO column in InfoText, which
+	// GetInfo looks through when asked since most keys are never read. Lines built by hand set InfoText
+	info       map[string]string
+	InfoText   string
 	Format     string
 	Sample     string
 	SampleData map[string]string
+	// Every sample column in a multi-sample VCF, Sample and SampleData are the first one
+	Samples []map[string]string
+}
+
+type Header struct {
+	MetaLines   []string
+	SampleNames []string
+	Info        map[string]*FieldDefinition
+	Format      map[string]*FieldDefinition
+	// Dictionaries BCF uses for FILTER, INFO and FORMAT keys, and chromosomes
+	Strings []string
+	Contigs []string
+}
+
+func NewHeader() *Header {
+	return &Header{
+		MetaLines:   make([]string, 0),
+		SampleNames: make([]string, 0),
+		Info:        make(map[string]*FieldDefinition),
+		Format:      make(map[string]*FieldDefinition),
+		Strings:     make([]string, 0),
+		Contigs:     make([]string, 0),
+	}
+}
+
+// Returns the index of the sample column with this name, or -1 if it's not in the VCF
+func (header *Header) SampleIndex(name string) int {
+	for i, sampleName := range header.SampleNames {
+		if sampleName == name {
+			return i
+		}
+	}
+	return -1
 }
 
 func (vcfLine VcfLine) GetSampleData(key string) string {
 	return vcfLine.SampleData[key]
 }
 
+func (vcfLine VcfLine) GetInfo(key string) string {
+	value, _ := vcfLine.lookupInfo(key)
+	return value
+}
+
+func (vcfLine VcfLine) lookupInfo(key string) (string, bool) {
+	if vcfLine.info != nil {
+		value, ok := vcfLine.info[key]
+		return value, ok
+	}
+	return infoValue(vcfLine.InfoText, key)
+}
+
+// Returns a copy of the line where Sample and SampleData are the sample at this index
+func (vcfLine *VcfLine) ForSample(index int) *VcfLine {
+	sampleLine := *vcfLine
+	sampleLine.SampleData = make(map[string]string)
+	if index >= 0 && index < len(vcfLine.Samples) {
+		sampleLine.SampleData = vcfLine.Samples[index]
+	}
+	sampleLine.Sample = ""
+	return &sampleLine
+}
+
 func ReadVcf(vcfPath string) ([]*VcfLine, error) {
+	return ReadVcfContext(context.Background(), vcfPath, nil)
+}
+
+// Like ReadVcf, stopping with the context's error once it's cancelled and telling the observer,
+// which can be nil, how far it's got
+func ReadVcfContext(ctx context.Context, vcfPath string, observer progress.Observer) ([]*VcfLine, error) {
+	_, lines, err := ReadVcfWithHeaderContext(ctx, vcfPath, observer)
+	return lines, err
+}
+
+func ReadVcfWithHeader(vcfPath string) (*Header, []*VcfLine, error) {
+	return ReadVcfWithHeaderContext(context.Background(), vcfPath, nil)
+}
+
+func ReadVcfWithHeaderContext(ctx context.Context, vcfPath string, observer progress.Observer) (*Header, []*VcfLine, error) {
+	tracker := progress.NewTracker(ctx, observer, vcfPath)
+	reader, err := OpenTracked(vcfPath, tracker)
+	if err != nil {
+		return NewHeader(), make([]*VcfLine, 0), err
+	}
+	defer reader.Close()
+	return readVcfFrom(reader, tracker)
+}
+
+// Reads a text VCF or a BCF from an already opened stream, telling them apart by the BCF magic
+func ReadVcfFrom(input io.Reader) (*Header, []*VcfLine, error) {
+	return readVcfFrom(input, nil)
+}
+
+func readVcfFrom(input io.Reader, tracker *progress.Tracker) (*Header, []*VcfLine, error) {
+	header := NewHeader()
 	lines := make([]*VcfLine, 0)
-	var reader io.Reader
-	if strings.HasSuffix(vcfPath, "vcf.gz") {
-		file, err := os.Open(vcfPath)
-		if err != nil {
-			return lines, err
-		}
-		defer file.Close()
-		gz, err := gzip.NewReader(file)
-		if err != nil {
-			return lines, err
-		}
-		defer gz.Close()
-		reader = gz
-	} else if strings.HasSuffix(vcfPath, "vcf.zip") {
-		zipReader, err := zip.OpenReader(vcfPath)
-		if err != nil {
-			return lines, err
-		}
-		defer zipReader.Close()
-		// Ensure the zip only has one file and it's a VCF file
-		if len(zipReader.File) == 1 && strings.HasSuffix(zipReader.File[0].Name, "vcf") {
-			file, err := zipReader.File[0].Open()
-			if err != nil {
-				return lines, err
-			}
-			defer file.Close()
-			reader = file
-		} else {
-			return lines, fmt.Errorf("unable to locate vcf in zip")
-		}
-	} else if strings.HasSuffix(vcfPath, "vcf") {
-		file, err := os.Open(vcfPath)
-		if err != nil {
-			return lines, err
-		}
-		defer file.Close()
-		reader = file
-	} else {
-		return lines, fmt.Errorf("please supply a .vcf or .vcf.gz to read")
+	buffered := bufio.NewReader(input)
+	if start, err := buffered.Peek(len(bcfMagic)); err == nil && bytes.Equal(start, bcfMagic) {
+		return readBcfLines(buffered, tracker)
+	}
+	start, err := buffered.Peek(1)
+	if err != nil {
+		return header, lines, errNotVcf
 	}
+	headerless := start[0] != '#'
 
-	scanner := bufio.NewScanner(reader)
+	scanner := newLineScanner(buffered)
 	for scanner.Scan() {
 		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			parseHeaderLine(header, line)
+			continue
+		}
+		if headerless {
+			if err := checkHeaderless(line); err != nil {
+				return header, lines, err
+			}
+			headerless = false
+		}
 		vcfLine, err := parseVcfLine(line)
 		if err != nil {
-			return lines, err
+			return header, lines, err
 		}
 		if vcfLine != nil {
 			lines = append(lines, vcfLine)
 		}
+		if err := tracker.Records(1); err != nil {
+			return header, lines, err
+		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		return lines, err
+		return header, lines, err
+	}
+	return header, lines, tracker.Done()
+}
+
+var errNotVcf = errors.New("input isn't a VCF or BCF, it should start with a ## header")
+
+// Whether the line parses as a VCF data record, for telling headerless VCFs from other text
+func LooksLikeRecord(line string) bool {
+	record, err := parseVcfLine(line)
+	return record != nil && err == nil
+}
+
+// Records without a header are still read, with a warning, as long as the first one is a VCF record
+func checkHeaderless(first string) error {
+	if !LooksLikeRecord(first) {
+		return errNotVcf
+	}
+	log.Warn("The VCF has no header, reading its records anyway")
+	return nil
+}
+
+// Large deletions spell out their whole REF, so lines can be far longer than bufio's 64kb default
+const maxLineLength = 16 * 1024 * 1024
+
+func newLineScanner(reader io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+	return scanner
+}
+
+func parseHeaderLine(header *Header, line string) {
+	if strings.HasPrefix(line, "##") {
+		header.MetaLines = append(header.MetaLines, line)
+		header.addMetaLine(line)
+		return
+	}
+	columns := strings.Split(line, "\t")
+	if len(columns) > 9 {
+		header.SampleNames = columns[9:]
 	}
-	return lines, nil
 }
 
+// Returns nil for blank, header and truncated lines, which are skipped
 func parseVcfLine(line string) (*VcfLine, error) {
-	if line[0] == '#' {
+	if line == "" || line[0] == '#' {
 		return nil, nil
 	}
 	parts := strings.Split(line, "\t")
@@ -106,20 +219,28 @@ func parseVcfLine(line string) (*VcfLine, error) {
 	}
 	sample := ""
 	format := ""
-	sampleData := make(map[string]string)
-	if len(parts) == 10 {
+	// Sites only VCFs like ClinVar's have no samples, so they don't get maps
+	var sampleData map[string]string
+	var samplesData []map[string]string
+	if len(parts) >= 10 {
 		format = parts[8]
 		sample = parts[9]
 		formats := strings.Split(format, ":")
-		samples := strings.Split(sample, ":")
-		if len(formats) == len(samples) {
-			for x := 0; x < len(formats); x++ {
-				sampleData[formats[x]] = samples[x]
+		for _, sampleColumn := range parts[9:] {
+			data := make(map[string]string)
+			samples := strings.Split(sampleColumn, ":")
+			// Trailing fields can be left off, like ./. against GT:AD:DP:GQ, but not added
+			if len(samples) <= len(formats) {
+				for x := 0; x < len(samples); x++ {
+					data[formats[x]] = samples[x]
+				}
+			} else {
+				variantKey := fmt.Sprintf("%v:%d", parts[0], pos)
+				log.Warnf("Format/Sample length mismatch for variant: %v", variantKey)
 			}
-		} else {
-			variantKey := fmt.Sprintf("%v:%d", parts[0], pos)
-			log.Warnf("Format/Sample length mismatch for variant: %v", variantKey)
+			samplesData = append(samplesData, data)
 		}
+		sampleData = samplesData[0]
 	}
 
 	return &VcfLine{
@@ -127,12 +248,13 @@ func parseVcfLine(line string) (*VcfLine, error) {
 		Pos:        pos,
 		ID:         parts[2],
 		Ref:        parts[3],
-		Alt:        parts[4],
+		Alt:        stripNonRef(parts[4]),
 		Qual:       parts[5],
 		Filter:     parts[6],
-		Info:       parseInfo(parts[7]),
+		InfoText:   parts[7],
 		Format:     format,
 		Sample:     sample,
 		SampleData: sampleData,
+		Samples:    samplesData,
 	}, nil
 }
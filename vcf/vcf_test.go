@@ -0,0 +1,97 @@
+package vcf
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseVcfLineSamples(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected []map[string]string
+	}{
+		{
+			"all fields",
+			"1\t100\t.\tA\tG\t50\tPASS\t.\tGT:DP\t0/1:30\t1/1:12",
+			[]map[string]string{{"GT": "0/1", "DP": "30"}, {"GT": "1/1", "DP": "12"}},
+		},
+		{
+			"trailing fields left off",
+			"1\t100\t.\tA\tG\t50\tPASS\t.\tGT:AD:DP:GQ\t0/1:10,12:22:99\t./.",
+			[]map[string]string{{"GT": "0/1", "AD": "10,12", "DP": "22", "GQ": "99"}, {"GT": "./."}},
+		},
+		{
+			"more fields than the format",
+			"1\t100\t.\tA\tG\t50\tPASS\t.\tGT\t0/1:30",
+			[]map[string]string{{}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			line, err := parseVcfLine(test.line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(line.Samples, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, line.Samples)
+			}
+			if line.ForSample(len(test.expected)-1).GetSampleData("GT") != test.expected[len(test.expected)-1]["GT"] {
+				t.Errorf("wrong GT for the last sample")
+			}
+		})
+	}
+}
+
+func TestParseVcfLineSitesOnly(t *testing.T) {
+	line, err := parseVcfLine("1\t100\trs1\tA\tG\t.\t.\tRS=1;CLNSIG=Pathogenic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line.Samples != nil || line.SampleData != nil {
+		t.Errorf("expected no sample data, got %v", line.Samples)
+	}
+	if line.GetInfo("CLNSIG") != "Pathogenic" {
+		t.Errorf("expected CLNSIG Pathogenic, got %q", line.GetInfo("CLNSIG"))
+	}
+}
+
+func TestParseVcfLineSkipsBlankLines(t *testing.T) {
+	for _, text := range []string{"", "#CHROM\tPOS", "1\t100\t.\tA"} {
+		line, err := parseVcfLine(text)
+		if line != nil || err != nil {
+			t.Errorf("%q: expected it to be skipped, got %v, %v", text, line, err)
+		}
+	}
+	lines, err := parseVcfLines([]string{"1\t100\t.\tA\tG\t50\tPASS\t.", "", "1\t200\t.\tC\tT\t50\tPASS\t."})
+	if err != nil || len(lines) != 2 {
+		t.Errorf("expected 2 lines around the blank one, got %d, %v", len(lines), err)
+	}
+}
+
+func TestReadVcfWithoutHeader(t *testing.T) {
+	records := "1\t100\t.\tA\tG\t50\tPASS\t.\tGT\t0/1\n2\t200\t.\tC\tT\t50\tPASS\t.\tGT\t1/1\n"
+	tests := []struct {
+		text  string
+		lines int
+		ok    bool
+	}{
+		{records, 2, true},
+		{"##fileformat=VCFv4.2\n" + records, 2, true},
+		{"hello\n" + records, 0, false},
+		{"1\tlots\t.\tA\tG\t50\tPASS\t.\n", 0, false},
+		{"", 0, false},
+	}
+	for _, workers := range []int{1, 2} {
+		for _, test := range tests {
+			_, lines, err := ReadVcfFromParallel(strings.NewReader(test.text), workers)
+			if (err == nil) != test.ok || len(lines) != test.lines {
+				t.Errorf("%d workers, %q: expected %d lines, got %d, %v", workers, test.text, test.lines, len(lines), err)
+			}
+		}
+	}
+	if !LooksLikeRecord("1\t100\t.\tA\tG\t50\tPASS\t.") || LooksLikeRecord("rs1\t1\t1000\tAG") {
+		t.Errorf("expected only the VCF record to look like one")
+	}
+}